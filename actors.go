@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Framework roles reported by the Akka/Pekko analysis.
+const (
+	RoleActor         = "actor"
+	RoleTypedBehavior = "typed-behavior"
+	RoleActorSystem   = "actor-system"
+)
+
+var classicActorParents = map[string]bool{
+	"Actor": true, "AbstractActor": true, "ActorLogging": true, "PersistentActor": true,
+	"AbstractPersistentActor": true, "Timers": true, "Stash": true,
+}
+
+var typedBehaviorParents = map[string]bool{
+	"AbstractBehavior": true, "ExtensibleBehavior": true, "EventSourcedBehavior": true,
+}
+
+// ActorRoles is an Extractor that tags files using Akka or Pekko actors in
+// FrameworkRoles: classic actors, typed behaviors, and files that create an
+// ActorSystem (usually the guardian). Only files importing from either framework
+// are considered.
+var ActorRoles Extractor = actorRoles{}
+
+type actorRoles struct{}
+
+func (actorRoles) Name() string {
+	return "actor-roles"
+}
+
+func (actorRoles) Visit(node *sitter.Node, sourceCode []byte, result *ParseResult) {
+	if !usesActors(result.Imports) {
+		return
+	}
+
+	switch node.Type() {
+	case "class_definition", "object_definition", "trait_definition":
+		for _, parent := range readParents(node, sourceCode) {
+			parent = parent[strings.LastIndex(parent, ".")+1:]
+			if classicActorParents[parent] {
+				addRole(result, RoleActor)
+			} else if typedBehaviorParents[parent] {
+				addRole(result, RoleTypedBehavior)
+			}
+		}
+
+	case "call_expression":
+		function := node.ChildByFieldName("function")
+		if function.Type() == "field_expression" {
+			function = function.ChildByFieldName("value")
+		}
+		if function.Content(sourceCode) == "ActorSystem" {
+			addRole(result, RoleActorSystem)
+		}
+	}
+}
+
+func usesActors(imports []string) bool {
+	for _, imp := range imports {
+		if strings.HasPrefix(imp, "akka.") || strings.HasPrefix(imp, "org.apache.pekko.") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func addRole(result *ParseResult, role string) {
+	if !containsString(result.FrameworkRoles, role) {
+		result.FrameworkRoles = append(result.FrameworkRoles, role)
+	}
+}
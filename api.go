@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// APISurfaceFile is the JSON written by `index --json` and read by `diff`.
+type APISurfaceFile struct {
+	SchemaVersion int `json:"schemaVersion"`
+	Symbols       []APISymbol
+}
+
+// APISymbol is a public symbol as recorded in a saved API surface.
+type APISymbol struct {
+	Symbol     string
+	Kind       string
+	File       string
+	Line       int
+	Modifiers  []string    `json:",omitempty"`
+	Parents    []string    `json:",omitempty"`
+	Fields     []Field     `json:",omitempty"`
+	TypeParams []TypeParam `json:",omitempty"`
+	Signature  string      `json:",omitempty"`
+}
+
+// APISurface returns the public symbols defined by results, sorted by name.
+func APISurface(results []*ParseResult) []APISymbol {
+	var surface []APISymbol
+	for _, result := range results {
+		for _, definition := range result.Definitions {
+			surface = append(surface, APISymbol{
+				Symbol:     qualify(result.Package, definition.Name),
+				Kind:       definition.Kind,
+				File:       result.File,
+				Line:       definition.Line,
+				Modifiers:  definition.Modifiers,
+				Parents:    definition.Parents,
+				Fields:     definition.Fields,
+				TypeParams: definition.TypeParams,
+				Signature:  definition.Signature,
+			})
+		}
+	}
+
+	sort.SliceStable(surface, func(i, j int) bool {
+		return surface[i].Symbol < surface[j].Symbol
+	})
+	return surface
+}
+
+func readAPISurface(path string) ([]APISymbol, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file APISurfaceFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if file.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("%s: schema version %d is newer than the supported version %d", path, file.SchemaVersion, SchemaVersion)
+	}
+	return file.Symbols, nil
+}
+
+// apiKey identifies a symbol across revisions. Types and terms are kept apart,
+// so a class and its companion object are compared separately.
+func (s APISymbol) apiKey() string {
+	switch s.Kind {
+	case "class", "trait", "type":
+		return s.Symbol + " (type)"
+	default:
+		return s.Symbol
+	}
+}
+
+// describe summarises everything about the symbol that affects its users.
+func (s APISymbol) describe() string {
+	var b strings.Builder
+	for _, modifier := range s.Modifiers {
+		if modifier == "case" && s.Kind == "case object" {
+			continue
+		}
+		b.WriteString(modifier + " ")
+	}
+	b.WriteString(s.Kind)
+	if len(s.TypeParams) > 0 {
+		params := make([]string, 0, len(s.TypeParams))
+		for _, param := range s.TypeParams {
+			params = append(params, param.String())
+		}
+		b.WriteString(" [" + strings.Join(params, ", ") + "]")
+	}
+	if s.Signature != "" {
+		b.WriteString(" " + s.Signature)
+	}
+	if len(s.Parents) > 0 {
+		b.WriteString(" extends " + strings.Join(s.Parents, " with "))
+	}
+	if len(s.Fields) > 0 {
+		fields := make([]string, 0, len(s.Fields))
+		for _, field := range s.Fields {
+			text := field.Name + ": " + field.Type
+			if field.Default != "" {
+				text += " = " + field.Default
+			}
+			fields = append(fields, text)
+		}
+		b.WriteString(" (" + strings.Join(fields, ", ") + ")")
+	}
+	return b.String()
+}
+
+// APIChange is a difference between two API surfaces. Old is empty for added
+// symbols and New for removed ones.
+type APIChange struct {
+	Symbol string
+	Old    string
+	New    string
+}
+
+func describeAll(surface []APISymbol) (map[string]string, map[string]string) {
+	grouped := make(map[string][]string)
+	names := make(map[string]string)
+	for _, symbol := range surface {
+		key := symbol.apiKey()
+		grouped[key] = append(grouped[key], symbol.describe())
+		names[key] = symbol.Symbol
+	}
+
+	descriptions := make(map[string]string, len(grouped))
+	for key, overloads := range grouped {
+		sort.Strings(overloads)
+		descriptions[key] = strings.Join(overloads, " | ")
+	}
+	return descriptions, names
+}
+
+// DiffAPI returns the symbols added, removed or changed between two API surfaces,
+// sorted by name. Overloads are compared as a set, since their signatures aren't
+// recorded.
+func DiffAPI(old, new []APISymbol) []APIChange {
+	oldDescriptions, oldNames := describeAll(old)
+	newDescriptions, newNames := describeAll(new)
+
+	var changes []APIChange
+	for key, description := range oldDescriptions {
+		if newDescriptions[key] != description {
+			changes = append(changes, APIChange{Symbol: oldNames[key], Old: description, New: newDescriptions[key]})
+		}
+	}
+	for key, description := range newDescriptions {
+		if _, ok := oldDescriptions[key]; !ok {
+			changes = append(changes, APIChange{Symbol: newNames[key], New: description})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Symbol != changes[j].Symbol {
+			return changes[i].Symbol < changes[j].Symbol
+		}
+		return changes[i].Old < changes[j].Old
+	})
+	return changes
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// BacktickMode controls how backtick-quoted identifiers, e.g. `type`, appear in
+// extracted names.
+type BacktickMode int
+
+const (
+	// StripBackticks removes all backticks, giving names as they appear on the JVM.
+	StripBackticks BacktickMode = iota
+	// KeepBackticks keeps backticks only where they are required, i.e. around
+	// keywords and names that are not plain identifiers.
+	KeepBackticks
+)
+
+var scalaKeywords = map[string]bool{
+	"abstract": true, "case": true, "catch": true, "class": true, "def": true,
+	"do": true, "else": true, "enum": true, "export": true, "extends": true,
+	"false": true, "final": true, "finally": true, "for": true, "forSome": true,
+	"given": true, "if": true, "implicit": true, "import": true, "lazy": true,
+	"macro": true, "match": true, "new": true, "null": true, "object": true,
+	"override": true, "package": true, "private": true, "protected": true,
+	"return": true, "sealed": true, "super": true, "then": true, "this": true,
+	"throw": true, "trait": true, "true": true, "try": true, "type": true,
+	"val": true, "var": true, "while": true, "with": true, "yield": true,
+}
+
+func (m BacktickMode) String() string {
+	if m == KeepBackticks {
+		return "keep"
+	}
+	return "strip"
+}
+
+// Set parses a mode name as printed by String, so a BacktickMode can be used as
+// a flag.Value.
+func (m *BacktickMode) Set(name string) error {
+	switch name {
+	case "strip":
+		*m = StripBackticks
+	case "keep":
+		*m = KeepBackticks
+	default:
+		return fmt.Errorf("unknown backtick mode %q, expected strip or keep", name)
+	}
+	return nil
+}
+
+func WithBackticks(mode BacktickMode) Option {
+	return func(p *treeSitterParser) {
+		p.backticks = mode
+	}
+}
+
+// normalizeBackticks normalizes each segment of a dotted name.
+func normalizeBackticks(name string, mode BacktickMode) string {
+	if !strings.Contains(name, "`") {
+		return name
+	}
+
+	segments := splitQualifiedName(name)
+	for i, segment := range segments {
+		unquoted := strings.Trim(segment, "`")
+		if mode == KeepBackticks && needsBackticks(unquoted) {
+			segments[i] = "`" + unquoted + "`"
+		} else {
+			segments[i] = unquoted
+		}
+	}
+
+	return strings.Join(segments, ".")
+}
+
+// splitQualifiedName splits a name on dots outside of backticks.
+func splitQualifiedName(name string) []string {
+	segments := make([]string, 0)
+	quoted := false
+	start := 0
+
+	for i, r := range name {
+		if r == '`' {
+			quoted = !quoted
+		} else if r == '.' && !quoted {
+			segments = append(segments, name[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(segments, name[start:])
+}
+
+func needsBackticks(identifier string) bool {
+	if identifier == "" {
+		return false
+	} else if scalaKeywords[identifier] {
+		return true
+	}
+
+	for i, r := range identifier {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		// operator identifiers, e.g. `++`, don't need quoting as long as they
+		// aren't mixed with letters
+		return !isOperatorIdentifier(identifier)
+	}
+
+	return false
+}
+
+func isOperatorIdentifier(identifier string) bool {
+	for _, r := range identifier {
+		if !isOperatorCharacter(r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// byteSize is a flag.Value for sizes such as `512M` or `2G`.
+type byteSize int64
+
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("expected a size such as 1048576, 512M or 2G")
+	}
+	*s = byteSize(n * multiplier)
+	return nil
+}
+
+// memoryBudget bounds the combined size of the files being parsed at once. A
+// file larger than the whole budget is parsed on its own.
+type memoryBudget struct {
+	mu    sync.Mutex
+	freed *sync.Cond
+	max   int64
+	used  int64
+}
+
+func newMemoryBudget(max int64) *memoryBudget {
+	b := &memoryBudget{max: max}
+	b.freed = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *memoryBudget) acquire(size int64) {
+	if b.max <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used > 0 && b.used+size > b.max {
+		b.freed.Wait()
+	}
+	b.used += size
+}
+
+func (b *memoryBudget) release(size int64) {
+	if b.max <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= size
+	b.freed.Broadcast()
+}
+
+// parsedFile is the outcome of parsing one file of a batch.
+type parsedFile struct {
+	result *ParseResult
+	errs   []error
+	// errorLines are errs formatted for stderr.
+	errorLines []string
+	// skipped is why the file was not parsed, if it wasn't.
+	skipped string
+	// readErr is the error reading the file, if it could not be read.
+	readErr error
+}
+
+// queuedFile is the index of a file of a batch, and the size acquired from the
+// memory budget for it, which is released once it is done.
+type queuedFile struct {
+	index int
+	size  int64
+}
+
+// fileSize returns the size of a file before it is read, or 0 if it is unknown.
+func fileSize(filePath string) int64 {
+	if filePath == "-" {
+		return 0
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// parseFiles parses files with up to --jobs at once, holding no more than
+// --max-memory of sources, and calls fn with each result in the order of files
+// as soon as it and those before it are done. Parse errors are reported on
+// stderr.
+func (f *parseFlags) parseFiles(files []string, fn func(result *ParseResult), extra ...Option) {
+	parser := NewParser(append(f.options(), extra...)...)
+	defer parser.Close()
+	cache := f.resultCacheFor(extra)
+
+	jobs := f.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	budget := newMemoryBudget(int64(f.maxMemory))
+
+	slots := make([]chan parsedFile, len(files))
+	for i := range slots {
+		slots[i] = make(chan parsedFile, 1)
+	}
+
+	// NOTE: memory is acquired in file order, before a worker takes the file, so
+	// the earliest unfinished file can always make progress.
+	queue := make(chan queuedFile)
+	go func() {
+		for i, filePath := range files {
+			size := fileSize(filePath)
+			budget.acquire(size)
+			queue <- queuedFile{index: i, size: size}
+		}
+		close(queue)
+	}()
+
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for queued := range queue {
+				// NOTE: check the size before reading, so huge files are never loaded.
+				i, size := queued.index, queued.size
+				if reason := skipReason(size, nil, int64(f.maxFileSize)); reason != "" {
+					budget.release(size)
+					slots[i] <- parsedFile{skipped: fmt.Sprintf("skipping %s: %s", files[i], reason)}
+					continue
+				}
+
+				// NOTE: decode the source once, here, as the checks and the cache key
+				// need it decoded too.
+				filePath, sourceCode, err := f.readSource(files[i])
+				if err != nil {
+					budget.release(size)
+					slots[i] <- parsedFile{readErr: err}
+					continue
+				}
+				sourceCode = prepareSource(sourceCode, f.encoding)
+				if reason := skipReason(int64(len(sourceCode)), sourceCode, int64(f.maxFileSize)); reason != "" {
+					budget.release(size)
+					slots[i] <- parsedFile{skipped: fmt.Sprintf("skipping %s: %s", filePath, reason)}
+					continue
+				}
+
+				var key string
+				if cache != nil {
+					key = f.cacheKey(filePath, sourceCode)
+					parsed, ok := lookupParse(cache, key, parser)
+					if f.parseStats != nil {
+						f.parseStats.recordResultLookup(ok)
+					}
+					if ok {
+						logf(LogVerbose, "using the cached result of %s\n", filePath)
+						budget.release(size)
+						slots[i] <- parsed
+						continue
+					}
+				}
+
+				logf(LogVerbose, "parsing %s (%d bytes)\n", filePath, len(sourceCode))
+				result, errs := parser.parsePrepared(filePath, sourceCode)
+				errorLines := f.formatErrors(result, filePath, errs)
+				budget.release(size)
+				parsed := parsedFile{result: result, errs: errs, errorLines: errorLines}
+				if cache != nil {
+					storeParse(cache, key, parsed)
+				}
+				slots[i] <- parsed
+			}
+		}()
+	}
+
+	bar := newProgress(len(files), !f.noProgress)
+	for i := range files {
+		parsed := <-slots[i]
+		slots[i] = nil
+
+		if parsed.skipped != "" {
+			bar.clear()
+			logf(LogDefault, "%s\n", parsed.skipped)
+			bar.fileDone(false)
+			continue
+		}
+		if parsed.readErr != nil {
+			bar.clear()
+			fmt.Fprintln(os.Stderr, parsed.readErr)
+			setExitCode(exitInternal)
+			bar.fileDone(true)
+			continue
+		}
+
+		if len(parsed.errorLines) > 0 {
+			bar.clear()
+		}
+		for _, line := range parsed.errorLines {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		f.failOn.check(parsed.result, parsed.errs)
+		fn(parsed.result)
+		bar.fileDone(len(parsed.errs) > 0)
+	}
+	bar.clear()
+
+	if f.parseStats != nil {
+		f.parseStats.Report(os.Stderr, 10)
+	}
+}
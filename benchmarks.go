@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// isBenchmark reports whether a file holds JMH benchmarks: it imports from
+// org.openjdk.jmh, or has a method annotated `@Benchmark`.
+func isBenchmark(node *sitter.Node, sourceCode []byte, imports []string) bool {
+	for _, imp := range imports {
+		if strings.HasPrefix(imp, "org.openjdk.jmh.") {
+			return true
+		}
+	}
+
+	return hasAnnotatedMethod(node, sourceCode, "Benchmark", "org.openjdk.jmh.annotations.Benchmark")
+}
+
+// hasAnnotatedMethod reports whether any method beneath node has one of the
+// given annotations.
+func hasAnnotatedMethod(node *sitter.Node, sourceCode []byte, annotations ...string) bool {
+	if node.Type() == "function_definition" {
+		for _, annotation := range readAnnotations(node, sourceCode) {
+			if containsString(annotations, annotation) {
+				return true
+			}
+		}
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if hasAnnotatedMethod(node.NamedChild(i), sourceCode, annotations...) {
+			return true
+		}
+	}
+
+	return false
+}
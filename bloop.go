@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BloopProject is a module of an existing build, as exported to a Bloop
+// `.bloop/<name>.json` file by sbt, Mill or Gradle.
+type BloopProject struct {
+	Name      string
+	Directory string
+	// Sources are the source directories and files of the module, as absolute paths.
+	Sources []string
+	// Dependencies are the names of the modules this one depends on.
+	Dependencies []string
+	// Classpath holds the jars and class directories the module compiles against.
+	Classpath    []string
+	ScalaVersion string
+	Test         bool
+}
+
+// bloopFile is the JSON form of a Bloop project file.
+type bloopFile struct {
+	Project struct {
+		Name         string   `json:"name"`
+		Directory    string   `json:"directory"`
+		Sources      []string `json:"sources"`
+		Dependencies []string `json:"dependencies"`
+		Classpath    []string `json:"classpath"`
+		Scala        *struct {
+			Version string `json:"version"`
+		} `json:"scala"`
+		Tags []string `json:"tags"`
+	} `json:"project"`
+}
+
+// ReadBloopProjects reads the project files in the `.bloop` directory of the
+// build at workspace.
+func ReadBloopProjects(workspace string) ([]BloopProject, error) {
+	files, err := filepath.Glob(filepath.Join(workspace, ".bloop", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Bloop projects in %s", filepath.Join(workspace, ".bloop"))
+	}
+
+	projects := make([]BloopProject, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var parsed bloopFile
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		project := BloopProject{
+			Name:         parsed.Project.Name,
+			Directory:    parsed.Project.Directory,
+			Sources:      parsed.Project.Sources,
+			Dependencies: parsed.Project.Dependencies,
+			Classpath:    parsed.Project.Classpath,
+			// NOTE: older exports have no tags, but name test modules `<name>-test`.
+			Test: containsString(parsed.Project.Tags, "test") || strings.HasSuffix(parsed.Project.Name, "-test"),
+		}
+		if parsed.Project.Scala != nil {
+			project.ScalaVersion = parsed.Project.Scala.Version
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+// WithBloopProjects attributes each file to the module among projects whose
+// sources contain it, reported as ParseResult.Module.
+func WithBloopProjects(projects []BloopProject) Option {
+	return func(p *treeSitterParser) {
+		p.bloopProjects = projects
+	}
+}
+
+// bloopModule returns the name of the module whose sources contain filePath.
+// Where sources nest, e.g. a module of generated sources beneath another's
+// source directory, the most specific source wins.
+func (p *treeSitterParser) bloopModule(filePath string) string {
+	file := absPath(filePath)
+	module, longest := "", -1
+	for _, project := range p.bloopProjects {
+		for _, source := range project.Sources {
+			source = filepath.Clean(source)
+			if file != source && !strings.HasPrefix(file, source+string(filepath.Separator)) {
+				continue
+			}
+			if len(source) > longest {
+				module, longest = project.Name, len(source)
+			}
+		}
+	}
+	return module
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// buildFile is a BUILD file, parsed with buildifier's parser so it is written
+// back as buildifier formats it.
+type buildFile struct {
+	*build.File
+}
+
+// parseBuildFile parses src as a BUILD file.
+func parseBuildFile(src string) (*buildFile, error) {
+	file, err := build.ParseBuild("BUILD", []byte(src))
+	if err != nil {
+		var parseErr build.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("%d:%d: %s", parseErr.Pos.Line, parseErr.Pos.LineRune, parseErr.Message)
+		}
+		return nil, err
+	}
+	return &buildFile{file}, nil
+}
+
+// isKeepComment reports whether comment is a Gazelle-style `# keep` comment,
+// optionally with a reason, e.g. `# keep: loaded reflectively`.
+func isKeepComment(comment string) bool {
+	text := strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+	return text == "keep" || strings.HasPrefix(text, "keep:")
+}
+
+// hasKeepComment reports whether a `# keep` comment is attached to expr, on
+// the line before it or after it on its line.
+func hasKeepComment(expr build.Expr) bool {
+	comments := expr.Comment()
+	for _, comment := range append(comments.Before, comments.Suffix...) {
+		if isKeepComment(comment.Token) {
+			return true
+		}
+	}
+	return false
+}
+
+// isKeptRule reports whether rule is marked `# keep`, on the line before it or
+// after its opening parenthesis. Kept rules are never changed.
+func isKeptRule(rule *build.Rule) bool {
+	if hasKeepComment(rule.Call) {
+		return true
+	}
+	// NOTE: the parser attaches a comment after the opening parenthesis to the
+	// first argument, and buildifier moves it onto a line of its own.
+	if len(rule.Call.List) > 0 {
+		for _, comment := range rule.Call.List[0].Comment().Before {
+			if isKeepComment(comment.Token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isKeptAttr reports whether the attribute called name is marked `# keep`.
+func isKeptAttr(rule *build.Rule, name string) bool {
+	attr := rule.AttrDefn(name)
+	return attr != nil && (hasKeepComment(attr) || hasKeepComment(attr.RHS))
+}
+
+// setStringAttr sets the string attribute called name, or removes it if value
+// is empty. Attributes marked `# keep` are left alone.
+func setStringAttr(rule *build.Rule, name, value string) {
+	switch str, isString := rule.Attr(name).(*build.StringExpr); {
+	case isKeptAttr(rule, name):
+	case value == "":
+		rule.DelAttr(name)
+	case isString:
+		str.Value = value
+	default:
+		rule.SetAttr(name, &build.StringExpr{Value: value})
+	}
+}
+
+// setListAttr sets the list attribute called name to values, plus any items
+// marked `# keep`, or removes it if that leaves it empty. Attributes marked
+// `# keep`, or whose value is not a plain list, e.g. a glob or select, are left
+// alone.
+func setListAttr(rule *build.Rule, name string, values []string) {
+	current := rule.Attr(name)
+	list, isList := current.(*build.ListExpr)
+	if isKeptAttr(rule, name) || (current != nil && !isList) {
+		return
+	}
+
+	var items []build.Expr
+	if list != nil {
+		for _, item := range list.List {
+			if hasKeepComment(item) {
+				items = append(items, item)
+			}
+		}
+	}
+	for _, value := range values {
+		if !containsItem(items, value) {
+			items = append(items, &build.StringExpr{Value: value})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return compareLabels(itemValue(items[i]), itemValue(items[j])) })
+
+	switch {
+	case len(items) == 0:
+		rule.DelAttr(name)
+	case list == nil:
+		rule.SetAttr(name, &build.ListExpr{List: items, ForceMultiLine: len(items) > 1})
+	default:
+		list.List = items
+		list.ForceMultiLine = len(items) > 1
+	}
+}
+
+// itemValue returns the value of a string list item, or "".
+func itemValue(item build.Expr) string {
+	if str, ok := item.(*build.StringExpr); ok {
+		return str.Value
+	}
+	return ""
+}
+
+func containsItem(items []build.Expr, value string) bool {
+	for _, item := range items {
+		if itemValue(item) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// compareLabels orders labels as buildifier does: labels in the same package
+// first, then the rest of the repository, then other repositories.
+func compareLabels(a, b string) bool {
+	priority := func(label string) int {
+		switch {
+		case strings.HasPrefix(label, ":"):
+			return 0
+		case strings.HasPrefix(label, "//"):
+			return 1
+		case strings.HasPrefix(label, "@"):
+			return 2
+		}
+		return 0
+	}
+	if pa, pb := priority(a), priority(b); pa != pb {
+		return pa < pb
+	}
+	return a < b
+}
+
+// rules returns the rules of the file.
+func (f *buildFile) rules() []*build.Rule {
+	return f.Rules("")
+}
+
+// rule returns the rule called name, or nil.
+func (f *buildFile) rule(name string) *build.Rule {
+	for _, rule := range f.rules() {
+		if rule.Name() == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// loads returns the load statements of the file.
+func (f *buildFile) loads() []*build.LoadStmt {
+	var loads []*build.LoadStmt
+	for _, stmt := range f.Stmt {
+		if load, ok := stmt.(*build.LoadStmt); ok {
+			loads = append(loads, load)
+		}
+	}
+	return loads
+}
+
+// loaded reports whether symbol is loaded by the file.
+func (f *buildFile) loaded(symbol string) bool {
+	for _, load := range f.loads() {
+		for _, to := range load.To {
+			if to.Name == symbol {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addLoad loads symbols from label, adding to an existing load of label if
+// there is one, or else a new load after the file's leading comments.
+func (f *buildFile) addLoad(label string, symbols ...string) {
+	for _, load := range f.loads() {
+		if load.Module.Value == label {
+			for _, symbol := range symbols {
+				if !f.loaded(symbol) {
+					load.From = append(load.From, &build.Ident{Name: symbol})
+					load.To = append(load.To, &build.Ident{Name: symbol})
+				}
+			}
+			return
+		}
+	}
+
+	load := &build.LoadStmt{Module: &build.StringExpr{Value: label}, ForceCompact: true}
+	for _, symbol := range symbols {
+		load.From = append(load.From, &build.Ident{Name: symbol})
+		load.To = append(load.To, &build.Ident{Name: symbol})
+	}
+
+	at := 0
+	for at < len(f.Stmt) {
+		if _, ok := f.Stmt[at].(*build.CommentBlock); !ok {
+			break
+		}
+		at++
+	}
+	f.Stmt = append(f.Stmt[:at], append([]build.Expr{load}, f.Stmt[at:]...)...)
+}
+
+// newRule returns a rule of kind called name, not yet in any file.
+func newRule(kind, name string) *build.Rule {
+	rule := build.NewRule(&build.CallExpr{X: &build.Ident{Name: kind}, ForceMultiLine: true})
+	rule.SetAttr("name", &build.StringExpr{Value: name})
+	return rule
+}
+
+// addRule appends rule to the file.
+func (f *buildFile) addRule(rule *build.Rule) {
+	f.Stmt = append(f.Stmt, rule.Call)
+}
+
+// String renders the file as buildifier formats it.
+func (f *buildFile) String() string {
+	return string(build.Format(f.File))
+}
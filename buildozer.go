@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// buildozerCommands returns the buildozer commands that make the changes to
+// the BUILD file src that mergeBuildFile would, as lines of a buildozer command
+// file: `command args|label`. src is empty for a package with no BUILD file
+// yet, which buildozer needs to be created first.
+func buildozerCommands(src string, targets []*BuildTarget, opts BuildOptions) ([]string, error) {
+	original, err := parseBuildFile(src)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := parseBuildFile(src)
+	if err != nil {
+		return nil, err
+	}
+	updateBuildFile(updated, targets, opts)
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	pkg := targets[0].Package
+	var commands []string
+	command := func(label string, args ...string) {
+		for i, arg := range args {
+			args[i] = strings.ReplaceAll(arg, " ", `\ `)
+		}
+		commands = append(commands, strings.Join(args, " ")+"|"+label)
+	}
+
+	for _, load := range updated.loads() {
+		var added []string
+		for _, symbol := range load.To {
+			if !original.loaded(symbol.Name) {
+				added = append(added, symbol.Name)
+			}
+		}
+		if len(added) > 0 {
+			command("//"+pkg+":__pkg__", append([]string{"new_load", load.Module.Value}, added...)...)
+		}
+	}
+
+	for _, target := range targets {
+		label := "//" + pkg + ":" + target.Name
+		before, after := original.rule(target.Name), updated.rule(target.Name)
+		if before == nil {
+			command("//"+pkg+":__pkg__", "new", after.Kind(), target.Name)
+			before = newRule(after.Kind(), target.Name)
+		}
+		if before.Kind() != after.Kind() {
+			command(label, "set", "kind", after.Kind())
+		}
+
+		for _, name := range after.AttrKeys() {
+			old := before.Attr(name)
+			switch value := after.Attr(name).(type) {
+			case *build.ListExpr:
+				oldList, _ := old.(*build.ListExpr)
+				var added []string
+				for _, item := range value.List {
+					if str := itemValue(item); str != "" && (oldList == nil || !containsItem(oldList.List, str)) {
+						added = append(added, str)
+					}
+				}
+				if len(added) > 0 {
+					command(label, append([]string{"add", name}, added...)...)
+				}
+			case *build.StringExpr:
+				if oldString, ok := old.(*build.StringExpr); !ok || oldString.Value != value.Value {
+					command(label, "set", name, value.Value)
+				}
+			}
+		}
+
+		for _, name := range before.AttrKeys() {
+			current := after.Attr(name)
+			if current == nil {
+				command(label, "remove", name)
+				continue
+			}
+			currentList, isList := current.(*build.ListExpr)
+			var removed []string
+			for _, value := range before.AttrStrings(name) {
+				if isList && !containsItem(currentList.List, value) {
+					removed = append(removed, value)
+				}
+			}
+			if len(removed) > 0 {
+				command(label, append([]string{"remove", name}, removed...)...)
+			}
+		}
+	}
+	return commands, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+)
+
+// ImportClass is where an import comes from, which decides how a build depends
+// on it.
+type ImportClass string
+
+const (
+	// StdlibImport is an import from the Scala or Java standard library, which
+	// needs no dependency.
+	StdlibImport ImportClass = "stdlib"
+	// FirstPartyImport is an import from code in the workspace.
+	FirstPartyImport ImportClass = "first-party"
+	// ThirdPartyImport is an import from anything else, usually a Maven artifact.
+	ThirdPartyImport ImportClass = "third-party"
+)
+
+// stdlibPrefixes are the packages of the Scala and Java standard libraries.
+var stdlibPrefixes = []string{"scala.", "java.", "javax.", "jdk."}
+
+// ImportGroups are a file's imports grouped by ImportClass, each in source order.
+type ImportGroups struct {
+	Stdlib     []string
+	FirstParty []string
+	ThirdParty []string
+}
+
+// WithFirstPartyPrefixes classifies imports starting with any of prefixes, e.g.
+// `com.mycompany.`, as first-party. Imports from the file's own package, and
+// with WithIndex imports of indexed symbols, are always first-party.
+func WithFirstPartyPrefixes(prefixes ...string) Option {
+	return func(p *treeSitterParser) {
+		p.firstPartyPrefixes = append(p.firstPartyPrefixes, prefixes...)
+	}
+}
+
+// classifyImport returns the class of imp, imported by a file in package pkg.
+func (p *treeSitterParser) classifyImport(imp, pkg string) ImportClass {
+	for _, prefix := range stdlibPrefixes {
+		if strings.HasPrefix(imp, prefix) {
+			return StdlibImport
+		}
+	}
+
+	if isPackageImport(imp, pkg) || (p.index != nil && p.index.Defines(imp)) {
+		return FirstPartyImport
+	}
+	for _, prefix := range p.firstPartyPrefixes {
+		if strings.HasPrefix(imp, prefix) {
+			return FirstPartyImport
+		}
+	}
+	return ThirdPartyImport
+}
+
+// groupImports groups the imports of result by class.
+func (p *treeSitterParser) groupImports(result *ParseResult) ImportGroups {
+	var groups ImportGroups
+	for _, imp := range result.Imports {
+		switch p.classifyImport(imp, result.Package) {
+		case StdlibImport:
+			groups.Stdlib = append(groups.Stdlib, imp)
+		case FirstPartyImport:
+			groups.FirstParty = append(groups.FirstParty, imp)
+		default:
+			groups.ThirdParty = append(groups.ThirdParty, imp)
+		}
+	}
+	return groups
+}
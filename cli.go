@@ -0,0 +1,914 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"parse", "print everything extracted from each file, or stdin for -", runParseCommand},
+	{"imports", "print the imports of each file", runImportsCommand},
+	{"symbols", "print the fully-qualified symbols defined by each file", runSymbolsCommand},
+	{"packages", "print a summary of each package", runPackagesCommand},
+	{"advise-split", "suggest how to split packages into independent targets", runAdviseSplitCommand},
+	{"dead-code", "list public symbols no other file references", runDeadCodeCommand},
+	{"deprecated", "list the uses of deprecated symbols, with their deprecation messages", runDeprecatedCommand},
+	{"private-leaks", "list imports of private[pkg] definitions from outside their package", runPrivateLeaksCommand},
+	{"diff", "report public symbols added, removed or changed between two revisions", runDiffCommand},
+	{"graph", "print the file dependency graph in DOT format", runGraphCommand},
+	{"wildcard-edges", "list dependencies on other packages made only through wildcard imports", runWildcardEdgesCommand},
+	{"index", "print the files defining each symbol, or merge index shards", runIndexCommand},
+	{"rewrite-imports", "rename or expand imports, rewriting files in place", runRewriteImportsCommand},
+	{"organize-imports", "sort, deduplicate and group imports, rewriting files in place", runOrganizeImportsCommand},
+	{"generate", "write rules_scala rules into the BUILD files of each package", runGenerateCommand},
+	{"drift", "report missing and superfluous deps of the Scala rules of existing BUILD files", runDriftCommand},
+	{"owners", "print the owners of symbols, or the symbols of owners, according to CODEOWNERS", runOwnersCommand},
+	{"impact", "print the test files affected by changes to some files, following imports", runImpactCommand},
+	{"index-jars", "print the top-level symbols of jars with the jar or Bazel label providing each, for --artifacts", runIndexJarsCommand},
+	{"index-deps", "resolve the dependencies of sbt builds and scala-cli sources with coursier, and index their jars for --artifacts", runIndexDepsCommand},
+	{"tasty", "print the top-level symbols compiled to the .tasty files of jars or class directories", runTastyCommand},
+	{"coverage-report", "count the syntax nodes the symbol walker does not understand, with example locations", runCoverageReportCommand},
+	{"query", "run a tree-sitter query over each file", runQueryCommand},
+	{"dump-ast", "print the syntax tree of a file", runDumpASTCommand},
+	{"serve", "parse files posted over HTTP", runServeCommand},
+	{"lsp", "serve document and workspace symbols over the Language Server Protocol", runLSPCommand},
+	{"version", "print the version", runVersionCommand},
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: scala-tree-parser <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "scala-tree-parser <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "internal error: %v\n", r)
+			os.Exit(exitInternal)
+		}
+		os.Exit(exitCode)
+	}()
+
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return
+	}
+
+	loadGrammarFromEnv()
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(os.Args[2:])
+			return
+		}
+	}
+
+	// NOTE: `scala-tree-parser [flags] <file or directory>...` predates the subcommands, and
+	// is kept as a shorthand for parse.
+	if _, err := os.Stat(name); err == nil || strings.HasPrefix(name, "-") {
+		runParseCommand(os.Args[1:])
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(exitUsage)
+}
+
+// parseFlags are the flags shared by every command that parses files.
+type parseFlags struct {
+	*flag.FlagSet
+
+	config               string
+	dialect              Dialect
+	symbolDepth          SymbolDepth
+	includeSymbols       string
+	excludeSymbols       string
+	includeImports       string
+	excludeImports       string
+	ignoreImportPrefixes stringList
+	firstPartyPrefixes   stringList
+	artifacts            string
+	artifactsRead        *ArtifactIndex
+	bloop                string
+	semanticDBTargetRoot string
+	semanticDBSourceRoot string
+	filename             string
+	gitDiff              string
+	printSchema          bool
+	argsOptional         bool
+	include              stringList
+	exclude              stringList
+	noIgnore             bool
+	followSymlinks       bool
+	metrics              bool
+	importsOnly          bool
+	stats                bool
+	failOn               failOn
+	errorFormat          string
+	quiet                bool
+	verbose              bool
+	debug                bool
+	noProgress           bool
+	jobs                 int
+	maxMemory            byteSize
+	maxFileSize          byteSize
+	encoding             Encoding
+	cacheDir             string
+	remoteCache          string
+	grammar              string
+	encodeOperators      bool
+	backticks            BacktickMode
+	keepRootPrefix       bool
+	resolveImports       bool
+
+	parseStats *Stats
+	// parseFlagNames are the names of the flags above, as opposed to those of
+	// the command.
+	parseFlagNames map[string]bool
+	// cache is the cache of results selected by --cache-dir and --remote-cache,
+	// opened on first use, and cacheSettings what its keys depend on besides files.
+	cache         resultCache
+	cacheSettings string
+	// git is the repository --git-diff refers to, opened on first use, and
+	// gitChanged the files changed since its ref. Files are read as of gitRevision
+	// instead of from the working tree if it is set.
+	git         *gitRepo
+	gitChanged  map[string]bool
+	gitRevision string
+}
+
+func newParseFlags(name, usage string) *parseFlags {
+	f := &parseFlags{
+		FlagSet:     flag.NewFlagSet(name, flag.ContinueOnError),
+		dialect:     DialectAuto,
+		symbolDepth: SymbolDepthMembers,
+		failOn:      failOnSyntaxErrors,
+
+		maxFileSize: defaultMaxFileSize,
+	}
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "usage: scala-tree-parser %s %s\n\n", name, usage)
+		f.PrintDefaults()
+	}
+
+	f.StringVar(&f.config, "config", "", "config file to read default flags from (default: nearest "+configFileName+")")
+	f.Var(&f.dialect, "dialect", "scala2, scala3 or auto")
+	f.Var(&f.symbolDepth, "symbol-depth", "extract top-level definitions only (top), also the members of objects (members), or every nested definition (all)")
+	f.StringVar(&f.includeSymbols, "include-symbols", "", "only report symbols matching this regexp")
+	f.StringVar(&f.excludeSymbols, "exclude-symbols", "", "drop symbols matching this regexp")
+	f.StringVar(&f.includeImports, "include-imports", "", "only report imports matching this regexp")
+	f.StringVar(&f.excludeImports, "exclude-imports", "", "drop imports matching this regexp")
+	f.Var(&f.ignoreImportPrefixes, "ignore-import-prefix", "drop imports with this prefix (repeatable)")
+	f.Var(&f.firstPartyPrefixes, "first-party-prefix", "classify imports with this prefix, e.g. com.mycompany., as first-party (repeatable)")
+	f.StringVar(&f.artifacts, "artifacts", "", "resolve third-party imports to jars or Bazel labels with this index, written by index-jars")
+	f.StringVar(&f.bloop, "bloop", "", "attribute each file to a module of the build in this directory, read from its .bloop project files")
+	f.StringVar(&f.semanticDBTargetRoot, "semanticdb-targetroot", "", "read the SemanticDB files the compiler wrote to this directory, to report exactly what each file references")
+	f.StringVar(&f.semanticDBSourceRoot, "semanticdb-sourceroot", ".", "the compiler's -sourceroot, which SemanticDB paths are relative to")
+	f.StringVar(&f.filename, "filename", "<stdin>", "name to report, and detect the file type from, when reading - from stdin")
+	f.StringVar(&f.gitDiff, "git-diff", "", "only parse the files changed since this git ref, e.g. origin/main, including untracked ones")
+	f.Var(&f.include, "include", "glob of files to parse in directories, e.g. '**/*.scala' (repeatable, comma-separated)")
+	f.Var(&f.exclude, "exclude", "glob of files and directories to skip, e.g. '**/target/**' (repeatable, comma-separated)")
+	f.BoolVar(&f.noIgnore, "no-ignore", false, "parse files in directories even if .gitignore or .bazelignore excludes them")
+	f.BoolVar(&f.followSymlinks, "follow-symlinks", false, "descend into symlinked directories, each real directory at most once")
+	f.BoolVar(&f.metrics, "metrics", false, "report line counts, definition counts and nesting depth")
+	f.BoolVar(&f.importsOnly, "imports-only", false, "extract only the package and imports of each file, skipping symbols")
+	f.BoolVar(&f.stats, "stats", false, "report parse times and throughput to stderr")
+	f.StringVar(&f.errorFormat, "error-format", "default", "format of errors on stderr: default, or gcc for file:line:col: message")
+	f.BoolVar(&f.quiet, "q", false, "log nothing but errors")
+	f.BoolVar(&f.verbose, "v", false, "log each file as it is parsed")
+	f.BoolVar(&f.debug, "vv", false, "log the nodes seen during extraction")
+	f.BoolVar(&f.noProgress, "no-progress", false, "never show the progress line, which is otherwise shown on a terminal")
+	f.IntVar(&f.jobs, "jobs", runtime.NumCPU(), "number of files to parse at once")
+	f.Var(&f.maxMemory, "max-memory", "limit on the size of the files being parsed at once, e.g. 512M (default unlimited)")
+	f.Var(&f.encoding, "encoding", "encoding of files without a byte order mark: utf-8, or latin1 to transcode files that are not valid UTF-8")
+	f.Var(&f.maxFileSize, "max-file-size", "skip files larger than this, e.g. 10M, or 0 for no limit")
+	f.Var(&f.failOn, "fail-on", "exit with code 2 on syntax-errors, unknown-nodes (and syntax errors) or none")
+	f.StringVar(&f.cacheDir, "cache-dir", "", "cache the results of files in this directory, keyed by their contents, so unchanged files are not parsed again")
+	f.StringVar(&f.remoteCache, "remote-cache", "", "also cache results on this server, shared between machines: an http(s):// base URL for GET and PUT, or redis://[:password@]host[:port][/db]")
+	f.BoolVar(&f.encodeOperators, "encode-operators", false, "percent-encode operator names, e.g. ++ as %2B%2B, for consumers that cannot handle them")
+	f.Var(&f.backticks, "backticks", "strip the backticks from quoted names (strip), or keep them where a name requires them (keep)")
+	f.BoolVar(&f.keepRootPrefix, "keep-root-prefix", false, "keep the _root_. marker on imports such as _root_.com.foo.Bar instead of dropping it")
+	f.BoolVar(&f.resolveImports, "resolve-imports", false, "expand relative imports into fully-qualified names using the file's package and preceding imports, reporting ambiguous ones")
+	f.StringVar(&f.grammar, "grammar", "", "parse with the tree-sitter-scala grammar compiled to this shared library, e.g. a .so or .dylib, instead of the bundled one; .wasm grammars are not supported (default $"+grammarEnv+")")
+
+	f.parseFlagNames = make(map[string]bool)
+	f.VisitAll(func(fl *flag.Flag) {
+		f.parseFlagNames[fl.Name] = true
+	})
+	return f
+}
+
+// parse parses args, applies the config file, and exits with the usage if no
+// files were given.
+func (f *parseFlags) parse(args []string) {
+	parseCommandFlags(f.FlagSet, args)
+	// NOTE: set the level before loading the config too, so -q silences its warnings.
+	f.setLogLevel()
+	loadConfig(f.FlagSet, f.config)
+	f.setLogLevel()
+
+	if f.errorFormat != "default" && f.errorFormat != "gcc" {
+		fmt.Fprintf(os.Stderr, "invalid --error-format %q, expected default or gcc\n", f.errorFormat)
+		os.Exit(exitUsage)
+	}
+
+	if f.grammar != "" {
+		if err := loadGrammar(f.grammar); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	if f.NArg() == 0 && !f.printSchema && !f.argsOptional {
+		f.Usage()
+		os.Exit(exitUsage)
+	}
+}
+
+// files returns the files named on the command line, with each directory
+// replaced by the files beneath it selected by --include and --exclude.
+func (f *parseFlags) files() []string {
+	return f.expand(f.Args())
+}
+
+func (f *parseFlags) expand(args []string) []string {
+	opts := findOptions{
+		Include:        splitPatterns(f.include),
+		Exclude:        splitPatterns(f.exclude),
+		NoIgnore:       f.noIgnore,
+		FollowSymlinks: f.followSymlinks,
+	}
+
+	var files []string
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err != nil || !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		found, err := findSourceFiles(arg, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInternal)
+		}
+		files = append(files, found...)
+	}
+
+	if f.gitDiff == "" {
+		return files
+	}
+	changed := f.changedSince()
+	selected := files[:0]
+	for _, file := range files {
+		if changed[absPath(file)] {
+			selected = append(selected, file)
+		}
+	}
+	return selected
+}
+
+// changedSince returns the absolute paths of the files present in the working
+// tree that changed since the ref named by --git-diff, read on first use.
+func (f *parseFlags) changedSince() map[string]bool {
+	if f.gitChanged == nil {
+		changed, err := f.gitRepo().changedFiles(f.gitDiff, "d", true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--git-diff: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		f.gitChanged = changed
+	}
+	return f.gitChanged
+}
+
+func (f *parseFlags) gitRepo() *gitRepo {
+	if f.git == nil {
+		repo, err := openGitRepo()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--git-diff: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		f.git = repo
+	}
+	return f.git
+}
+
+func splitPatterns(values []string) []string {
+	var patterns []string
+	for _, value := range values {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+func (f *parseFlags) setLogLevel() {
+	switch {
+	case f.debug:
+		cliLogLevel = LogDebug
+	case f.verbose:
+		cliLogLevel = LogVerbose
+	case f.quiet:
+		cliLogLevel = LogQuiet
+	}
+}
+
+func (f *parseFlags) options() []Option {
+	opts := []Option{
+		WithLogLevel(cliLogLevel),
+		WithDialect(f.dialect),
+		WithSymbolDepth(f.symbolDepth),
+		WithFirstPartyPrefixes(f.firstPartyPrefixes...),
+		WithEncoding(f.encoding),
+		WithBackticks(f.backticks),
+		WithFilters(Filters{
+			IncludeSymbols:       compileFlagRegexp("include-symbols", f.includeSymbols),
+			ExcludeSymbols:       compileFlagRegexp("exclude-symbols", f.excludeSymbols),
+			IncludeImports:       compileFlagRegexp("include-imports", f.includeImports),
+			ExcludeImports:       compileFlagRegexp("exclude-imports", f.excludeImports),
+			IgnoreImportPrefixes: f.ignoreImportPrefixes,
+		}),
+	}
+	if f.metrics {
+		opts = append(opts, WithMetrics())
+	}
+	if f.encodeOperators {
+		opts = append(opts, WithEncodedOperators())
+	}
+	if f.keepRootPrefix {
+		opts = append(opts, WithRootPrefix())
+	}
+	if f.resolveImports {
+		opts = append(opts, WithRelativeImports())
+	}
+	if idx := f.artifactIndex(); idx != nil {
+		opts = append(opts, WithArtifactIndex(idx))
+	}
+	if f.bloop != "" {
+		projects, err := ReadBloopProjects(f.bloop)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--bloop: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		opts = append(opts, WithBloopProjects(projects))
+	}
+	if f.semanticDBTargetRoot != "" {
+		opts = append(opts, WithSemanticDB(f.semanticDBTargetRoot, f.semanticDBSourceRoot))
+	}
+	if f.importsOnly {
+		opts = append(opts, WithImportsOnly())
+	}
+	if f.stats {
+		f.parseStats = NewStats()
+		opts = append(opts, WithStats(f.parseStats))
+	}
+	return opts
+}
+
+// artifactIndex returns the index named by --artifacts, read on first use, or
+// nil if there is none.
+func (f *parseFlags) artifactIndex() *ArtifactIndex {
+	if f.artifacts != "" && f.artifactsRead == nil {
+		idx, err := ReadArtifactIndex(f.artifacts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--artifacts: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		f.artifactsRead = idx
+	}
+	return f.artifactsRead
+}
+
+// readFile returns the name to report for filePath and its contents, reading
+// stdin if filePath is "-", and its contents at gitRevision if that is set.
+func (f *parseFlags) readFile(filePath string) (string, []byte) {
+	filePath, sourceCode, err := f.readSource(filePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInternal)
+	}
+	return filePath, sourceCode
+}
+
+// readSource is readFile, returning the error instead of exiting.
+func (f *parseFlags) readSource(filePath string) (string, []byte, error) {
+	var sourceCode []byte
+	var err error
+	if filePath == "-" {
+		filePath = f.filename
+		sourceCode, err = io.ReadAll(os.Stdin)
+	} else if f.gitRevision != "" {
+		sourceCode, err = f.gitRepo().show(f.gitRevision, filePath)
+	} else {
+		sourceCode, err = os.ReadFile(filePath)
+	}
+	return filePath, sourceCode, err
+}
+
+// formatErrors formats the errors found parsing a file for stderr, as selected
+// by --error-format.
+func (f *parseFlags) formatErrors(result *ParseResult, filePath string, errs []error) []string {
+	display := filepath.ToSlash(filePath)
+	var lines []string
+	if f.errorFormat != "gcc" {
+		for _, err := range errs {
+			lines = append(lines, fmt.Sprintf("%s: %v", display, err))
+		}
+		return lines
+	}
+
+	positioned := positionedErrors(result, errs)
+	if len(positioned) == 0 {
+		for _, err := range errs {
+			lines = append(lines, fmt.Sprintf("%s:1:1: %v", display, err))
+		}
+		return lines
+	}
+	for _, err := range positioned {
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", display, err.Line, err.Column, err.Message))
+	}
+	return lines
+}
+
+func runParseCommand(args []string) {
+	f := newParseFlags("parse", "[flags] <file or directory>...")
+	asJSON := f.Bool("json", false, "print each result as a line of JSON")
+	f.BoolVar(&f.printSchema, "print-schema", false, "print the JSON Schema of the results and exit")
+	f.parse(args)
+
+	if f.printSchema {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(ParseResultSchema()); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	printResult := func(result any) {
+		if *asJSON {
+			if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+				panic(err)
+			}
+			return
+		}
+		fmt.Printf("%+v\n", result)
+	}
+
+	sbtParser := NewSbtParser()
+	var files []string
+	for _, filePath := range f.files() {
+		if !isSbtBuildFile(filePath) && !(filePath == "-" && isSbtBuildFile(f.filename)) {
+			files = append(files, filePath)
+			continue
+		}
+
+		filePath, sourceCode := f.readFile(filePath)
+		build, errs := sbtParser.Parse(filePath, sourceCode)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filePath, err)
+		}
+		f.failOn.check(nil, errs)
+		printResult(*build)
+	}
+
+	if len(files) == 0 {
+		return
+	}
+	f.parseFiles(files, func(result *ParseResult) {
+		printResult(*result)
+	})
+}
+
+// printPerFile prints lines, prefixed by the file name unless a single file was
+// named on the command line.
+func printPerFile(f *parseFlags, file string, lines []string) {
+	single := f.NArg() == 1 && (file == f.Arg(0) || f.Arg(0) == "-")
+	for _, line := range lines {
+		if !single {
+			fmt.Printf("%s: %s\n", file, line)
+		} else {
+			fmt.Println(line)
+		}
+	}
+}
+
+func runImportsCommand(args []string) {
+	f := newParseFlags("imports", "[flags] <file or directory>...")
+	group := f.Bool("group", false, "print the class of each import, stdlib, first-party or third-party, grouping them by class")
+	f.parse(args)
+	f.parseFiles(f.files(), func(result *ParseResult) {
+		if !*group {
+			printPerFile(f, result.File, result.Imports)
+			return
+		}
+
+		var lines []string
+		for _, class := range []struct {
+			name    ImportClass
+			imports []string
+		}{
+			{StdlibImport, result.ImportGroups.Stdlib},
+			{FirstPartyImport, result.ImportGroups.FirstParty},
+			{ThirdPartyImport, result.ImportGroups.ThirdParty},
+		} {
+			for _, imp := range class.imports {
+				lines = append(lines, fmt.Sprintf("%s\t%s", class.name, imp))
+			}
+		}
+		printPerFile(f, result.File, lines)
+	})
+}
+
+func runSymbolsCommand(args []string) {
+	f := newParseFlags("symbols", "[flags] <file or directory>...")
+	format := f.String("format", "plain", "output format: plain, or ctags or etags for a tags file")
+	f.parse(args)
+
+	switch *format {
+	case "ctags", "etags":
+		var tags []tag
+		f.parseFiles(f.files(), func(result *ParseResult) {
+			tags = append(tags, readTags(result)...)
+		})
+		if *format == "ctags" {
+			writeCtags(os.Stdout, tags)
+		} else {
+			writeEtags(os.Stdout, tags)
+		}
+		return
+	case "plain":
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --format %q, expected plain, ctags or etags\n", *format)
+		os.Exit(exitUsage)
+	}
+
+	f.parseFiles(f.files(), func(result *ParseResult) {
+		symbols := make([]string, 0, len(result.Symbols))
+		for _, symbol := range result.Symbols {
+			symbols = append(symbols, qualify(result.Package, symbol))
+		}
+		printPerFile(f, result.File, symbols)
+	})
+}
+
+// parseAll parses every file named on the command line, returning the results.
+func (f *parseFlags) parseAll() []*ParseResult {
+	var results []*ParseResult
+	f.parseFiles(f.files(), func(result *ParseResult) {
+		results = append(results, result)
+	})
+	return results
+}
+
+func runPackagesCommand(args []string) {
+	f := newParseFlags("packages", "[flags] <file or directory>...")
+	f.parse(args)
+
+	for _, summary := range AggregatePackages(f.parseAll()) {
+		fmt.Printf("%+v\n", summary)
+	}
+}
+
+func runIndexCommand(args []string) {
+	if len(args) > 0 && args[0] == "merge" {
+		runIndexMergeCommand(args[1:])
+		return
+	}
+
+	f := newParseFlags("index", "[flags] <file or directory>...\n       scala-tree-parser index merge [flags] <shard>...")
+	var onlyShard shard
+	f.Var(&onlyShard, "shard", "only index the files of this shard, e.g. 1/4 for the first of four, whose output index merge combines")
+	asJSON := f.Bool("json", false, "print the API surface as JSON, for use with diff")
+	asLSIF := f.Bool("lsif", false, "print an LSIF dump of definitions and import references, for code navigation tools")
+
+	failOnDuplicates := f.Bool("fail-on-duplicates", false, "exit with code 2 if a symbol is defined in more than one file")
+	f.parse(args)
+
+	var results []*ParseResult
+	f.parseFiles(onlyShard.files(f.files()), func(result *ParseResult) {
+		results = append(results, result)
+	})
+	for _, duplicate := range FindDuplicateDefinitions(results) {
+		logf(LogDefault, "duplicate: %s is defined in %s\n", duplicate.Symbol, strings.Join(duplicate.Definitions, ", "))
+		if *failOnDuplicates {
+			setExitCode(exitParseFailure)
+		}
+	}
+
+	if *asLSIF {
+		if err := WriteLSIF(os.Stdout, results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInternal)
+		}
+		return
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		surface := APISurfaceFile{SchemaVersion: SchemaVersion, Symbols: APISurface(results)}
+		if err := encoder.Encode(surface); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	index := NewIndex()
+	for _, result := range results {
+		index.Add(result)
+	}
+	if err := writeIndex(os.Stdout, index); err != nil {
+		panic(err)
+	}
+}
+
+// importedFiles returns the files defining what imp imports, according to index.
+func importedFiles(index *Index, imp string) []string {
+	if pkg, ok := strings.CutSuffix(imp, "._"); ok {
+		return membersFiles(index, pkg)
+	} else if pkg, ok := strings.CutSuffix(imp, ".*"); ok {
+		return membersFiles(index, pkg)
+	}
+
+	// A member of an object is defined by the object's file.
+	for name := imp; name != ""; {
+		if files := index.Files(name); len(files) > 0 {
+			return files
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return nil
+}
+
+func membersFiles(index *Index, owner string) []string {
+	var files []string
+	for _, member := range index.Members(owner) {
+		for _, file := range index.Files(member) {
+			if !containsString(files, file) {
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+func runGraphCommand(args []string) {
+	f := newParseFlags("graph", "[flags] <file or directory>...")
+	f.parse(args)
+
+	index := NewIndex()
+	var results []*ParseResult
+	f.parseFiles(f.files(), func(result *ParseResult) {
+		index.Add(result)
+		results = append(results, result)
+	})
+
+	edges := make(map[string]bool)
+	for _, result := range results {
+		for _, imp := range result.Imports {
+			for _, file := range importedFiles(index, imp) {
+				if file != result.File {
+					edges[fmt.Sprintf("  %q -> %q;", result.File, file)] = true
+				}
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(edges))
+	for edge := range edges {
+		lines = append(lines, edge)
+	}
+	sort.Strings(lines)
+
+	fmt.Println("digraph {")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	fmt.Println("}")
+}
+
+func runWildcardEdgesCommand(args []string) {
+	f := newParseFlags("wildcard-edges", "[flags] <file or directory>...")
+	f.parse(args)
+
+	index := NewIndex()
+	results := f.parseAll()
+	for _, result := range results {
+		index.Add(result)
+	}
+
+	for _, edge := range FindWildcardEdges(results, index) {
+		fmt.Printf("%s: %s via %s\n", edge.File, edge.Package, strings.Join(edge.Imports, ", "))
+	}
+}
+
+func runAdviseSplitCommand(args []string) {
+	f := newParseFlags("advise-split", "[flags] <file or directory>...")
+	f.parse(args)
+	files := f.files()
+
+	// NOTE: SamePackageRefs needs every file in the index first, so parse twice.
+	index := NewIndex()
+	f.parseFiles(files, index.Add)
+
+	var results []*ParseResult
+	f.parseFiles(files, func(result *ParseResult) {
+		results = append(results, result)
+	}, WithIndex(index))
+
+	for _, advice := range AdviseSplit(results, index) {
+		fmt.Printf("package %s: %d targets\n", advice.Package, len(advice.Groups))
+		for i, group := range advice.Groups {
+			fmt.Printf("  target %d: %s\n", i+1, strings.Join(group, " "))
+		}
+	}
+}
+
+func runDeadCodeCommand(args []string) {
+	f := newParseFlags("dead-code", "[flags] <file or directory>...")
+	f.parse(args)
+	files := f.files()
+
+	index := NewIndex()
+	f.parseFiles(files, index.Add)
+
+	var results []*ParseResult
+	f.parseFiles(files, func(result *ParseResult) {
+		results = append(results, result)
+	}, WithIndex(index))
+
+	for _, candidate := range FindDeadCode(results) {
+		fmt.Printf("%s:%d: %s\n", candidate.File, candidate.Line, candidate.Symbol)
+	}
+}
+
+func runDeprecatedCommand(args []string) {
+	f := newParseFlags("deprecated", "[flags] <file or directory>...")
+	f.parse(args)
+	files := f.files()
+
+	// NOTE: SamePackageRefs needs every file in the index first, so parse twice.
+	index := NewIndex()
+	f.parseFiles(files, index.Add)
+	var results []*ParseResult
+	f.parseFiles(files, func(result *ParseResult) {
+		results = append(results, result)
+	}, WithIndex(index))
+
+	for _, usage := range FindDeprecatedUsages(results) {
+		line := fmt.Sprintf("%s: uses %s, deprecated", usage.File, usage.Symbol)
+		if usage.Deprecated != usage.Symbol {
+			line += " with " + usage.Deprecated
+		}
+		if usage.Deprecation.Since != "" {
+			line += " since " + usage.Deprecation.Since
+		}
+		if usage.Deprecation.Message != "" {
+			line += ": " + usage.Deprecation.Message
+		}
+		fmt.Println(line)
+	}
+}
+
+// runPrivateLeaksCommand implements `private-leaks`, which fails with
+// exitParseFailure if any file imports a qualified private definition it cannot
+// see, as the compiler would.
+func runPrivateLeaksCommand(args []string) {
+	f := newParseFlags("private-leaks", "[flags] <file or directory>...")
+	f.parse(args)
+
+	for _, leak := range FindPrivateLeaks(f.parseAll()) {
+		fmt.Printf("%s: imports %s, but %s is %s[%s], defined at %s\n",
+			leak.File, leak.Import, leak.Symbol, leak.Modifier, leak.Scope[strings.LastIndex(leak.Scope, ".")+1:], leak.DefinedAt)
+		setExitCode(exitParseFailure)
+	}
+}
+
+// readRevision returns the API surface of a saved `index --json` file, or of the
+// files at path.
+func (f *parseFlags) readRevision(path string) []APISymbol {
+	if strings.HasSuffix(path, ".json") {
+		surface, err := readAPISurface(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInternal)
+		}
+		return surface
+	}
+
+	var results []*ParseResult
+	f.parseFiles(f.expand([]string{path}), func(result *ParseResult) {
+		results = append(results, result)
+	})
+	return APISurface(results)
+}
+
+// readGitRevision returns the API surface of the files at path as of the ref
+// named by --git-diff, of those changed or deleted since, read from git.
+func (f *parseFlags) readGitRevision(path string) []APISymbol {
+	changed, err := f.gitRepo().changedFiles(f.gitDiff, "DMT", false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--git-diff: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	includes, excludes := splitPatterns(f.include), splitPatterns(f.exclude)
+	if len(includes) == 0 {
+		includes = defaultIncludes
+	}
+
+	var files []string
+	for file := range changed {
+		rel, err := filepath.Rel(absPath(path), file)
+		if rel = filepath.ToSlash(rel); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		if rel == "." || matchAnyGlob(includes, rel) && !matchAnyGlob(excludes, rel) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	f.gitRevision = f.gitDiff
+	defer func() { f.gitRevision = "" }()
+	var results []*ParseResult
+	f.parseFiles(files, func(result *ParseResult) {
+		results = append(results, result)
+	})
+	return APISurface(results)
+}
+
+func runDiffCommand(args []string) {
+	f := newParseFlags("diff", "[flags] <old file, directory or .json> <new file, directory or .json>\n       scala-tree-parser diff --git-diff=<ref> [flags] <file or directory>")
+	f.parse(args)
+
+	var before, after []APISymbol
+	switch {
+	case f.gitDiff != "" && f.NArg() == 1:
+		// NOTE: the old revision is the files changed since the ref as they were at
+		// it, and the new one, as --git-diff selects, those files as they are now.
+		before, after = f.readGitRevision(f.Arg(0)), f.readRevision(f.Arg(0))
+	case f.NArg() == 2:
+		before, after = f.readRevision(f.Arg(0)), f.readRevision(f.Arg(1))
+	default:
+		f.Usage()
+		os.Exit(exitUsage)
+	}
+
+	for _, change := range DiffAPI(before, after) {
+		switch {
+		case change.Old == "":
+			fmt.Printf("+ %s: %s\n", change.Symbol, change.New)
+		case change.New == "":
+			fmt.Printf("- %s: %s\n", change.Symbol, change.Old)
+		default:
+			fmt.Printf("~ %s: %s -> %s\n", change.Symbol, change.Old, change.New)
+		}
+	}
+}
+
+func runVersionCommand(args []string) {
+	v := version
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version
+	}
+	fmt.Printf("scala-tree-parser %s\n", v)
+	fmt.Printf("tree-sitter-scala grammar: %s\n", describeGrammar())
+}
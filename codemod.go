@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// parseForCodemod parses sourceCode, wrapping scripts as ParseBytes does. The
+// offsets of nodes in the returned tree, over parsed, are offset bytes past the
+// same positions in sourceCode.
+func parseForCodemod(filePath string, sourceCode []byte) (root *sitter.Node, parsed []byte, offset int, err error) {
+	parsed = sourceCode
+	if isScriptFile(filePath) {
+		parsed, offset = wrapScript(sourceCode), len(scriptPrefix)
+	}
+	root, err = sitter.ParseCtx(context.Background(), grammarInput(parsed), ScalaLang)
+	return root, parsed, offset, err
+}
+
+// textEdit replaces the bytes [start, end) of a file with text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits returns source with edits applied. Edits must not overlap.
+func applyEdits(source []byte, edits []textEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		out.Write(source[last:edit.start])
+		out.WriteString(edit.text)
+		last = edit.end
+	}
+	out.Write(source[last:])
+	return out.Bytes()
+}
+
+// codemodFlags are the flags shared by the commands that rewrite files.
+type codemodFlags struct {
+	dryRun bool
+	check  bool
+	// create lets files that do not exist yet be written, rewriting them from empty.
+	create bool
+}
+
+func addCodemodFlags(f *parseFlags) *codemodFlags {
+	c := &codemodFlags{}
+	f.BoolVar(&c.dryRun, "dry-run", false, "print unified diffs instead of rewriting files")
+	f.BoolVar(&c.check, "check", false, "rewrite nothing, but list the files that would change and exit with code 2 if there are any")
+	return c
+}
+
+// rewriteFiles applies rewrite to the contents of each of files. Changed files
+// are rewritten in place, or with --dry-run printed as unified diffs. With --check
+// nothing is rewritten: changed files are listed, or diffed with --dry-run, and
+// the command fails.
+func (c *codemodFlags) rewriteFiles(files []string, rewrite func(filePath string, sourceCode []byte) ([]byte, error)) {
+	for _, filePath := range files {
+		if err := c.rewriteFile(filePath, rewrite); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filePath, err)
+			setExitCode(exitInternal)
+		}
+	}
+}
+
+func (c *codemodFlags) rewriteFile(filePath string, rewrite func(filePath string, sourceCode []byte) ([]byte, error)) error {
+	perm := fs.FileMode(0o644)
+	original, err := os.ReadFile(filePath)
+	if err != nil && !(c.create && errors.Is(err, fs.ErrNotExist)) {
+		return err
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+	rewritten, err := rewrite(filePath, original)
+	if err != nil || bytes.Equal(original, rewritten) {
+		return err
+	}
+
+	if c.check {
+		setExitCode(exitParseFailure)
+	}
+	switch {
+	case c.dryRun:
+		fmt.Print(unifiedDiff(filePath, original, rewritten))
+	case c.check:
+		fmt.Println(filePath)
+	default:
+		return os.WriteFile(filePath, rewritten, perm)
+	}
+	return nil
+}
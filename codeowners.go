@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// codeOwnersLocations are where GitHub and GitLab look for a CODEOWNERS file,
+// relative to the repository root, in order.
+var codeOwnersLocations = []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeOwnersRule is a line of a CODEOWNERS file: a pattern, as in .gitignore,
+// and the owners of the files it matches, which may be none.
+type codeOwnersRule struct {
+	pattern  string
+	anchored bool
+	dirOnly  bool
+	owners   []string
+}
+
+// CodeOwners are the rules of a CODEOWNERS file, assigning owners to the files
+// of a repository. As in git hosts, the last matching rule wins.
+type CodeOwners struct {
+	// root is the absolute path of the repository the rules are relative to.
+	root  string
+	rules []codeOwnersRule
+}
+
+// findCodeOwnersFile returns the path of the CODEOWNERS file of the repository
+// at root, or "" if there is none.
+func findCodeOwnersFile(root string) string {
+	for _, location := range codeOwnersLocations {
+		if file := filepath.Join(root, filepath.FromSlash(location)); fileExists(file) {
+			return file
+		}
+	}
+	return ""
+}
+
+// ReadCodeOwners reads the CODEOWNERS file at file, whose patterns are relative
+// to the repository at root.
+func ReadCodeOwners(file, root string) (*CodeOwners, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	owners := &CodeOwners{root: absPath(root)}
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		// NOTE: GitLab's `[Section]` headers only group rules.
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		rule := codeOwnersRule{pattern: strings.TrimPrefix(fields[0], `\`), owners: fields[1:]}
+		if strings.HasSuffix(rule.pattern, "/") {
+			rule.dirOnly = true
+			rule.pattern = strings.TrimRight(rule.pattern, "/")
+		}
+		// NOTE: a slash anywhere but the end anchors the pattern to the root.
+		if strings.Contains(rule.pattern, "/") {
+			rule.anchored = true
+			rule.pattern = strings.TrimPrefix(rule.pattern, "/")
+		}
+		if rule.pattern == "" {
+			return nil, fmt.Errorf("%s:%d: expected a pattern", file, lineNumber)
+		}
+		owners.rules = append(owners.rules, rule)
+	}
+	return owners, scanner.Err()
+}
+
+// Owners returns the owners of file, or nil if it has none.
+func (c *CodeOwners) Owners(file string) []string {
+	rel, err := filepath.Rel(c.root, absPath(file))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	var owners []string
+	for _, rule := range c.rules {
+		pattern := rule.pattern
+		if !rule.anchored {
+			pattern = "**/" + pattern
+		}
+		// NOTE: a pattern matching a directory matches everything beneath it.
+		matched := !rule.dirOnly && matchGlob(pattern, rel)
+		for dir := path.Dir(rel); !matched && dir != "."; dir = path.Dir(dir) {
+			matched = matchGlob(pattern, dir)
+		}
+		if matched {
+			owners = rule.owners
+		}
+	}
+	return owners
+}
+
+// runOwnersCommand implements `owners`, which joins a CODEOWNERS file against
+// the symbol index: it prints the owners of each --symbol, the symbols owned by
+// each --owner, or, given neither, every symbol with its owners.
+func runOwnersCommand(args []string) {
+	f := newParseFlags("owners", "[flags] <file or directory>...")
+	repoRoot := f.String("repo-root", ".", "the repository root, which CODEOWNERS patterns are relative to")
+	codeOwnersFile := f.String("codeowners", "", "the CODEOWNERS file (default: CODEOWNERS, .github/CODEOWNERS, .gitlab/CODEOWNERS or docs/CODEOWNERS in --repo-root)")
+	var symbols, owners stringList
+	f.Var(&symbols, "symbol", "print the owners of this fully-qualified symbol (repeatable)")
+	f.Var(&owners, "owner", "print the symbols owned by this owner, e.g. @org/payments (repeatable)")
+	f.parse(args)
+
+	if *codeOwnersFile == "" {
+		if *codeOwnersFile = findCodeOwnersFile(*repoRoot); *codeOwnersFile == "" {
+			fmt.Fprintf(os.Stderr, "no CODEOWNERS file in %s\n", *repoRoot)
+			os.Exit(exitUsage)
+		}
+	}
+	codeOwners, err := ReadCodeOwners(*codeOwnersFile, *repoRoot)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+
+	index := NewIndex()
+	f.parseFiles(f.files(), index.Add)
+
+	// symbolOwners returns the owners of the files defining symbol, or of the
+	// object it is a member of.
+	symbolOwners := func(symbol string) []string {
+		var all []string
+		for _, file := range importedFiles(index, symbol) {
+			all = append(all, codeOwners.Owners(file)...)
+		}
+		return sortedUnique(all)
+	}
+
+	switch {
+	case len(symbols) > 0:
+		for _, symbol := range symbols {
+			if !index.Defines(symbol) {
+				fmt.Fprintf(os.Stderr, "%s: not defined in the files parsed\n", symbol)
+				setExitCode(exitUsage)
+				continue
+			}
+			fmt.Printf("%s\t%s\n", symbol, strings.Join(symbolOwners(symbol), " "))
+		}
+	case len(owners) > 0:
+		for _, symbol := range index.Symbols() {
+			for _, owner := range symbolOwners(symbol) {
+				if containsString(owners, owner) {
+					fmt.Println(symbol)
+					break
+				}
+			}
+		}
+	default:
+		for _, symbol := range index.Symbols() {
+			fmt.Printf("%s\t%s\n", symbol, strings.Join(symbolOwners(symbol), " "))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configFileName = ".scala-tree-parser.yaml"
+
+// workspaceMarkers are the files marking the root of a workspace, above which
+// no config file is looked for.
+var workspaceMarkers = []string{"WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel", ".git"}
+
+// findConfigFile returns the path of the config file in dir or its nearest
+// ancestor, stopping at the workspace root, or "" if there is none.
+func findConfigFile(dir string) string {
+	for {
+		path := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		for _, marker := range workspaceMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return ""
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// configFile is the content of a config file: a mapping of flag names to a
+// value, or a list of values for repeatable flags, e.g.
+//
+//	dialect: scala3
+//	exclude-symbols: '.*Spec$'
+//	ignore-import-prefix:
+//	  - scala.
+//	  - java.
+type configFile struct {
+	Settings map[string]configValues `yaml:",inline"`
+}
+
+// configValues are the values of one setting, given as a scalar or a list of
+// scalars.
+type configValues []string
+
+func (v *configValues) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			*v = nil
+			return nil
+		}
+		*v = configValues{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+		*v = values
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a value or a list of values", node.Line)
+}
+
+// readConfigFile reads the config file at path, returning the values of each
+// setting it names.
+func readConfigFile(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file configFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	config := make(map[string][]string, len(file.Settings))
+	for key, values := range file.Settings {
+		config[key] = values
+	}
+	return config, nil
+}
+
+// applyConfig sets each flag named in config that was not given on the command
+// line, so command-line flags take precedence over the config file. Keys that
+// are not flags of this command are reported and ignored.
+func applyConfig(flags *flag.FlagSet, config map[string][]string, path string) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for key, values := range config {
+		if flags.Lookup(key) == nil {
+			logf(LogDefault, "%s: ignoring unknown setting %q\n", path, key)
+			continue
+		}
+		if explicit[key] {
+			continue
+		}
+
+		for _, value := range values {
+			if err := flags.Set(key, value); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadConfig applies the config file at path, or the one found from the working
+// directory if path is empty, to flags.
+func loadConfig(flags *flag.FlagSet, path string) {
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return
+		}
+		if path = findConfigFile(wd); path == "" {
+			return
+		}
+	}
+
+	config, err := readConfigFile(path)
+	if err == nil {
+		err = applyConfig(flags, config, path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// readSecondaryConstructors returns the public `def this(...)` constructors of a
+// class whose members aren't otherwise extracted.
+func readSecondaryConstructors(node *sitter.Node, sourceCode []byte, class string) []Symbol {
+	symbols := make([]Symbol, 0)
+
+	body := node.ChildByFieldName("body")
+	if body == nil {
+		return symbols
+	}
+
+	for i := 0; i < int(body.NamedChildCount()); i++ {
+		child := body.NamedChild(i)
+		if child.Type() != "function_definition" || hasAccessModifier(child) {
+			continue
+		}
+
+		if name := child.ChildByFieldName("name"); name != nil && name.Content(sourceCode) == "this" {
+			symbols = append(symbols, newSymbol(child, sourceCode, class+".this"))
+		}
+	}
+
+	return symbols
+}
+
+// markCompanionApplies flags the `apply` methods of companion objects as
+// constructors, since they're how callers usually create instances.
+func markCompanionApplies(symbols []Symbol) {
+	classes := make(map[string]bool)
+	for _, symbol := range symbols {
+		if symbol.Kind == "class" || symbol.Kind == "trait" {
+			classes[symbol.Name] = true
+		}
+	}
+
+	for i, symbol := range symbols {
+		owner, ok := strings.CutSuffix(symbol.Name, ".apply")
+		if ok && symbol.Kind == "def" && classes[owner] {
+			symbols[i].Constructor = true
+		}
+	}
+}
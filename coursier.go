@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Coursier resolves dependency coordinates to the jars providing them, by
+// running the coursier launcher or, offline, by finding them in its cache.
+type Coursier struct {
+	// Command is the coursier launcher, usually `cs`. If empty, jars are only
+	// looked up in the cache.
+	Command string
+	// CacheDir is coursier's cache; see defaultCoursierCache.
+	CacheDir string
+}
+
+// defaultCoursierCache returns the cache coursier uses by default: $COURSIER_CACHE,
+// or the coursier directory of the user's cache directory.
+func defaultCoursierCache() string {
+	if cache := os.Getenv("COURSIER_CACHE"); cache != "" {
+		return cache
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "coursier")
+}
+
+// scalaBinaryVersion returns the suffix of the artifacts cross-built for
+// scalaVersion, e.g. 2.13 for 2.13.12 and 3 for 3.3.1.
+func scalaBinaryVersion(scalaVersion string) string {
+	if major, _, _ := strings.Cut(scalaVersion, "."); major == "3" {
+		return major
+	}
+	parts := strings.SplitN(scalaVersion, ".", 3)
+	if len(parts) < 2 {
+		return scalaVersion
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// artifactName returns the name of dep's artifact, with the Scala binary version
+// suffix of cross-versioned dependencies.
+func artifactName(dep Dependency, scalaVersion string) string {
+	if dep.CrossVersion {
+		return dep.Name + "_" + scalaBinaryVersion(scalaVersion)
+	}
+	return dep.Name
+}
+
+// Fetch returns the jar of dep itself, for a build on scalaVersion. Transitive
+// dependencies are not fetched, since only the direct dependencies of a target
+// may provide its imports.
+func (c Coursier) Fetch(dep Dependency, scalaVersion string) (string, error) {
+	if jar, ok := c.cached(dep, scalaVersion); ok {
+		return jar, nil
+	}
+	if c.Command == "" {
+		return "", fmt.Errorf("%s is not in the coursier cache %s", dep, c.CacheDir)
+	}
+
+	coordinates := dep.Organization + ":" + artifactName(dep, scalaVersion) + ":" + dep.Version
+	if dep.Version == "" {
+		coordinates += "latest.release"
+	}
+	cmd := exec.Command(c.Command, "fetch", "--intransitive", coordinates)
+	if c.CacheDir != "" {
+		cmd.Env = append(os.Environ(), "COURSIER_CACHE="+c.CacheDir)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s fetch %s: %v: %s", c.Command, coordinates, err, strings.TrimSpace(stderr.String()))
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); strings.HasSuffix(line, ".jar") {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("%s fetch %s: no jar", c.Command, coordinates)
+}
+
+// cached returns the jar of dep in the cache, which mirrors each repository
+// under `v1/<protocol>/<host>/<path>`, e.g.
+// `v1/https/repo1.maven.org/maven2/org/typelevel/cats-core_2.13/2.10.0/cats-core_2.13-2.10.0.jar`.
+func (c Coursier) cached(dep Dependency, scalaVersion string) (string, bool) {
+	if c.CacheDir == "" || dep.Version == "" {
+		return "", false
+	}
+
+	name := artifactName(dep, scalaVersion)
+	jar := filepath.Join(strings.ReplaceAll(dep.Organization, ".", "/"), name, dep.Version, name+"-"+dep.Version+".jar")
+
+	// NOTE: repositories live at varying depths beneath their host, e.g.
+	// `maven2` or `content/repositories/releases`.
+	repository := filepath.Join(c.CacheDir, "v1", "*", "*")
+	for depth := 0; depth <= 3; depth++ {
+		matches, _ := filepath.Glob(filepath.Join(repository, jar))
+		if len(matches) > 0 {
+			return matches[0], true
+		}
+		repository = filepath.Join(repository, "*")
+	}
+	return "", false
+}
+
+// buildDependencies are the dependencies declared by a build definition or
+// scala-cli source, with the Scala version they are built for, if declared.
+type buildDependencies struct {
+	ScalaVersion string
+	Deps         []Dependency
+}
+
+// readBuildDependencies returns the library dependencies of every project of an
+// sbt build.
+func readBuildDependencies(build *SbtBuild) buildDependencies {
+	deps := buildDependencies{ScalaVersion: build.ScalaVersion}
+	for _, dep := range build.LibraryDependencies {
+		deps.Deps = append(deps.Deps, dep.Dependency)
+	}
+	for _, project := range build.Projects {
+		for _, dep := range project.LibraryDependencies {
+			deps.Deps = append(deps.Deps, dep.Dependency)
+		}
+	}
+	return deps
+}
+
+// runIndexDepsCommand implements `index-deps`, which resolves the dependencies
+// declared by sbt builds, scala-cli `//> using dep` directives and script magic
+// imports with coursier, and prints the artifact index of their jars for
+// `--artifacts`, with each symbol attributed to its dependency's coordinates.
+func runIndexDepsCommand(args []string) {
+	f := newParseFlags("index-deps", "[flags] <build.sbt, source or directory>...")
+	command := f.String("cs", "cs", "coursier launcher to fetch dependencies missing from the cache; empty to only read the cache")
+	cacheDir := f.String("coursier-cache", defaultCoursierCache(), "coursier cache to find jars in")
+	defaultScalaVersion := f.String("scala-version", "2.13", "Scala version of cross-versioned dependencies, for builds that do not set one")
+	f.parse(args)
+	if len(f.include) == 0 {
+		f.include = append(stringList{"**/*.sbt"}, defaultIncludes...)
+	}
+
+	var declared []buildDependencies
+	sbtParser := NewSbtParser()
+	var sources []string
+	for _, filePath := range f.files() {
+		if !isSbtBuildFile(filePath) {
+			sources = append(sources, filePath)
+			continue
+		}
+
+		filePath, sourceCode := f.readFile(filePath)
+		build, errs := sbtParser.Parse(filePath, sourceCode)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filePath, err)
+		}
+		declared = append(declared, readBuildDependencies(build))
+	}
+	f.parseFiles(sources, func(result *ParseResult) {
+		deps := buildDependencies{Deps: result.ScriptDeps}
+		if result.Using != nil {
+			deps.ScalaVersion = result.Using.ScalaVersion
+			deps.Deps = append(append(deps.Deps, result.Using.Deps...), result.Using.TestDeps...)
+		}
+		declared = append(declared, deps)
+	}, WithImportsOnly())
+
+	coursier := Coursier{Command: *command, CacheDir: *cacheDir}
+	idx := NewArtifactIndex()
+	indexed := make(map[string]bool)
+	for _, deps := range declared {
+		scalaVersion := deps.ScalaVersion
+		if scalaVersion == "" {
+			scalaVersion = *defaultScalaVersion
+		}
+
+		for _, dep := range deps.Deps {
+			coordinates := dep.String()
+			if indexed[coordinates] {
+				continue
+			}
+			indexed[coordinates] = true
+
+			jar, err := coursier.Fetch(dep, scalaVersion)
+			if err == nil {
+				err = idx.IndexJar(jar, coordinates)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				setExitCode(exitInternal)
+			}
+		}
+	}
+
+	if err := idx.Write(os.Stdout); err != nil {
+		panic(err)
+	}
+}
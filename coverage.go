@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// NodeCoverage is a type of syntax node the symbol walker does not understand,
+// with how often it was seen.
+type NodeCoverage struct {
+	Node  string
+	Count int
+	// Files is the number of files it was seen in.
+	Files int
+	// Examples are the `file:line` of its first occurrences.
+	Examples []string
+}
+
+// CoverageReport summarises the syntax the symbol walker did not understand
+// across a set of files, so the grammar constructs needing extraction support
+// can be prioritised.
+type CoverageReport struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Files is the number of files parsed, and FilesWithUnknownNodes how many of
+	// them had nodes the walker did not understand.
+	Files                 int
+	FilesWithUnknownNodes int
+	// Nodes are sorted by count, most frequent first.
+	Nodes []NodeCoverage
+}
+
+// UnknownNodeCoverage returns the coverage report of the `unknown-node` warnings
+// of results, keeping up to maxExamples locations of each node type.
+func UnknownNodeCoverage(results []*ParseResult, maxExamples int) CoverageReport {
+	report := CoverageReport{SchemaVersion: SchemaVersion, Files: len(results)}
+	nodes := make(map[string]*NodeCoverage)
+	for _, result := range results {
+		seen := make(map[string]bool)
+		for _, warning := range result.Warnings {
+			if warning.Kind != "unknown-node" {
+				continue
+			}
+			node := nodes[warning.Node]
+			if node == nil {
+				node = &NodeCoverage{Node: warning.Node}
+				nodes[warning.Node] = node
+			}
+			node.Count++
+			if !seen[warning.Node] {
+				seen[warning.Node] = true
+				node.Files++
+			}
+			if len(node.Examples) < maxExamples {
+				node.Examples = append(node.Examples, fmt.Sprintf("%s:%d", result.File, warning.Line))
+			}
+		}
+		if len(seen) > 0 {
+			report.FilesWithUnknownNodes++
+		}
+	}
+
+	for _, node := range nodes {
+		report.Nodes = append(report.Nodes, *node)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		if report.Nodes[i].Count != report.Nodes[j].Count {
+			return report.Nodes[i].Count > report.Nodes[j].Count
+		}
+		return report.Nodes[i].Node < report.Nodes[j].Node
+	})
+	return report
+}
+
+func runCoverageReportCommand(args []string) {
+	f := newParseFlags("coverage-report", "[flags] <file or directory>...")
+	examples := f.Int("examples", 3, "number of example locations to print for each node type")
+	asJSON := f.Bool("json", false, "print the report as JSON")
+	f.parse(args)
+
+	report := UnknownNodeCoverage(f.parseAll(), *examples)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	fmt.Printf("%d of %d files have nodes the symbol walker does not understand\n", report.FilesWithUnknownNodes, report.Files)
+	for _, node := range report.Nodes {
+		fmt.Printf("%6d  %s (%d files)\n", node.Count, node.Node, node.Files)
+		for _, example := range node.Examples {
+			fmt.Printf("          %s\n", example)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// DeadCodeCandidate is a public symbol that no other file imports or references.
+type DeadCodeCandidate struct {
+	Symbol string
+	File   string
+	Line   int
+}
+
+// FindDeadCode returns the symbols defined in results that no other file
+// references, either by import or through SamePackageRefs, so results should come
+// from a parser created WithIndex. Symbols in mains and tests are never
+// candidates, and importing an object or class counts as referencing all of its
+// members, since their uses through the owner are not tracked.
+func FindDeadCode(results []*ParseResult) []DeadCodeCandidate {
+	defined := make(map[string]bool)
+	for _, result := range results {
+		for _, definition := range result.Definitions {
+			defined[qualify(result.Package, definition.Name)] = true
+		}
+	}
+
+	// referencedBy maps each referenced symbol, and each symbol it is nested in,
+	// to the files referencing it.
+	referencedBy := make(map[string][]string)
+	reference := func(name, file string) {
+		for {
+			if !containsString(referencedBy[name], file) {
+				referencedBy[name] = append(referencedBy[name], file)
+			}
+			i := strings.LastIndex(name, ".")
+			if i < 0 {
+				return
+			}
+			name = name[:i]
+		}
+	}
+
+	for _, result := range results {
+		for _, imp := range result.Imports {
+			if strings.HasSuffix(imp, "._") || strings.HasSuffix(imp, ".*") {
+				continue
+			}
+			reference(imp, result.File)
+		}
+		for _, ref := range result.SamePackageRefs {
+			reference(ref, result.File)
+		}
+	}
+
+	referencedElsewhere := func(name, file string) bool {
+		for _, other := range referencedBy[name] {
+			if other != file {
+				return true
+			}
+		}
+		return false
+	}
+
+	var candidates []DeadCodeCandidate
+	for _, result := range results {
+		if result.HasMain || isTestFile(result) {
+			continue
+		}
+
+	definitions:
+		for _, definition := range result.Definitions {
+			symbol := qualify(result.Package, definition.Name)
+			if referencedElsewhere(symbol, result.File) {
+				continue
+			}
+
+			for owner := symbol; strings.Contains(owner, "."); {
+				owner = owner[:strings.LastIndex(owner, ".")]
+				if defined[owner] && referencedElsewhere(owner, result.File) {
+					continue definitions
+				}
+			}
+
+			candidates = append(candidates, DeadCodeCandidate{
+				Symbol: symbol,
+				File:   result.File,
+				Line:   definition.Line,
+			})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].File != candidates[j].File {
+			return candidates[i].File < candidates[j].File
+		}
+		return candidates[i].Line < candidates[j].Line
+	})
+	return candidates
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Deprecation is a definition's `@deprecated` annotation, or Java's
+// `@Deprecated`, with its message and the version it was deprecated since, if
+// given.
+type Deprecation struct {
+	Message string
+	Since   string
+}
+
+// deprecatedAnnotations are the names of the annotations deprecating a definition.
+var deprecatedAnnotations = []string{"deprecated", "scala.deprecated", "Deprecated", "java.lang.Deprecated"}
+
+// readDeprecation returns the deprecation of the definition node, or nil if it
+// is not deprecated. A scaladoc `@deprecated` tag supplies the message when the
+// annotation has none, but does not deprecate a definition by itself.
+func readDeprecation(node *sitter.Node, sourceCode []byte, doc *Scaladoc) *Deprecation {
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		annotation := node.NamedChild(i)
+		if annotation.Type() != "annotation" {
+			continue
+		}
+		name := annotation.ChildByFieldName("name")
+		if name == nil || !containsString(deprecatedAnnotations, name.Content(sourceCode)) {
+			continue
+		}
+
+		deprecation := &Deprecation{}
+		if args := annotation.ChildByFieldName("arguments"); args != nil {
+			for j := 0; j < int(args.NamedChildCount()); j++ {
+				arg := args.NamedChild(j)
+				field := []*string{&deprecation.Message, &deprecation.Since}[min(j, 1)]
+				if arg.Type() == "assignment_expression" {
+					switch arg.ChildByFieldName("left").Content(sourceCode) {
+					case "message":
+						field = &deprecation.Message
+					case "since":
+						field = &deprecation.Since
+					default:
+						continue
+					}
+					arg = arg.ChildByFieldName("right")
+				}
+				if arg != nil && arg.Type() == "string" {
+					*field = unquoteScalaString(arg.Content(sourceCode))
+				}
+			}
+		}
+		if deprecation.Message == "" && doc != nil {
+			deprecation.Message = doc.DeprecatedMessage
+		}
+		return deprecation
+	}
+	return nil
+}
+
+// unquoteScalaString returns the value of a string literal, or the literal
+// without its quotes if it cannot be unquoted like a Go string.
+func unquoteScalaString(literal string) string {
+	if strings.HasPrefix(literal, `"""`) {
+		return strings.TrimSuffix(strings.TrimPrefix(literal, `"""`), `"""`)
+	}
+	if value, err := strconv.Unquote(literal); err == nil {
+		return value
+	}
+	return strings.Trim(literal, `"`)
+}
+
+// DeprecatedUsage is a file's use of a deprecated symbol defined in another file.
+type DeprecatedUsage struct {
+	File string
+	// Symbol is the deprecated symbol used, which may be a member of Deprecated,
+	// the symbol actually deprecated.
+	Symbol      string
+	Deprecated  string
+	Deprecation Deprecation
+	DefinedAt   string
+}
+
+// FindDeprecatedUsages returns the uses of deprecated symbols among results:
+// imports of them or their members, and, for results parsed WithIndex or
+// WithSemanticDB, references to them from the same package or resolved by the
+// compiler. Members of a deprecated object are deprecated with it. Uses of an
+// object's deprecated members through the object are only found by SemanticDB.
+func FindDeprecatedUsages(results []*ParseResult) []DeprecatedUsage {
+	type deprecated struct {
+		Deprecation
+		file string
+		line int
+	}
+	deprecations := make(map[string]deprecated)
+	for _, result := range results {
+		for _, definition := range result.Definitions {
+			if definition.Deprecation != nil {
+				symbol := qualify(result.Package, definition.Name)
+				deprecations[symbol] = deprecated{*definition.Deprecation, result.File, definition.Line}
+			}
+		}
+	}
+	if len(deprecations) == 0 {
+		return nil
+	}
+
+	var usages []DeprecatedUsage
+	for _, result := range results {
+		used := make(map[string]bool)
+		use := func(symbol string) {
+			for name := symbol; name != "" && !used[symbol]; {
+				if d, ok := deprecations[name]; ok && d.file != result.File {
+					used[symbol] = true
+					usages = append(usages, DeprecatedUsage{
+						File:        result.File,
+						Symbol:      symbol,
+						Deprecated:  name,
+						Deprecation: d.Deprecation,
+						DefinedAt:   d.file + ":" + strconv.Itoa(d.line),
+					})
+				}
+				i := strings.LastIndex(name, ".")
+				if i < 0 {
+					break
+				}
+				name = name[:i]
+			}
+		}
+
+		// NOTE: a wildcard import of a deprecated object uses the object, while one
+		// of a package containing deprecated symbols uses none of them.
+		for _, imp := range result.Imports {
+			use(strings.TrimSuffix(strings.TrimSuffix(imp, "._"), ".*"))
+		}
+		for _, ref := range result.SamePackageRefs {
+			use(ref)
+		}
+		for _, ref := range result.ResolvedReferences {
+			use(ref)
+		}
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool { return usages[i].File < usages[j].File })
+	return usages
+}
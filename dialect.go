@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// scala3Syntax matches constructs that only parse as Scala 3: given instances,
+// enums, extension methods, context functions, using clauses and derives clauses.
+var scala3Syntax = regexp.MustCompile(`(?m)^\s*(given|enum|extension)\b|\?=>|\(using\s|\bderives\s|^\s*import\s.*\.\*\s*$`)
+
+// scalaVersionDirective matches a scala-cli `//> using scala` directive.
+var scalaVersionDirective = regexp.MustCompile(`(?m)^//>\s*using\s+scala\s+"?(\d+)`)
+
+func (d Dialect) String() string {
+	switch d {
+	case Scala2:
+		return "scala2"
+	case Scala3:
+		return "scala3"
+	default:
+		return "auto"
+	}
+}
+
+// Set parses a dialect name as printed by String, so a Dialect can be used as a
+// flag.Value.
+func (d *Dialect) Set(name string) error {
+	switch name {
+	case "scala2", "2":
+		*d = Scala2
+	case "scala3", "3":
+		*d = Scala3
+	case "auto":
+		*d = DialectAuto
+	default:
+		return fmt.Errorf("unknown dialect %q, expected scala2, scala3 or auto", name)
+	}
+	return nil
+}
+
+// MarshalText encodes the dialect by name, so it appears as e.g. "scala3" in JSON.
+func (d Dialect) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Dialect) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// Wildcard returns the import wildcard used by the dialect.
+func (d Dialect) Wildcard() string {
+	if d == Scala3 {
+		return "*"
+	}
+	return "_"
+}
+
+// detectDialect guesses the dialect of a file, preferring build hints (a
+// `//> using scala` directive or an sbt `scala-2`/`scala-3` source directory)
+// over the syntax of the source itself.
+func detectDialect(filePath string, sourceCode []byte) Dialect {
+	if match := scalaVersionDirective.FindSubmatch(sourceCode); match != nil {
+		if string(match[1]) == "3" {
+			return Scala3
+		}
+		return Scala2
+	}
+
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(filePath)), "/") {
+		if dir == "scala-3" || strings.HasPrefix(dir, "scala-3.") {
+			return Scala3
+		} else if strings.HasPrefix(dir, "scala-2") {
+			return Scala2
+		}
+	}
+
+	if scala3Syntax.Match(sourceCode) {
+		return Scala3
+	}
+
+	return Scala2
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// CommentDirective is a magic comment that overrides an automated dependency
+// decision, e.g.
+//
+//	// scala-tree-parser:ignore-file
+//	import com.foo.Bar // gazelle:keep
+//	import com.foo.Baz // target: //foo:baz
+type CommentDirective struct {
+	// Name is the directive without its prefix: `ignore-file`, `keep` or `target`.
+	// Other gazelle directives keep their prefix, e.g. `gazelle:resolve`.
+	Name string
+	// Value is the rest of the comment, e.g. the label of a `target` directive.
+	Value string
+	// Line is the 1-based line of the comment.
+	Line int
+	// Imports are the imports declared on the same line, if any.
+	Imports []string
+}
+
+// readCommentDirectives finds every directive comment beneath node. importLines
+// maps 0-based rows to the imports declared on them.
+func readCommentDirectives(node *sitter.Node, sourceCode []byte, importLines map[uint32][]string) []CommentDirective {
+	directives := make([]CommentDirective, 0)
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() != "comment" {
+			directives = append(directives, readCommentDirectives(child, sourceCode, importLines)...)
+			continue
+		}
+
+		directive, ok := parseCommentDirective(child.Content(sourceCode))
+		if !ok {
+			continue
+		}
+
+		row := child.StartPoint().Row
+		directive.Line = int(row) + 1
+		directive.Imports = importLines[row]
+		directives = append(directives, directive)
+	}
+
+	return directives
+}
+
+func parseCommentDirective(comment string) (CommentDirective, bool) {
+	var directive CommentDirective
+
+	text := strings.TrimPrefix(comment, "//")
+	if strings.HasPrefix(comment, "/*") {
+		text = strings.TrimSuffix(strings.TrimPrefix(comment, "/*"), "*/")
+	}
+	text = strings.TrimSpace(text)
+
+	if rest, ok := strings.CutPrefix(text, "scala-tree-parser:"); ok {
+		directive.Name, directive.Value, _ = strings.Cut(rest, " ")
+	} else if rest, ok := strings.CutPrefix(text, "gazelle:"); ok {
+		directive.Name, directive.Value, _ = strings.Cut(rest, " ")
+		if directive.Name != "keep" {
+			directive.Name = "gazelle:" + directive.Name
+		}
+	} else if rest, ok := strings.CutPrefix(text, "target:"); ok {
+		directive.Name = "target"
+		directive.Value = rest
+	} else {
+		return directive, false
+	}
+
+	directive.Value = strings.TrimSpace(directive.Value)
+	return directive, directive.Name != ""
+}
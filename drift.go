@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// TargetDrift is the difference between the deps a rule of an existing BUILD
+// file declares and the deps its sources need.
+type TargetDrift struct {
+	Label string
+	// Missing are deps the rule's sources need that it does not declare.
+	Missing []string
+	// Superfluous are declared deps its sources do not need. Only deps known to
+	// build Scala sources, or artifacts of the artifact index, are judged, and
+	// deps marked `# keep` never are.
+	Superfluous []string
+	// Visibility, if set, is a narrower visibility that would do for a public
+	// library; see narrowestVisibility.
+	Visibility []string
+}
+
+// existingRule is a Scala rule of an existing BUILD file.
+type existingRule struct {
+	label string
+	pkg   string
+	rule  *build.Rule
+	// files are the absolute paths of the parsed sources the rule builds.
+	files []string
+}
+
+// bazelPackage returns the Bazel package containing the directory dir, an
+// absolute path beneath root: the nearest directory with a BUILD file. Lookups
+// are cached in packages, where a nil entry means no package.
+func bazelPackage(root, dir string, packages map[string]*string) (string, bool) {
+	if pkg, ok := packages[dir]; ok {
+		return derefString(pkg), pkg != nil
+	}
+
+	var pkg *string
+	for _, name := range []string{"BUILD.bazel", "BUILD"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(root, dir)
+			rel = filepath.ToSlash(rel)
+			if rel == "." {
+				rel = ""
+			}
+			pkg = &rel
+			break
+		}
+	}
+	if parent := filepath.Dir(dir); pkg == nil && dir != root && parent != dir {
+		if parentPkg, ok := bazelPackage(root, parent, packages); ok {
+			pkg = &parentPkg
+		}
+	}
+	packages[dir] = pkg
+	return derefString(pkg), pkg != nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// normalizeLabel returns label, as written in a BUILD file of pkg, in its
+// absolute form, e.g. `//a/b:b` for `//a/b`, or `//pkg:c` for `:c`.
+func normalizeLabel(label, pkg string) string {
+	repo, target, isAbsolute := strings.Cut(label, "//")
+	if !isAbsolute {
+		return "//" + pkg + ":" + strings.TrimPrefix(label, ":")
+	}
+	if !strings.Contains(target, ":") {
+		target += ":" + path.Base(target)
+	}
+	if repo == "@" {
+		repo = ""
+	}
+	return repo + "//" + target
+}
+
+// ruleSources returns the files of dir, a package's directory, among files that
+// the rule's srcs name, either directly or through a glob.
+func ruleSources(rule *build.Rule, dir string, files []string) []string {
+	srcs := rule.Attr("srcs")
+	if srcs == nil {
+		return nil
+	}
+
+	// NOTE: strings inside glob(...) are patterns, and in its exclude argument
+	// excluded patterns; any others are files.
+	var explicit, includes, excludes []string
+	build.Walk(srcs, func(expr build.Expr, stack []build.Expr) {
+		str, ok := expr.(*build.StringExpr)
+		if !ok {
+			return
+		}
+		inGlob, inExclude := false, false
+		for _, parent := range stack {
+			switch parent := parent.(type) {
+			case *build.CallExpr:
+				if ident, ok := parent.X.(*build.Ident); ok && ident.Name == "glob" {
+					inGlob = true
+				}
+			case *build.AssignExpr:
+				if ident, ok := parent.LHS.(*build.Ident); ok && ident.Name == "exclude" && inGlob {
+					inExclude = true
+				}
+			}
+		}
+		switch {
+		case inExclude:
+			excludes = append(excludes, str.Value)
+		case inGlob:
+			includes = append(includes, str.Value)
+		default:
+			explicit = append(explicit, str.Value)
+		}
+	})
+
+	var matched []string
+	for _, file := range files {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if containsString(explicit, rel) || (matchAnyGlob(includes, rel) && !matchAnyGlob(excludes, rel)) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// ReconcileBuildFiles compares the deps declared by the Scala rules of the
+// existing BUILD files of results against the deps their sources need, as
+// GenerateTargets would compute them, reporting the rules whose deps differ.
+// Results should come from a parser created WithIndex(index), and artifacts,
+// if not nil, is the index results were parsed WithArtifactIndex of.
+func ReconcileBuildFiles(results []*ParseResult, index *Index, artifacts *ArtifactIndex, opts BuildOptions) ([]TargetDrift, error) {
+	root := absPath(opts.RepoRoot)
+	packages := make(map[string]*string)
+	packageFiles := make(map[string][]string)
+	resultsByFile := make(map[string]*ParseResult)
+	for _, result := range results {
+		file := absPath(result.File)
+		resultsByFile[file] = result
+		if pkg, ok := bazelPackage(root, filepath.Dir(file), packages); ok {
+			packageFiles[pkg] = append(packageFiles[pkg], file)
+		}
+	}
+
+	var rules []*existingRule
+	fileRules := make(map[string]*existingRule)
+	for pkg, files := range packageFiles {
+		dir := filepath.Join(root, filepath.FromSlash(pkg))
+		buildFile := buildFilePath(root, pkg, "")
+		src, err := os.ReadFile(buildFile)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseBuildFile(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", buildFile, err)
+		}
+
+		for _, rule := range parsed.rules() {
+			if !containsString(scalaRuleKinds, rule.Kind()) {
+				continue
+			}
+			existing := &existingRule{label: "//" + pkg + ":" + rule.Name(), pkg: pkg, rule: rule}
+			existing.files = ruleSources(rule, dir, files)
+			for _, file := range existing.files {
+				fileRules[file] = existing
+			}
+			rules = append(rules, existing)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].label < rules[j].label })
+
+	knownArtifacts := make(map[string]bool)
+	if artifacts != nil {
+		for _, providers := range artifacts.symbols {
+			for _, artifact := range providers {
+				knownArtifacts[artifact] = true
+			}
+		}
+	}
+	knownRules := make(map[string]bool)
+	for _, rule := range rules {
+		knownRules[rule.label] = true
+	}
+
+	neededBy := make(map[*existingRule]map[string]bool)
+	dependents := make(map[string][]string)
+	for _, rule := range rules {
+		needed := make(map[string]bool)
+		addDep := func(file string) {
+			if dep, ok := fileRules[absPath(file)]; ok && dep != rule && dep.rule.Kind() != "scala_test" {
+				needed[dep.label] = true
+			}
+		}
+		for _, file := range rule.files {
+			result := resultsByFile[file]
+			for _, imp := range result.Imports {
+				for _, file := range importedFiles(index, imp) {
+					addDep(file)
+				}
+			}
+			for _, ref := range result.SamePackageRefs {
+				for _, file := range index.Files(ref) {
+					addDep(file)
+				}
+			}
+			for _, artifact := range result.Artifacts {
+				if strings.HasPrefix(artifact, "@") || strings.HasPrefix(artifact, "//") {
+					needed[normalizeLabel(artifact, rule.pkg)] = true
+				}
+			}
+		}
+		neededBy[rule] = needed
+		for label := range needed {
+			dependents[label] = append(dependents[label], rule.pkg)
+		}
+	}
+
+	var drift []TargetDrift
+	for _, rule := range rules {
+		needed := neededBy[rule]
+
+		declared := make(map[string]bool)
+		var targetDrift TargetDrift
+		if deps, ok := rule.rule.Attr("deps").(*build.ListExpr); ok {
+			keepAll := isKeptAttr(rule.rule, "deps")
+			for _, item := range deps.List {
+				value := itemValue(item)
+				if value == "" {
+					continue
+				}
+				label := normalizeLabel(value, rule.pkg)
+				declared[label] = true
+				if !needed[label] && !hasKeepComment(item) && !keepAll && (knownRules[label] || knownArtifacts[value]) {
+					targetDrift.Superfluous = append(targetDrift.Superfluous, label)
+				}
+			}
+		}
+		for label := range needed {
+			if !declared[label] {
+				targetDrift.Missing = append(targetDrift.Missing, label)
+			}
+		}
+
+		if rule.rule.Kind() == "scala_library" && containsString(rule.rule.AttrStrings("visibility"), "//visibility:public") {
+			if suggested := narrowestVisibility(rule.pkg, dependents[rule.label]); !containsString(suggested, "//visibility:public") {
+				targetDrift.Visibility = suggested
+			}
+		}
+
+		if len(targetDrift.Missing) > 0 || len(targetDrift.Superfluous) > 0 || targetDrift.Visibility != nil {
+			targetDrift.Label = rule.label
+			sort.Slice(targetDrift.Missing, func(i, j int) bool { return compareLabels(targetDrift.Missing[i], targetDrift.Missing[j]) })
+			sort.Slice(targetDrift.Superfluous, func(i, j int) bool {
+				return compareLabels(targetDrift.Superfluous[i], targetDrift.Superfluous[j])
+			})
+			drift = append(drift, targetDrift)
+		}
+	}
+	return drift, nil
+}
+
+// runDriftCommand implements `drift`, which reports the Scala rules of existing
+// BUILD files whose deps differ from those their sources need, changing
+// nothing. It fails with exitParseFailure if any do. Public libraries that could
+// be less visible, and definitions that could be package-private, are reported
+// as suggestions, which do not fail it.
+func runDriftCommand(args []string) {
+	f := newParseFlags("drift", "[flags] <file or directory>...")
+	var opts BuildOptions
+	f.StringVar(&opts.RepoRoot, "repo-root", ".", "the workspace root, which Bazel packages are relative to")
+	f.parse(args)
+	files := f.files()
+
+	// NOTE: SamePackageRefs needs every file in the index first, so parse twice.
+	index := NewIndex()
+	f.parseFiles(files, index.Add)
+	var results []*ParseResult
+	f.parseFiles(files, func(result *ParseResult) {
+		results = append(results, result)
+	}, WithIndex(index))
+
+	drift, err := ReconcileBuildFiles(results, index, f.artifactIndex(), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInternal)
+	}
+	for _, target := range drift {
+		for _, label := range target.Missing {
+			fmt.Printf("%s: missing %s\n", target.Label, label)
+		}
+		for _, label := range target.Superfluous {
+			fmt.Printf("%s: superfluous %s\n", target.Label, label)
+		}
+		if target.Visibility != nil {
+			fmt.Printf("%s: visibility could be [%s]\n", target.Label, strings.Join(target.Visibility, ", "))
+		}
+		if len(target.Missing) > 0 || len(target.Superfluous) > 0 {
+			setExitCode(exitParseFailure)
+		}
+	}
+	for _, suggestion := range SuggestPackagePrivate(results) {
+		fmt.Printf("%s:%d: %s could be private[%s]\n", suggestion.File, suggestion.Line, suggestion.Symbol, suggestion.Qualifier)
+	}
+}
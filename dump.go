@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// astNode is the JSON form of a named node in dump-ast output.
+type astNode struct {
+	// SchemaVersion is only set on the root.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	Type     string     `json:"type"`
+	Field    string     `json:"field,omitempty"`
+	Start    astPoint   `json:"start"`
+	End      astPoint   `json:"end"`
+	Text     string     `json:"text,omitempty"`
+	Children []*astNode `json:"children,omitempty"`
+}
+
+// astPoint is a 1-based position.
+type astPoint struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// buildAST converts the named nodes beneath the cursor. Field names come from the
+// cursor, since Node.FieldNameForChild is unreliable in this version of the bindings.
+func buildAST(cursor *sitter.TreeCursor, sourceCode []byte) *astNode {
+	node := cursor.CurrentNode()
+	ast := &astNode{
+		Type:  node.Type(),
+		Field: cursor.CurrentFieldName(),
+		Start: astPoint{Line: int(node.StartPoint().Row) + 1, Column: int(node.StartPoint().Column) + 1},
+		End:   astPoint{Line: int(node.EndPoint().Row) + 1, Column: int(node.EndPoint().Column) + 1},
+	}
+
+	if node.NamedChildCount() == 0 {
+		ast.Text = node.Content(sourceCode)
+	}
+
+	if cursor.GoToFirstChild() {
+		for {
+			if cursor.CurrentNode().IsNamed() {
+				ast.Children = append(ast.Children, buildAST(cursor, sourceCode))
+			}
+			if !cursor.GoToNextSibling() {
+				break
+			}
+		}
+		cursor.GoToParent()
+	}
+
+	return ast
+}
+
+// writeSExpression writes the tree one node per line, indented by depth, e.g.
+//
+//	(class_definition [1:1-1:10]
+//	  name: (identifier [1:7-1:10] "Foo"))
+func writeSExpression(w io.Writer, ast *astNode, depth int) {
+	var line strings.Builder
+	line.WriteString(strings.Repeat("  ", depth))
+	if ast.Field != "" {
+		line.WriteString(ast.Field + ": ")
+	}
+	fmt.Fprintf(&line, "(%s [%d:%d-%d:%d]", ast.Type, ast.Start.Line, ast.Start.Column, ast.End.Line, ast.End.Column)
+	if ast.Text != "" {
+		line.WriteString(" " + strconv.Quote(ast.Text))
+	}
+	fmt.Fprint(w, line.String())
+
+	for _, child := range ast.Children {
+		fmt.Fprintln(w)
+		writeSExpression(w, child, depth+1)
+	}
+	fmt.Fprint(w, ")")
+}
+
+// runDumpASTCommand implements `dump-ast [--format=sexp|json] <file>`.
+func runDumpASTCommand(args []string) {
+	flags := flag.NewFlagSet("dump-ast", flag.ContinueOnError)
+	format := flags.String("format", "sexp", "output format: sexp or json")
+	parseCommandFlags(flags, args)
+
+	if flags.NArg() != 1 || (*format != "sexp" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "usage: dump-ast [--format=sexp|json] <file>")
+		os.Exit(exitUsage)
+	}
+
+	sourceCode, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	sourceCode = prepareSource(sourceCode, UTF8)
+
+	root, err := sitter.ParseCtx(context.Background(), grammarInput(sourceCode), ScalaLang)
+	if err != nil {
+		panic(err)
+	}
+
+	cursor := sitter.NewTreeCursor(root)
+	defer cursor.Close()
+	ast := buildAST(cursor, sourceCode)
+
+	if *format == "json" {
+		ast.SchemaVersion = SchemaVersion
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(ast); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	writeSExpression(os.Stdout, ast, 0)
+	fmt.Println()
+}
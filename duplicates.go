@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DuplicateDefinition is a fully-qualified top-level symbol defined in more
+// than one file, which makes which one is loaded depend on the classpath order.
+type DuplicateDefinition struct {
+	Symbol string
+	// Definitions are the `file:line` of each definition.
+	Definitions []string
+}
+
+// versionedSourceRoot matches the Scala version suffix of a source root such as
+// `src/main/scala-2.13`.
+var versionedSourceRoot = regexp.MustCompile(`/scala-[^/]+$`)
+
+// crossBuilt reports whether roots are distinct source roots of one project for
+// different Scala versions, such as `src/main/scala-2` and `src/main/scala-3`,
+// which are never compiled together.
+func crossBuilt(roots []string) bool {
+	seen := make(map[string]bool)
+	project := ""
+	for i, root := range roots {
+		if !versionedSourceRoot.MatchString(root) || seen[root] {
+			return false
+		}
+		seen[root] = true
+		if unversioned := versionedSourceRoot.ReplaceAllString(root, ""); i == 0 {
+			project = unversioned
+		} else if unversioned != project {
+			return false
+		}
+	}
+	return true
+}
+
+// FindDuplicateDefinitions returns the top-level symbols defined in more than
+// one of results, sorted by symbol. A class or trait and an object of the same
+// name, as companions are, are different symbols, and so are definitions in the
+// source roots of different Scala versions of a cross-built project.
+func FindDuplicateDefinitions(results []*ParseResult) []DuplicateDefinition {
+	type definition struct {
+		file string
+		line int
+		root string
+	}
+	// NOTE: types and terms are kept apart, as apiKey does.
+	definitions := make(map[string][]definition)
+	symbols := make(map[string]string)
+	for _, result := range results {
+		for _, def := range result.Definitions {
+			if strings.Contains(def.Name, ".") {
+				continue
+			}
+			symbol := qualify(result.Package, def.Name)
+			key := APISymbol{Symbol: symbol, Kind: def.Kind}.apiKey()
+			defs := definitions[key]
+			if len(defs) > 0 && defs[len(defs)-1].file == result.File {
+				continue
+			}
+			symbols[key] = symbol
+			definitions[key] = append(defs, definition{result.File, def.Line, result.SourceRoot})
+		}
+	}
+
+	var duplicates []DuplicateDefinition
+	for key, defs := range definitions {
+		if len(defs) < 2 {
+			continue
+		}
+		roots := make([]string, 0, len(defs))
+		for _, def := range defs {
+			roots = append(roots, def.root)
+		}
+		if crossBuilt(roots) {
+			continue
+		}
+
+		duplicate := DuplicateDefinition{Symbol: symbols[key]}
+		for _, def := range defs {
+			duplicate.Definitions = append(duplicate.Definitions, fmt.Sprintf("%s:%d", def.file, def.line))
+		}
+		sort.Strings(duplicate.Definitions)
+		duplicates = append(duplicates, duplicate)
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].Symbol != duplicates[j].Symbol {
+			return duplicates[i].Symbol < duplicates[j].Symbol
+		}
+		return duplicates[i].Definitions[0] < duplicates[j].Definitions[0]
+	})
+	return duplicates
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding assumed for sources without a byte order mark.
+type Encoding int
+
+const (
+	// UTF8 is the default: sources are parsed as they are.
+	UTF8 Encoding = iota
+	// Latin1 transcodes sources that are not valid UTF-8 from ISO-8859-1.
+	Latin1
+)
+
+func (e Encoding) String() string {
+	if e == Latin1 {
+		return "latin1"
+	}
+	return "utf-8"
+}
+
+// Set parses an encoding name, so an Encoding can be used as a flag.Value.
+func (e *Encoding) Set(name string) error {
+	switch name {
+	case "utf-8", "utf8":
+		*e = UTF8
+	case "latin1", "latin-1", "iso-8859-1":
+		*e = Latin1
+	default:
+		return fmt.Errorf("unknown encoding %q, expected utf-8 or latin1", name)
+	}
+	return nil
+}
+
+// WithEncoding sets the encoding assumed for sources without a byte order mark.
+func WithEncoding(encoding Encoding) Option {
+	return func(p *treeSitterParser) {
+		p.encoding = encoding
+	}
+}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// decodeSource returns source as UTF-8 without a byte order mark. UTF-16 sources
+// are recognised by their BOM; otherwise source is assumed to be in encoding.
+// source itself is returned when it needs no change.
+func decodeSource(source []byte, encoding Encoding) []byte {
+	switch {
+	case bytes.HasPrefix(source, utf8BOM):
+		return source[len(utf8BOM):]
+	case bytes.HasPrefix(source, utf16LEBOM):
+		return decodeUTF16(source[len(utf16LEBOM):], binary.LittleEndian)
+	case bytes.HasPrefix(source, utf16BEBOM):
+		return decodeUTF16(source[len(utf16BEBOM):], binary.BigEndian)
+	}
+
+	if encoding == Latin1 && !utf8.Valid(source) {
+		decoded := make([]byte, 0, len(source)+len(source)/8)
+		for _, b := range source {
+			decoded = utf8.AppendRune(decoded, rune(b))
+		}
+		return decoded
+	}
+	return source
+}
+
+func decodeUTF16(source []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(source)/2)
+	for i := range units {
+		units[i] = order.Uint16(source[2*i:])
+	}
+
+	decoded := make([]byte, 0, len(units))
+	for _, r := range utf16.Decode(units) {
+		decoded = utf8.AppendRune(decoded, r)
+	}
+	return decoded
+}
+
+// normalizeLineEndings converts CRLF line endings to LF, so no `\r` ends up in
+// extracted text. Rows and columns are unaffected, since the `\r` ends its line.
+func normalizeLineEndings(source []byte) []byte {
+	if !bytes.Contains(source, []byte("\r\n")) {
+		return source
+	}
+	return bytes.ReplaceAll(source, []byte("\r\n"), []byte("\n"))
+}
+
+// lineEnding returns the line ending source uses, `\r\n` if any line ends in
+// one and `\n` otherwise.
+func lineEnding(source []byte) string {
+	if bytes.Contains(source, []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// prepareSource decodes source to UTF-8 and normalizes its line endings.
+func prepareSource(source []byte, encoding Encoding) []byte {
+	return normalizeLineEndings(decodeSource(source, encoding))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// SyntaxError is the 1-based position and description of an ERROR or MISSING
+// node, or of a construct the parser could not read.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// findSyntaxErrors returns the syntax errors in a tree, with 1-based positions in
+// the file as written, i.e. before any script wrapping.
+func findSyntaxErrors(root *sitter.Node, sourceCode []byte, filePath string) []SyntaxError {
+	var errs []SyntaxError
+	var visit func(node *sitter.Node)
+	visit = func(node *sitter.Node) {
+		if node.IsMissing() || node.IsError() {
+			point := node.StartPoint()
+			// NOTE: tree-sitter columns are in bytes; report characters, as editors do.
+			column := int(point.Column) + 1
+			if lineStart := int(node.StartByte()) - int(point.Column); lineStart >= 0 && lineStart <= len(sourceCode) {
+				column = runeColumn(sourceCode[lineStart:], int(point.Column)) + 1
+			}
+			if point.Row == 0 && isScriptFile(filePath) {
+				column -= len(scriptPrefix)
+			}
+
+			message := fmt.Sprintf("syntax error: missing %s", node.Type())
+			if node.IsError() {
+				text, _, _ := strings.Cut(strings.TrimSpace(node.Content(sourceCode)), "\n")
+				message = fmt.Sprintf("syntax error: unexpected %q", text)
+			}
+
+			errs = append(errs, SyntaxError{Line: int(point.Row) + 1, Column: column, Message: message})
+			return
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			visit(node.Child(i))
+		}
+	}
+	visit(root)
+	return errs
+}
+
+// positionedErrors returns the syntax errors of result followed by the errors
+// among errs with a position, i.e. constructs the parser could not read.
+func positionedErrors(result *ParseResult, errs []error) []SyntaxError {
+	positioned := append([]SyntaxError(nil), result.SyntaxErrors...)
+	for _, err := range errs {
+		var syntaxErr SyntaxError
+		if errors.As(err, &syntaxErr) {
+			positioned = append(positioned, syntaxErr)
+		}
+	}
+	return positioned
+}
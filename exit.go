@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Exit codes of the CLI.
+const (
+	exitOK = 0
+	// exitUsage is returned for invalid flags or arguments.
+	exitUsage = 1
+	// exitParseFailure is returned when a file fails the --fail-on check, would
+	// be changed by a command rewriting files with --check, when drift finds
+	// rules whose deps differ from those their sources need, or when index finds
+	// symbols defined twice with --fail-on-duplicates, or index merge conflicting
+	// ones, or when private-leaks finds imports of definitions they cannot see.
+	exitParseFailure = 2
+	// exitInternal is returned for I/O errors and crashes.
+	exitInternal = 3
+)
+
+// exitCode is the code main exits with once the command has run; see setExitCode.
+var exitCode = exitOK
+
+// setExitCode raises the code main exits with, so the most severe problem wins.
+func setExitCode(code int) {
+	if code > exitCode {
+		exitCode = code
+	}
+}
+
+// parseCommandFlags parses the flags of a command, exiting with exitUsage if
+// they are invalid.
+func parseCommandFlags(flags *flag.FlagSet, args []string) {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitOK)
+	} else if err != nil {
+		os.Exit(exitUsage)
+	}
+}
+
+// failOn selects which problems found while parsing make the CLI exit with
+// exitParseFailure.
+type failOn string
+
+const (
+	failOnSyntaxErrors failOn = "syntax-errors"
+	// failOnUnknownNodes also fails on nodes the extractor does not understand.
+	failOnUnknownNodes failOn = "unknown-nodes"
+	failOnNone         failOn = "none"
+)
+
+func (f *failOn) String() string {
+	return string(*f)
+}
+
+func (f *failOn) Set(value string) error {
+	switch failOn(value) {
+	case failOnSyntaxErrors, failOnUnknownNodes, failOnNone:
+		*f = failOn(value)
+		return nil
+	}
+	return fmt.Errorf("expected syntax-errors, unknown-nodes or none")
+}
+
+// check records the exit code for a parsed file and its errors. result may be nil
+// for files, such as sbt builds, that do not produce a ParseResult.
+func (f failOn) check(result *ParseResult, errs []error) {
+	if f == failOnNone {
+		return
+	}
+
+	if len(errs) > 0 {
+		setExitCode(exitParseFailure)
+	}
+	if f == failOnUnknownNodes && result != nil {
+		for _, warning := range result.Warnings {
+			if warning.Kind == "unknown-node" {
+				setExitCode(exitParseFailure)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Extractor is a pluggable analysis run over every parsed file. Once the built-in
+// extraction is complete, Visit is called for each named node of the tree in
+// pre-order, and may record its findings in the ParseResult, typically under
+// result.Extra[Name()].
+//
+// A parser may be used concurrently, so Visit must not keep per-file state on
+// the extractor itself.
+type Extractor interface {
+	Name() string
+	Visit(node *sitter.Node, sourceCode []byte, result *ParseResult)
+}
+
+// ExtraDecoder is implemented by Extractors whose findings in result.Extra are
+// not plain JSON values, to decode them from their JSON form, so results read
+// back from a result cache hold the same types as fresh ones. Findings of other
+// extractors are decoded as plain values, e.g. map[string]any for a struct.
+type ExtraDecoder interface {
+	DecodeExtra(data []byte) (any, error)
+}
+
+// WithExtractors registers extractors to run on every parsed file, in order.
+func WithExtractors(extractors ...Extractor) Option {
+	return func(p *treeSitterParser) {
+		p.extractors = append(p.extractors, extractors...)
+	}
+}
+
+func runExtractors(extractors []Extractor, node *sitter.Node, sourceCode []byte, result *ParseResult) {
+	if len(extractors) == 0 {
+		return
+	}
+
+	WalkNode(node, func(node *sitter.Node) bool {
+		for _, extractor := range extractors {
+			extractor.Visit(node, sourceCode, result)
+		}
+		return true
+	})
+}
+
+// decodeExtra decodes the JSON form of the findings recorded under each name in
+// result.Extra, with the registered extractor of that name if it is an
+// ExtraDecoder.
+func decodeExtra(extractors []Extractor, encoded map[string]json.RawMessage) (map[string]any, error) {
+	extra := make(map[string]any, len(encoded))
+	for name, data := range encoded {
+		var value any
+		var err error
+		decoder, ok := extractorNamed(extractors, name).(ExtraDecoder)
+		if ok {
+			value, err = decoder.DecodeExtra(data)
+		} else {
+			err = json.Unmarshal(data, &value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		extra[name] = value
+	}
+	return extra, nil
+}
+
+func extractorNamed(extractors []Extractor, name string) Extractor {
+	for _, extractor := range extractors {
+		if extractor.Name() == name {
+			return extractor
+		}
+	}
+	return nil
+}
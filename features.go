@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+)
+
+// languageFeatures returns the features enabled by `scala.language` imports, e.g.
+// `higherKinds` or `experimental.macros`, in the form scalac's `-language:` flag
+// takes. A wildcard import enables every feature, which is reported as `_`.
+func languageFeatures(imports []string, dialect Dialect) []string {
+	features := make([]string, 0)
+
+	for _, imp := range imports {
+		feature, ok := strings.CutPrefix(imp, "scala.language.")
+		if !ok {
+			// `scala` is always in scope, so `import language.x` is common too
+			feature, ok = strings.CutPrefix(imp, "language.")
+		}
+		if !ok {
+			continue
+		}
+
+		if feature == dialect.Wildcard() {
+			feature = "_"
+		}
+		if !containsString(features, feature) {
+			features = append(features, feature)
+		}
+	}
+
+	return features
+}
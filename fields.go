@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Field is a case class constructor parameter.
+type Field struct {
+	Name string
+	// Type is the parameter's type as written, with whitespace normalized.
+	Type string
+	// Default is the parameter's default value as written, if any.
+	Default string
+}
+
+// readFields returns the fields of a case class, i.e. the parameters of its first
+// parameter list. Later lists (e.g. implicit parameters) don't define fields.
+func readFields(node *sitter.Node, sourceCode []byte) []Field {
+	fields := make([]Field, 0)
+
+	params := node.ChildByFieldName("class_parameters")
+	if params == nil {
+		return fields
+	}
+
+	for i := 0; i < int(params.NamedChildCount()); i++ {
+		param := params.NamedChild(i)
+		if param.Type() != "class_parameter" {
+			continue
+		}
+
+		field := Field{Name: param.ChildByFieldName("name").Content(sourceCode)}
+		if paramType := param.ChildByFieldName("type"); paramType != nil {
+			field.Type = normalizeWhitespace(paramType.Content(sourceCode))
+		}
+		if defaultValue := param.ChildByFieldName("default_value"); defaultValue != nil {
+			field.Default = normalizeWhitespace(defaultValue.Content(sourceCode))
+		}
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// normalizeWhitespace collapses runs of whitespace to single spaces, and drops
+// them just inside brackets and parentheses.
+func normalizeWhitespace(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	for _, pair := range [][2]string{{"[ ", "["}, {" ]", "]"}, {"( ", "("}, {" )", ")"}} {
+		text = strings.ReplaceAll(text, pair[0], pair[1])
+	}
+	return text
+}
+
+// readSignature returns the parameter lists and return type of a def as
+// written, with whitespace normalized, e.g. `(xs: Seq[A])(implicit ev: Ord[A]): A`.
+// Type parameters are left to readTypeParams.
+func readSignature(node *sitter.Node, sourceCode []byte) string {
+	start := node.ChildByFieldName("name").EndByte()
+	if typeParams := node.ChildByFieldName("type_parameters"); typeParams != nil {
+		start = typeParams.EndByte()
+	}
+	end := node.EndByte()
+	if body := node.ChildByFieldName("body"); body != nil {
+		end = body.StartByte()
+	}
+	if end < start {
+		return ""
+	}
+
+	signature := strings.TrimSpace(string(sourceCode[start:end]))
+	return normalizeWhitespace(strings.TrimSpace(strings.TrimSuffix(signature, "=")))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultIncludes are the files collected from a directory when no include
+// patterns are given.
+var defaultIncludes = []string{"**/*.scala", "**/*.sc"}
+
+// findOptions select the files collected by findSourceFiles.
+type findOptions struct {
+	Include []string
+	Exclude []string
+	// NoIgnore disables .gitignore and .bazelignore handling.
+	NoIgnore bool
+	// FollowSymlinks descends into symlinked directories. Symlinked files are
+	// always collected.
+	FollowSymlinks bool
+}
+
+// fileFinder is the state of a single findSourceFiles traversal.
+type fileFinder struct {
+	root    string
+	opts    findOptions
+	ignores *ignoreMatcher
+	// visited holds the resolved path of every directory walked, so a symlink
+	// cycle, or a second link to the same directory, is walked only once.
+	visited map[string]bool
+	files   []string
+}
+
+// findSourceFiles returns the files beneath root whose path relative to root
+// matches one of opts.Include and none of opts.Exclude. Directories matching
+// opts.Exclude are not descended into. Unless opts.NoIgnore is set, files and
+// directories ignored by .gitignore or .bazelignore files are skipped too.
+func findSourceFiles(root string, opts findOptions) ([]string, error) {
+	if len(opts.Include) == 0 {
+		opts.Include = defaultIncludes
+	}
+
+	finder := &fileFinder{root: root, opts: opts, visited: make(map[string]bool)}
+	if !opts.NoIgnore {
+		finder.ignores = newIgnoreMatcher(root)
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	err = finder.walk(root, resolved)
+	return finder.files, err
+}
+
+// walk collects the files beneath the resolved directory, reporting them under
+// dir, the path they were reached by.
+func (w *fileFinder) walk(dir, resolved string) error {
+	return filepath.WalkDir(resolved, func(walked string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		filePath := dir + strings.TrimPrefix(walked, resolved)
+		rel, err := filepath.Rel(w.root, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		abs, err := filepath.Abs(filePath)
+		if err != nil {
+			return err
+		}
+
+		isDir := entry.IsDir()
+		var target string
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if target, err = filepath.EvalSymlinks(walked); err != nil {
+				logf(LogDefault, "skipping %s: %v\n", filePath, err)
+				return nil
+			}
+			info, err := os.Stat(target)
+			if err != nil {
+				return nil
+			}
+			isDir = info.IsDir()
+		}
+
+		if w.ignores != nil && rel != "." {
+			if entry.Name() == ".git" || w.ignores.ignored(abs, isDir) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if !isDir {
+			if matchAnyGlob(w.opts.Include, rel) && !matchAnyGlob(w.opts.Exclude, rel) {
+				w.files = append(w.files, filePath)
+			}
+			return nil
+		}
+
+		// NOTE: `**/target/**` should prune target itself, not just its contents.
+		if rel != "." && (matchAnyGlob(w.opts.Exclude, rel) || matchAnyGlob(w.opts.Exclude, rel+"/")) {
+			return filepath.SkipDir
+		}
+
+		if target != "" {
+			if !w.opts.FollowSymlinks {
+				return nil
+			}
+			if w.visited[target] {
+				logf(LogVerbose, "skipping %s: already visited %s\n", filePath, target)
+				return nil
+			}
+			return w.walk(filePath, target)
+		}
+
+		if w.visited[walked] {
+			return filepath.SkipDir
+		}
+		w.visited[walked] = true
+		if w.ignores != nil {
+			w.ignores.loadGitignore(abs)
+		}
+		return nil
+	})
+}
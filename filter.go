@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Filters drops symbols and imports from a ParseResult before it is returned.
+// A nil pattern matches everything for Include* and nothing for Exclude*.
+type Filters struct {
+	IncludeSymbols *regexp.Regexp
+	ExcludeSymbols *regexp.Regexp
+	IncludeImports *regexp.Regexp
+	ExcludeImports *regexp.Regexp
+	// IgnoreImportPrefixes drops imports starting with any of these prefixes, e.g. "scala.".
+	IgnoreImportPrefixes []string
+}
+
+// WithFilters applies the given symbol and import filters to every result.
+func WithFilters(filters Filters) Option {
+	return func(p *treeSitterParser) {
+		p.filters = &filters
+	}
+}
+
+func (f *Filters) keepSymbol(name string) bool {
+	if f.IncludeSymbols != nil && !f.IncludeSymbols.MatchString(name) {
+		return false
+	}
+	return f.ExcludeSymbols == nil || !f.ExcludeSymbols.MatchString(name)
+}
+
+func (f *Filters) keepImport(imp string) bool {
+	for _, prefix := range f.IgnoreImportPrefixes {
+		if strings.HasPrefix(imp, prefix) {
+			return false
+		}
+	}
+	if f.IncludeImports != nil && !f.IncludeImports.MatchString(imp) {
+		return false
+	}
+	return f.ExcludeImports == nil || !f.ExcludeImports.MatchString(imp)
+}
+
+// apply filters the symbols, definitions and imports of result in place.
+func (f *Filters) apply(result *ParseResult) {
+	symbols := result.Symbols[:0]
+	for _, symbol := range result.Symbols {
+		if f.keepSymbol(symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	result.Symbols = symbols
+
+	definitions := result.Definitions[:0]
+	for _, definition := range result.Definitions {
+		if f.keepSymbol(definition.Name) {
+			definitions = append(definitions, definition)
+		}
+	}
+	result.Definitions = definitions
+
+	imports := result.Imports[:0]
+	for _, imp := range result.Imports {
+		if f.keepImport(imp) {
+			imports = append(imports, imp)
+		}
+	}
+	result.Imports = imports
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// compileFlagRegexp compiles the value of a regexp flag, returning nil if it is unset.
+func compileFlagRegexp(name, pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --%s pattern: %v\n", name, err)
+		os.Exit(exitUsage)
+	}
+	return re
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BuildOptions configure BUILD file generation.
+type BuildOptions struct {
+	// RepoRoot is the workspace root, which Bazel packages are relative to.
+	RepoRoot string
+	// RulesScala is the name of the rules_scala repository, e.g. `@io_bazel_rules_scala`.
+	RulesScala string
+	// Format is the build tool written for: bazel, pants or mill.
+	Format string
+}
+
+// buildFlavor is how BuildTargets are written to the BUILD files of a build tool.
+type buildFlavor struct {
+	// kinds maps the kinds of BuildTargets to the tool's rules.
+	kinds map[string]string
+	// srcs, deps and mainClass name the attributes rules are given.
+	srcs, deps, mainClass string
+	// label converts a Bazel label to the tool's, or reports there is none.
+	label func(label string) (string, bool)
+	// load is the file rules are loaded from, if they need loading.
+	load string
+	// visibility is given to new libraries, if set.
+	visibility []string
+}
+
+// flavor returns how targets are written to BUILD files for opts.Format.
+func (opts BuildOptions) flavor() buildFlavor {
+	if opts.Format == "pants" {
+		return pantsFlavor
+	}
+	return buildFlavor{
+		kinds:      map[string]string{"scala_binary": "scala_binary", "scala_library": "scala_library", "scala_test": "scala_test"},
+		srcs:       "srcs",
+		deps:       "deps",
+		mainClass:  "main_class",
+		label:      func(label string) (string, bool) { return label, true },
+		load:       opts.RulesScala + "//scala:scala.bzl",
+		visibility: []string{"//visibility:public"},
+	}
+}
+
+// ruleKind reports whether kind is one of the rules of the flavor.
+func (f buildFlavor) ruleKind(kind string) bool {
+	for _, ruleKind := range f.kinds {
+		if ruleKind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// scalaRuleKinds are the rules_scala rules generated.
+var scalaRuleKinds = []string{"scala_binary", "scala_library", "scala_test"}
+
+// BuildTarget is a rules_scala rule generated for the sources of a Bazel package.
+type BuildTarget struct {
+	// Package is the Bazel package, e.g. `core/src/main/scala/com/foo`.
+	Package   string
+	Kind      string
+	Name      string
+	Srcs      []string
+	Deps      []string
+	MainClass string
+	// Artifacts are the artifacts providing the sources' third-party imports,
+	// whether labels or not; see ParseResult.Artifacts.
+	Artifacts []string
+	// TestFramework is the package of the test framework a scala_test imports,
+	// e.g. `org.scalatest.`.
+	TestFramework string
+	// Visibility, if set, is the visibility a library is given; see SuggestVisibility.
+	Visibility []string
+}
+
+// Label returns the target's label, relative to the package from.
+func (t *BuildTarget) Label(from string) string {
+	switch {
+	case t.Package == from:
+		return ":" + t.Name
+	case path.Base(t.Package) == t.Name:
+		return "//" + t.Package
+	}
+	return "//" + t.Package + ":" + t.Name
+}
+
+// GenerateTargets returns the rules building results: for each directory, a
+// scala_library of its sources and a scala_test of its tests, and a scala_binary
+// for each main class of the library. Deps come from the files defining what
+// each file imports or references, and, when parsed WithArtifactIndex, from
+// the artifacts providing its third-party imports that are labels. Results
+// should come from a parser created WithIndex(index), for SamePackageRefs.
+func GenerateTargets(results []*ParseResult, index *Index, opts BuildOptions) []*BuildTarget {
+	root := absPath(opts.RepoRoot)
+	targets := make(map[string]*BuildTarget)
+	fileTargets := make(map[string]*BuildTarget)
+	var mains []string
+
+	sorted := append([]*ParseResult(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].File < sorted[j].File })
+
+	for _, result := range sorted {
+		if !strings.HasSuffix(result.File, ".scala") || isSbtBuildFile(result.File) {
+			continue
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(absPath(result.File)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			logf(LogDefault, "skipping %s: outside the repository root %s\n", result.File, opts.RepoRoot)
+			continue
+		}
+
+		pkg, name := filepath.ToSlash(rel), filepath.Base(rel)
+		if pkg == "." {
+			pkg, name = "", filepath.Base(root)
+		}
+		kind := "scala_library"
+		if isTestFile(result) {
+			kind, name = "scala_test", name+"_test"
+		}
+
+		key := pkg + ":" + name
+		target, ok := targets[key]
+		if !ok {
+			target = &BuildTarget{Package: pkg, Kind: kind, Name: name}
+			targets[key] = target
+		}
+		target.Srcs = append(target.Srcs, filepath.Base(result.File))
+		fileTargets[result.File] = target
+		if kind == "scala_test" && target.TestFramework == "" {
+			target.TestFramework = importedTestFramework(result)
+		}
+
+		if kind == "scala_library" {
+			for _, main := range result.MainClasses {
+				mains = append(mains, key+"\t"+main)
+			}
+		}
+	}
+
+	for _, result := range sorted {
+		target, ok := fileTargets[result.File]
+		if !ok {
+			continue
+		}
+		addDep := func(file string) {
+			dep, ok := fileTargets[file]
+			if !ok || dep == target || dep.Kind == "scala_test" {
+				return
+			}
+			if label := dep.Label(target.Package); !containsString(target.Deps, label) {
+				target.Deps = append(target.Deps, label)
+			}
+		}
+
+		for _, imp := range result.Imports {
+			for _, file := range importedFiles(index, imp) {
+				addDep(file)
+			}
+		}
+		for _, ref := range result.SamePackageRefs {
+			for _, file := range index.Files(ref) {
+				addDep(file)
+			}
+		}
+		for _, artifact := range result.Artifacts {
+			if !containsString(target.Artifacts, artifact) {
+				target.Artifacts = append(target.Artifacts, artifact)
+			}
+			if (strings.HasPrefix(artifact, "@") || strings.HasPrefix(artifact, "//")) && !containsString(target.Deps, artifact) {
+				target.Deps = append(target.Deps, artifact)
+			}
+		}
+	}
+
+	for _, main := range mains {
+		key, mainClass, _ := strings.Cut(main, "\t")
+		library := targets[key]
+		name := mainClass[strings.LastIndex(mainClass, ".")+1:]
+		if _, ok := targets[library.Package+":"+name]; ok {
+			name += "_bin"
+		}
+		targets[library.Package+":"+name] = &BuildTarget{
+			Package:   library.Package,
+			Kind:      "scala_binary",
+			Name:      name,
+			Deps:      []string{library.Label(library.Package)},
+			MainClass: mainClass,
+		}
+	}
+
+	generated := make([]*BuildTarget, 0, len(targets))
+	for _, target := range targets {
+		sort.Strings(target.Srcs)
+		sort.Strings(target.Artifacts)
+		sort.Slice(target.Deps, func(i, j int) bool { return compareLabels(target.Deps[i], target.Deps[j]) })
+		generated = append(generated, target)
+	}
+	kindOrder := map[string]int{"scala_library": 0, "scala_binary": 1, "scala_test": 2}
+	sort.Slice(generated, func(i, j int) bool {
+		a, b := generated[i], generated[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Kind != b.Kind {
+			return kindOrder[a.Kind] < kindOrder[b.Kind]
+		}
+		return a.Name < b.Name
+	})
+	return generated
+}
+
+// buildFilePath returns the BUILD file of pkg: an existing BUILD.bazel or BUILD
+// file, or else a new file called name.
+func buildFilePath(root, pkg, name string) string {
+	dir := filepath.Join(root, filepath.FromSlash(pkg))
+	for _, existing := range []string{"BUILD.bazel", "BUILD"} {
+		if info, err := os.Stat(filepath.Join(dir, existing)); err == nil && !info.IsDir() {
+			return filepath.Join(dir, existing)
+		}
+	}
+	return filepath.Join(dir, name)
+}
+
+// mergeBuildFile updates the BUILD file src with targets, all of one package;
+// see updateBuildFile.
+func mergeBuildFile(src string, targets []*BuildTarget, opts BuildOptions) (string, error) {
+	file, err := parseBuildFile(src)
+	if err != nil {
+		return "", err
+	}
+	if !updateBuildFile(file, targets, opts) {
+		// NOTE: formatting would reorder attributes of rules that were skipped.
+		return src, nil
+	}
+	return file.String(), nil
+}
+
+// updateBuildFile adds targets, all of one package, to file. Rules of the same
+// name are updated in place: their srcs, deps and main_class are replaced,
+// except for items, attributes or whole rules marked `# keep`, and their other
+// attributes are left as they are. Rules that are not generated are left alone.
+// It reports whether any rule was added or updated.
+func updateBuildFile(file *buildFile, targets []*BuildTarget, opts BuildOptions) bool {
+	flavor := opts.flavor()
+	changed := false
+	var missingLoads []string
+	for _, target := range targets {
+		kind := flavor.kinds[target.Kind]
+		rule := file.rule(target.Name)
+		isNew := rule == nil
+		switch {
+		case isNew:
+			rule = newRule(kind, target.Name)
+		case isKeptRule(rule):
+			continue
+		case !flavor.ruleKind(rule.Kind()):
+			logf(LogDefault, "skipping %s: a %s rule already has the name\n", target.Label(""), rule.Kind())
+			continue
+		}
+
+		var deps []string
+		for _, dep := range target.Deps {
+			if label, ok := flavor.label(dep); ok {
+				deps = append(deps, label)
+			}
+		}
+
+		changed = true
+		rule.SetKind(kind)
+		setListAttr(rule, flavor.srcs, target.Srcs)
+		setStringAttr(rule, flavor.mainClass, target.MainClass)
+		setListAttr(rule, flavor.deps, deps)
+
+		if target.Visibility != nil && flavor.visibility != nil {
+			setListAttr(rule, "visibility", target.Visibility)
+		}
+
+		if isNew {
+			if target.Kind == "scala_library" && flavor.visibility != nil && target.Visibility == nil {
+				setListAttr(rule, "visibility", flavor.visibility)
+			}
+			file.addRule(rule)
+		}
+		if flavor.load != "" && !file.loaded(kind) && !containsString(missingLoads, kind) {
+			missingLoads = append(missingLoads, kind)
+		}
+	}
+
+	if len(missingLoads) > 0 {
+		file.addLoad(flavor.load, missingLoads...)
+	}
+	return changed
+}
+
+// importedTestFramework returns the package of the first test framework
+// result imports, or "".
+func importedTestFramework(result *ParseResult) string {
+	for _, imp := range result.Imports {
+		for _, framework := range testFrameworks {
+			if strings.HasPrefix(imp, framework) {
+				return framework
+			}
+		}
+	}
+	return ""
+}
+
+// runGenerateCommand implements `generate`, which writes rules_scala rules for
+// the sources beneath the given directories into the BUILD files of their
+// packages, or with --format Pants targets into BUILD files or Mill modules to
+// stdout.
+func runGenerateCommand(args []string) {
+	f := newParseFlags("generate", "[flags] <file or directory>...")
+	var opts BuildOptions
+	f.StringVar(&opts.RepoRoot, "repo-root", ".", "the workspace root, which Bazel packages are relative to")
+	f.StringVar(&opts.RulesScala, "rules-scala", "@io_bazel_rules_scala", "name of the rules_scala repository to load rules from")
+	f.StringVar(&opts.Format, "format", "bazel", "build tool to generate for: bazel, pants, or mill to print a build.mill of modules")
+	buildFileName := f.String("build-file-name", "", "name of new BUILD files, BUILD.bazel for bazel and BUILD for pants; existing BUILD and BUILD.bazel files are updated")
+	scalaVersion := f.String("scala-version", "2.13.14", "Scala version of generated Mill modules")
+	visibility := f.Bool("visibility", false, "give libraries the narrowest visibility their dependents need, rather than public")
+	buildozer := f.Bool("buildozer", false, "print buildozer commands making the changes, for `buildozer -f -`, instead of rewriting BUILD files")
+	codemod := addCodemodFlags(f)
+	codemod.create = true
+	f.parse(args)
+	files := f.files()
+
+	switch {
+	case opts.Format != "bazel" && opts.Format != "pants" && opts.Format != "mill":
+		fmt.Fprintf(os.Stderr, "--format: unknown build tool %q\n", opts.Format)
+		os.Exit(exitUsage)
+	case *buildozer && opts.Format != "bazel":
+		fmt.Fprintln(os.Stderr, "--buildozer: only supported for bazel")
+		os.Exit(exitUsage)
+	case *buildFileName == "" && opts.Format == "pants":
+		*buildFileName = "BUILD"
+	case *buildFileName == "":
+		*buildFileName = "BUILD.bazel"
+	}
+
+	// NOTE: SamePackageRefs needs every file in the index first, so parse twice.
+	index := NewIndex()
+	f.parseFiles(files, index.Add)
+	var results []*ParseResult
+	f.parseFiles(files, func(result *ParseResult) {
+		results = append(results, result)
+	}, WithIndex(index))
+
+	targets := GenerateTargets(results, index, opts)
+	if *visibility {
+		for target, suggested := range SuggestVisibility(targets) {
+			target.Visibility = suggested
+		}
+	}
+	if opts.Format == "mill" {
+		if err := writeMillModules(os.Stdout, targets, *scalaVersion); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	packages := make(map[string][]*BuildTarget)
+	var buildFiles []string
+	for _, target := range targets {
+		buildFile := buildFilePath(opts.RepoRoot, target.Package, *buildFileName)
+		if _, ok := packages[buildFile]; !ok {
+			buildFiles = append(buildFiles, buildFile)
+		}
+		packages[buildFile] = append(packages[buildFile], target)
+	}
+
+	if *buildozer {
+		for _, buildFile := range buildFiles {
+			sourceCode, err := os.ReadFile(buildFile)
+			if errors.Is(err, fs.ErrNotExist) {
+				logf(LogDefault, "%s does not exist: create it before running buildozer\n", buildFile)
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", buildFile, err)
+				setExitCode(exitInternal)
+				continue
+			}
+			commands, err := buildozerCommands(string(sourceCode), packages[buildFile], opts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: reading BUILD file: %v\n", buildFile, err)
+				setExitCode(exitInternal)
+				continue
+			}
+			for _, command := range commands {
+				fmt.Println(command)
+			}
+		}
+		return
+	}
+
+	codemod.rewriteFiles(buildFiles, func(filePath string, sourceCode []byte) ([]byte, error) {
+		merged, err := mergeBuildFile(string(sourceCode), packages[filePath], opts)
+		if err != nil {
+			return nil, fmt.Errorf("reading BUILD file: %w", err)
+		}
+		return []byte(merged), nil
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepo is the git repository containing the working directory.
+type gitRepo struct {
+	// root is the absolute path of the working tree.
+	root string
+}
+
+// openGitRepo returns the git repository containing the working directory.
+func openGitRepo() (*gitRepo, error) {
+	// NOTE: --show-cdup rather than --show-toplevel, which resolves symlinks, so
+	// root is comparable with the absolute paths of files named on the command line.
+	out, err := (&gitRepo{root: "."}).run("rev-parse", "--show-cdup")
+	if err != nil {
+		return nil, err
+	}
+	return &gitRepo{root: absPath(strings.TrimSpace(string(out)))}, nil
+}
+
+// run runs git with args in the root of the working tree, returning its
+// stdout, or its stderr as the error if it fails.
+func (r *gitRepo) run(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// changedFiles returns the absolute paths of the files that differ between ref
+// and the working tree, selected by filter as by `git diff --diff-filter`, e.g.
+// d for every file but deleted ones. Renames count as a deletion and an
+// addition. With untracked, files git does not track, unless ignored, are
+// included too.
+func (r *gitRepo) changedFiles(ref, filter string, untracked bool) (map[string]bool, error) {
+	out, err := r.run("diff", "--name-only", "-z", "--no-renames", "--diff-filter="+filter, ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	if untracked {
+		others, err := r.run("ls-files", "--others", "--exclude-standard", "-z")
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, others...)
+	}
+
+	files := make(map[string]bool)
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files[filepath.Join(r.root, filepath.FromSlash(name))] = true
+		}
+	}
+	return files, nil
+}
+
+// show returns the contents of file, a path in the working tree, at ref.
+func (r *gitRepo) show(ref, file string) ([]byte, error) {
+	rel, err := filepath.Rel(r.root, absPath(file))
+	if err != nil {
+		return nil, err
+	}
+	return r.run("show", ref+":"+filepath.ToSlash(rel))
+}
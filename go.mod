@@ -4,6 +4,15 @@ go 1.21.5
 
 require (
 	aspect.build/cli v1.508.19
+	github.com/bazelbuild/buildtools v0.0.0-20240918101019-be1c24cc9a44
 	github.com/emirpasic/gods v1.18.1
+	github.com/redis/go-redis/v9 v9.7.3
 	github.com/smacker/go-tree-sitter v0.0.0-20231219031718-233c2f923ac7
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 )
@@ -0,0 +1,112 @@
+package main
+
+/*
+#include <stdint.h>
+
+const void *tree_sitter_scala(void);
+uint32_t ts_language_version(const void *language);
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"unsafe"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// grammarEnv names a grammar to load in place of the bundled one, as --grammar
+// does, for every command.
+const grammarEnv = "SCALA_TREE_PARSER_GRAMMAR"
+
+// The tree-sitter ABI versions of grammars the linked runtime can load, from
+// its api.h.
+const (
+	minGrammarABIVersion = 13
+	maxGrammarABIVersion = 14
+)
+
+// grammarModule is the module the bundled grammar is compiled from.
+const grammarModule = "github.com/smacker/go-tree-sitter"
+
+// loadedGrammar is the path of the grammar ScalaLang was loaded from, or "" for
+// the bundled grammar, and loadedGrammarABI its ABI version.
+var (
+	loadedGrammar    string
+	loadedGrammarABI uint32
+)
+
+// grammarABIVersion returns the tree-sitter ABI version of the grammar
+// language, a TSLanguage pointer.
+func grammarABIVersion(language unsafe.Pointer) uint32 {
+	return uint32(C.ts_language_version(language))
+}
+
+// bundledGrammarVersion returns the version of the module the bundled grammar
+// is compiled from, or "unknown" if the binary does not record it.
+func bundledGrammarVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == grammarModule {
+				if dep.Replace != nil {
+					dep = dep.Replace
+				}
+				return dep.Version
+			}
+		}
+	}
+	return "unknown"
+}
+
+// describeGrammar describes the grammar in use, for `version`.
+func describeGrammar() string {
+	if loadedGrammar != "" {
+		return fmt.Sprintf("%s (ABI %d)", loadedGrammar, loadedGrammarABI)
+	}
+	abi := grammarABIVersion(unsafe.Pointer(C.tree_sitter_scala()))
+	return fmt.Sprintf("bundled from %s %s (ABI %d)", grammarModule, bundledGrammarVersion(), abi)
+}
+
+// loadGrammar replaces ScalaLang, the grammar every parse uses, with the
+// tree-sitter-scala grammar compiled to the shared library at path, which must
+// export `tree_sitter_scala` like the grammar's own bindings. Grammars compiled
+// to WASM are refused: the linked runtime can only load native code.
+func loadGrammar(path string) error {
+	if strings.HasSuffix(path, ".wasm") {
+		return fmt.Errorf("%s: WASM grammars are not supported by this build; use a shared library", path)
+	}
+
+	language, err := openGrammarLibrary(path, "tree_sitter_"+ScalaTreeSitterName)
+	if err != nil {
+		return err
+	}
+	abi := grammarABIVersion(language)
+	if abi < minGrammarABIVersion || abi > maxGrammarABIVersion {
+		return fmt.Errorf("%s: grammar ABI version %d is not supported, expected %d to %d", path, abi, minGrammarABIVersion, maxGrammarABIVersion)
+	}
+
+	ScalaLang = sitter.NewLanguage(language)
+	loadedGrammar, loadedGrammarABI = path, abi
+	return nil
+}
+
+// grammarInput returns sourceCode with the constructs the grammar cannot lex
+// masked, keeping every byte offset, so the parsed tree still locates nodes in
+// sourceCode.
+func grammarInput(sourceCode []byte) []byte {
+	return maskSymbolLiterals(maskUnicodeIdentifiers(sourceCode))
+}
+
+// loadGrammarFromEnv loads the grammar named by grammarEnv, if any, exiting
+// with exitUsage if it cannot be loaded.
+func loadGrammarFromEnv() {
+	if path := os.Getenv(grammarEnv); path != "" {
+		if err := loadGrammar(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", grammarEnv, err)
+			os.Exit(exitUsage)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// openGrammarLibrary is unsupported on platforms without dlopen.
+func openGrammarLibrary(path, symbol string) (unsafe.Pointer, error) {
+	return nil, fmt.Errorf("%s: loading grammars is not supported on %s", path, runtime.GOOS)
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package main
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+
+typedef const void *(*language_func)(void);
+
+static const void *load_language(const char *path, const char *symbol, const char **err) {
+	void *library = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (library == NULL) {
+		*err = dlerror();
+		return NULL;
+	}
+	language_func language = (language_func)dlsym(library, symbol);
+	if (language == NULL) {
+		*err = dlerror();
+		return NULL;
+	}
+	return language();
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// openGrammarLibrary loads the shared library at path, returning the language
+// its function symbol returns. The library is never unloaded.
+func openGrammarLibrary(path, symbol string) (unsafe.Pointer, error) {
+	cPath, cSymbol := C.CString(path), C.CString(symbol)
+	defer C.free(unsafe.Pointer(cPath))
+	defer C.free(unsafe.Pointer(cSymbol))
+
+	var cErr *C.char
+	language := C.load_language(cPath, cSymbol, &cErr)
+	if language == nil {
+		return nil, fmt.Errorf("loading grammar: %s", C.GoString(cErr))
+	}
+	return unsafe.Pointer(language), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// defaultMaxFileSize is the default of --max-file-size. Larger sources are
+// almost always generated, and slow to parse for little benefit.
+const defaultMaxFileSize = 5 << 20
+
+// binarySniffLength is how much of a file is checked for NUL bytes.
+const binarySniffLength = 8 << 10
+
+// skipReason returns why a file should not be parsed, or "" if it should be:
+// it is larger than maxSize (if positive), binary, or not valid UTF-8.
+func skipReason(size int64, sourceCode []byte, maxSize int64) string {
+	if maxSize > 0 && size > maxSize {
+		return fmt.Sprintf("%d bytes is over the %d byte limit", size, maxSize)
+	}
+
+	sniff := sourceCode
+	if len(sniff) > binarySniffLength {
+		sniff = sniff[:binarySniffLength]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return "binary file"
+	}
+
+	if !utf8.Valid(sourceCode) {
+		return "not valid UTF-8 (see --encoding)"
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// SealedHierarchy is a sealed trait or class together with its direct subtypes
+// defined in the same file.
+type SealedHierarchy struct {
+	Root     string
+	Subtypes []string
+}
+
+// readModifiers returns the modifier keywords of a definition, e.g. `sealed` or
+// `implicit`, including the `case` of case classes and objects.
+func readModifiers(node *sitter.Node, sourceCode []byte) []string {
+	modifiers := make([]string, 0)
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if child := node.Child(i); child.Type() == "case" {
+			modifiers = append(modifiers, "case")
+		}
+	}
+
+	if mods := getLoneChild(node, "modifiers"); mods != nil {
+		for i := 0; i < int(mods.ChildCount()); i++ {
+			if child := mods.Child(i); child.Type() != "access_modifier" {
+				modifiers = append(modifiers, child.Content(sourceCode))
+			}
+		}
+	}
+
+	return modifiers
+}
+
+// readParents returns the types a definition extends, as written but without
+// type arguments, e.g. `Shape` or `foo.Shape`.
+func readParents(node *sitter.Node, sourceCode []byte) []string {
+	parents := make([]string, 0)
+
+	extends := getLoneChild(node, "extends_clause")
+	if extends == nil {
+		return parents
+	}
+
+	for i := 0; i < int(extends.NamedChildCount()); i++ {
+		child := extends.NamedChild(i)
+		if child.Type() == "arguments" {
+			continue
+		}
+		parents = append(parents, readTypeNames(child, sourceCode)...)
+	}
+
+	return parents
+}
+
+func readTypeNames(node *sitter.Node, sourceCode []byte) []string {
+	switch node.Type() {
+	case "type_identifier", "stable_type_identifier":
+		return []string{node.Content(sourceCode)}
+	case "generic_type":
+		return readTypeNames(node.ChildByFieldName("type"), sourceCode)
+	case "compound_type":
+		names := make([]string, 0)
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			names = append(names, readTypeNames(node.NamedChild(i), sourceCode)...)
+		}
+		return names
+	}
+
+	return []string{}
+}
+
+// readSealedHierarchies matches sealed definitions to the definitions in the
+// file that directly extend them.
+func readSealedHierarchies(symbols []Symbol) []SealedHierarchy {
+	hierarchies := make([]SealedHierarchy, 0)
+
+	for _, root := range symbols {
+		if !containsString(root.Modifiers, "sealed") {
+			continue
+		}
+
+		rootName := root.Name[strings.LastIndex(root.Name, ".")+1:]
+		hierarchy := SealedHierarchy{Root: root.Name, Subtypes: make([]string, 0)}
+		for _, symbol := range symbols {
+			for _, parent := range symbol.Parents {
+				if parent == rootName || parent == root.Name {
+					hierarchy.Subtypes = append(hierarchy.Subtypes, symbol.Name)
+					break
+				}
+			}
+		}
+
+		hierarchies = append(hierarchies, hierarchy)
+	}
+
+	return hierarchies
+}
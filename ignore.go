@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore or .bazelignore file.
+type ignoreRule struct {
+	// base is the absolute directory the pattern is relative to.
+	base    string
+	pattern string
+	negate  bool
+	dirOnly bool
+	// anchored patterns match from base; others match at any depth beneath it.
+	anchored bool
+}
+
+// ignoreMatcher applies the .gitignore and .bazelignore rules seen so far
+// during a traversal. As in git, the last matching rule wins.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// newIgnoreMatcher returns a matcher for a traversal of root, preloaded with the
+// .gitignore files of root's ancestors within the same git repository and the
+// .bazelignore file at the root of its Bazel workspace.
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return m
+	}
+
+	var ancestors []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		ancestors = append(ancestors, dir)
+		if fileExists(filepath.Join(dir, ".git")) {
+			for i := len(ancestors) - 1; i >= 0; i-- {
+				m.loadGitignore(ancestors[i])
+			}
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if isWorkspaceRoot(dir) {
+			m.loadBazelignore(dir)
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return m
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func isWorkspaceRoot(dir string) bool {
+	for _, name := range []string{"WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel"} {
+		if fileExists(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readIgnoreLines returns the non-blank, non-comment lines of an ignore file.
+func readIgnoreLines(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// loadGitignore adds the rules of dir/.gitignore, if there is one.
+func (m *ignoreMatcher) loadGitignore(dir string) {
+	for _, line := range readIgnoreLines(filepath.Join(dir, ".gitignore")) {
+		rule := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// NOTE: a slash anywhere but the end anchors the pattern to its .gitignore.
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// loadBazelignore adds the directories listed in dir/.bazelignore, which are
+// plain paths relative to the workspace root rather than patterns.
+func (m *ignoreMatcher) loadBazelignore(dir string) {
+	for _, line := range readIgnoreLines(filepath.Join(dir, ".bazelignore")) {
+		m.rules = append(m.rules, ignoreRule{
+			base:     dir,
+			pattern:  strings.Trim(filepath.ToSlash(line), "/"),
+			anchored: true,
+		})
+	}
+}
+
+// ignored reports whether the file or directory at the absolute path is ignored.
+func (m *ignoreMatcher) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		rel, err := filepath.Rel(rule.base, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		pattern := rule.pattern
+		if !rule.anchored {
+			pattern = "**/" + pattern
+		}
+		if matchGlob(pattern, rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
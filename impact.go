@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// fileDependencies returns the files defining what result imports or references
+// from its own package, other than result's file itself.
+func fileDependencies(result *ParseResult, index *Index) []string {
+	var files []string
+	add := func(file string) {
+		if file != result.File && !containsString(files, file) {
+			files = append(files, file)
+		}
+	}
+	for _, imp := range result.Imports {
+		for _, file := range importedFiles(index, imp) {
+			add(file)
+		}
+	}
+	for _, ref := range result.SamePackageRefs {
+		for _, file := range index.Files(ref) {
+			add(file)
+		}
+	}
+	return files
+}
+
+// AffectedFiles returns the files among results affected by changes to the
+// files changed: the changed files themselves and every file depending on them,
+// directly or transitively, sorted. Results should come from a parser created
+// WithIndex(index), so references within a package are followed too.
+func AffectedFiles(results []*ParseResult, index *Index, changed []string) []string {
+	// NOTE: files are matched by absolute path, since changed files are usually
+	// relative to the repository root rather than to the parsed directories.
+	files := make(map[string]string)
+	dependents := make(map[string][]string)
+	for _, result := range results {
+		files[absPath(result.File)] = result.File
+		for _, dep := range fileDependencies(result, index) {
+			dependents[dep] = append(dependents[dep], result.File)
+		}
+	}
+
+	affected := make(map[string]bool)
+	var queue []string
+	for _, file := range changed {
+		if file, ok := files[absPath(file)]; ok && !affected[file] {
+			affected[file] = true
+			queue = append(queue, file)
+		}
+	}
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[file] {
+			if !affected[dependent] {
+				affected[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(affected))
+	for file := range affected {
+		sorted = append(sorted, file)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// readChangedFiles returns the file names listed one per line in path, or on
+// stdin for -, as `git diff --name-only` prints them.
+func readChangedFiles(path string) ([]string, error) {
+	in := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var changed []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			changed = append(changed, line)
+		}
+	}
+	return changed, scanner.Err()
+}
+
+// runImpactCommand implements `impact`, which prints the test files affected
+// by changes to the files listed by --changed, or changed since the ref named by
+// --git-diff, so CI can run only those tests.
+// With --packages, the affected packages are printed instead.
+func runImpactCommand(args []string) {
+	f := newParseFlags("impact", "[flags] --changed=<file> <file or directory>...")
+	var changed stringList
+	f.Var(&changed, "changed", "a changed file (repeatable)")
+	changedFrom := f.String("changed-from", "", "read changed files one per line from this file, or - for stdin, e.g. from `git diff --name-only`")
+	packages := f.Bool("packages", false, "print the affected packages rather than test files")
+	f.parse(args)
+
+	if *changedFrom != "" {
+		listed, err := readChangedFiles(*changedFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--changed-from: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		changed = append(changed, listed...)
+	}
+	if f.gitDiff != "" {
+		// NOTE: the changed files are only where the walk starts; every file must
+		// still be parsed to find their dependents.
+		for file := range f.changedSince() {
+			changed = append(changed, file)
+		}
+		f.gitDiff = ""
+	}
+	files := f.files()
+
+	// NOTE: SamePackageRefs needs every file in the index first, so parse twice.
+	index := NewIndex()
+	f.parseFiles(files, index.Add)
+	results := make(map[string]*ParseResult)
+	var parsed []*ParseResult
+	f.parseFiles(files, func(result *ParseResult) {
+		results[result.File] = result
+		parsed = append(parsed, result)
+	}, WithIndex(index))
+
+	var affectedPackages []string
+	for _, file := range AffectedFiles(parsed, index, changed) {
+		result := results[file]
+		if *packages {
+			affectedPackages = append(affectedPackages, result.Package)
+		} else if isTestFile(result) {
+			fmt.Println(file)
+		}
+	}
+	for _, pkg := range sortedUnique(affectedPackages) {
+		fmt.Println(pkg)
+	}
+}
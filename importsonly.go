@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// WithImportsOnly extracts only the package and imports of each file, skipping
+// symbols and everything derived from them. Resolving dependencies usually needs
+// nothing more, and it saves the walk over every definition.
+func WithImportsOnly() Option {
+	return func(p *treeSitterParser) {
+		p.importsOnly = true
+	}
+}
+
+// importsOnlyQuery matches the declarations read by readImportsOnly. A query is
+// immutable once compiled, so one is shared by every parse.
+var importsOnlyQuery = sync.OnceValue(func() *sitter.Query {
+	query, err := sitter.NewQuery([]byte(`[(package_clause) (import_declaration)] @declaration`), ScalaLang)
+	if err != nil {
+		panic(err)
+	}
+	return query
+})
+
+// readImportsOnly fills in the package, imports and script dependencies of result
+// from the declarations matched by importsOnlyQuery, without walking the rest of
+// the tree. Imports nested beneath topLevel are only kept under ImportScopeAll.
+// It returns the errors of declarations it could not read.
+func (p *treeSitterParser) readImportsOnly(topLevel *sitter.Node, sourceCode []byte, result *ParseResult) []error {
+	resolver := newImportResolver()
+	var errs []error
+
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(importsOnlyQuery(), topLevel)
+
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			return errs
+		}
+
+		for _, capture := range match.Captures {
+			node := capture.Node
+			topLevelChild := topLevel.Equal(node.Parent())
+
+			if node.Type() == "package_clause" {
+				if topLevelChild {
+					if err := addPackageClause(node, sourceCode, result, resolver); err != nil {
+						errs = append(errs, err)
+					}
+				}
+				continue
+			}
+
+			if !topLevelChild {
+				// NOTE: as in a full extraction, nested imports are not resolved.
+				if p.importScope == ImportScopeAll {
+					imports, err := readImportDeclaration(node, sourceCode, result.Dialect)
+					if err != nil {
+						errs = append(errs, err)
+					}
+					for _, imp := range imports {
+						result.Imports = append(result.Imports, p.normalizeImport(imp))
+					}
+				}
+				continue
+			}
+
+			if deps, magic := readMagicImports(node, sourceCode); magic {
+				result.ScriptDeps = append(result.ScriptDeps, deps...)
+				continue
+			}
+			if _, err := p.addImportDeclaration(node, sourceCode, result, resolver); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+}
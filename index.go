@@ -0,0 +1,134 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Index is a workspace-wide symbol index built from ParseResults. It maps each
+// fully-qualified symbol to the files defining it, and is safe for concurrent use.
+type Index struct {
+	mu sync.RWMutex
+
+	// files maps a fully-qualified symbol to the files that define it.
+	files map[string][]string
+	// members maps a package or object to the fully-qualified symbols directly
+	// inside it.
+	members map[string][]string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		files:   make(map[string][]string),
+		members: make(map[string][]string),
+	}
+}
+
+// Add records the symbols defined by result.
+func (idx *Index) Add(result *ParseResult) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, symbol := range result.Symbols {
+		idx.addLocked(qualify(result.Package, symbol), result.File)
+	}
+}
+
+// add records that file defines the fully-qualified symbol.
+func (idx *Index) add(symbol, file string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.addLocked(symbol, file)
+}
+
+func (idx *Index) addLocked(symbol, file string) {
+	if _, ok := idx.files[symbol]; !ok {
+		owner := ""
+		if i := strings.LastIndex(symbol, "."); i >= 0 {
+			owner = symbol[:i]
+		}
+		idx.members[owner] = append(idx.members[owner], symbol)
+	}
+	if !containsString(idx.files[symbol], file) {
+		idx.files[symbol] = append(idx.files[symbol], file)
+	}
+}
+
+// Files returns the files defining the fully-qualified symbol.
+func (idx *Index) Files(symbol string) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.files[symbol]
+}
+
+// Members returns the fully-qualified symbols directly inside a package or
+// object, in sorted order.
+func (idx *Index) Members(owner string) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	members := append([]string(nil), idx.members[owner]...)
+	sort.Strings(members)
+	return members
+}
+
+// Defines reports whether the index defines what imp imports: the symbol itself
+// or, for an import of an object member, its object. A wildcard or package import
+// is defined if the package has indexed members.
+func (idx *Index) Defines(imp string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if owner, ok := strings.CutSuffix(imp, "._"); ok {
+		return len(idx.members[owner]) > 0
+	} else if owner, ok := strings.CutSuffix(imp, ".*"); ok {
+		return len(idx.members[owner]) > 0
+	}
+
+	if len(idx.members[imp]) > 0 {
+		return true
+	}
+	for name := imp; name != ""; {
+		if len(idx.files[name]) > 0 {
+			return true
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return false
+}
+
+// Symbols returns every fully-qualified symbol in the index, in sorted order.
+func (idx *Index) Symbols() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	symbols := make([]string, 0, len(idx.files))
+	for symbol := range idx.files {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func qualify(pkg, symbol string) string {
+	if pkg == "" {
+		return symbol
+	}
+	return pkg + "." + symbol
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
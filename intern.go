@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync"
+)
+
+// interner deduplicates strings repeated across files, such as packages and
+// common imports like `scala.concurrent.Future`, so each is only held in memory
+// once however many results refer to it. It is safe for concurrent use.
+type interner struct {
+	mu      sync.RWMutex
+	strings map[string]string
+}
+
+func newInterner() *interner {
+	return &interner{strings: make(map[string]string)}
+}
+
+func (in *interner) intern(s string) string {
+	in.mu.RLock()
+	interned, ok := in.strings[s]
+	in.mu.RUnlock()
+	if ok {
+		return interned
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if interned, ok := in.strings[s]; ok {
+		return interned
+	}
+	in.strings[s] = s
+	return s
+}
+
+func (in *interner) internAll(values []string) {
+	for i, value := range values {
+		values[i] = in.intern(value)
+	}
+}
+
+// internResult interns the strings of result most likely to repeat across files.
+func (in *interner) internResult(result *ParseResult) {
+	result.Package = in.intern(result.Package)
+	in.internAll(result.Imports)
+	for i := range result.Definitions {
+		in.internAll(result.Definitions[i].Parents)
+	}
+}
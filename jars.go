@@ -0,0 +1,204 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ArtifactIndex maps the top-level classes and objects of third-party jars to
+// the artifacts providing them: the jars themselves, or the Bazel labels they
+// were indexed under. It is built by index-jars.
+type ArtifactIndex struct {
+	// symbols maps a fully-qualified top-level symbol to its artifacts.
+	symbols map[string][]string
+	// packages maps a package to the artifacts with symbols directly inside it.
+	packages map[string][]string
+}
+
+func NewArtifactIndex() *ArtifactIndex {
+	return &ArtifactIndex{
+		symbols:  make(map[string][]string),
+		packages: make(map[string][]string),
+	}
+}
+
+// Add records that artifact provides symbol.
+func (idx *ArtifactIndex) Add(symbol, artifact string) {
+	if !containsString(idx.symbols[symbol], artifact) {
+		idx.symbols[symbol] = append(idx.symbols[symbol], artifact)
+	}
+	pkg := ""
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		pkg = symbol[:i]
+	}
+	if !containsString(idx.packages[pkg], artifact) {
+		idx.packages[pkg] = append(idx.packages[pkg], artifact)
+	}
+}
+
+// Resolve returns the artifacts providing what imp imports: the artifacts of the
+// longest indexed prefix of imp, so members of an object or package object
+// resolve to its artifact, or for a wildcard or package import every artifact
+// with symbols in the package.
+func (idx *ArtifactIndex) Resolve(imp string) []string {
+	name := strings.TrimSuffix(strings.TrimSuffix(imp, "._"), ".*")
+
+	for prefix := name; prefix != ""; {
+		if artifacts := idx.symbols[prefix]; len(artifacts) > 0 {
+			return artifacts
+		}
+		if prefix == name {
+			if artifacts := idx.packages[prefix]; len(artifacts) > 0 {
+				return artifacts
+			}
+		} else if artifacts := idx.symbols[prefix+".package"]; len(artifacts) > 0 {
+			return artifacts
+		}
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return nil
+}
+
+// Write writes the index as `symbol<TAB>artifact` lines, sorted by symbol.
+func (idx *ArtifactIndex) Write(w io.Writer) error {
+	symbols := make([]string, 0, len(idx.symbols))
+	for symbol := range idx.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	out := bufio.NewWriter(w)
+	for _, symbol := range symbols {
+		for _, artifact := range idx.symbols[symbol] {
+			fmt.Fprintf(out, "%s\t%s\n", symbol, artifact)
+		}
+	}
+	return out.Flush()
+}
+
+// ReadArtifactIndex reads an index written by ArtifactIndex.Write.
+func ReadArtifactIndex(path string) (*ArtifactIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	idx := NewArtifactIndex()
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		symbol, artifact, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected `symbol<TAB>artifact`", path, lineNumber)
+		}
+		idx.Add(symbol, artifact)
+	}
+	return idx, scanner.Err()
+}
+
+// classSymbol returns the top-level symbol compiled to the class file at name,
+// a path within a jar. Nested and anonymous classes have none, while an object's
+// `Foo$` class is Foo. Package objects, and Scala 3's `Foo$package` classes of
+// top-level definitions, are recorded as the `package` member of their package.
+func classSymbol(name string) (string, bool) {
+	name, ok := strings.CutSuffix(name, ".class")
+	if !ok || strings.HasPrefix(name, "META-INF/") {
+		return "", false
+	}
+	name = strings.TrimSuffix(name, "$")
+	if strings.HasSuffix(name, "$package") {
+		pkg := tastySymbol(name)
+		return pkg + ".package", pkg != ""
+	}
+	if strings.Contains(name, "$") {
+		return "", false
+	}
+
+	symbol := strings.ReplaceAll(name, "/", ".")
+	pkg, simpleName := "", symbol
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		pkg, simpleName = symbol[:i], symbol[i+1:]
+	}
+	switch simpleName {
+	case "module-info", "package-info":
+		return "", false
+	case "package":
+		return symbol, pkg != ""
+	}
+	return symbol, true
+}
+
+// IndexJar adds the top-level symbols of the class files in the jar at path to
+// idx, as provided by artifact.
+func (idx *ArtifactIndex) IndexJar(path, artifact string) error {
+	jar, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer jar.Close()
+
+	for _, entry := range jar.File {
+		if symbol, ok := classSymbol(entry.Name); ok {
+			idx.Add(symbol, artifact)
+		}
+	}
+	return nil
+}
+
+// WithArtifactIndex resolves the third-party imports of each file against idx,
+// reporting the artifacts providing them as ParseResult.Artifacts.
+func WithArtifactIndex(idx *ArtifactIndex) Option {
+	return func(p *treeSitterParser) {
+		p.artifacts = idx
+	}
+}
+
+// resolveArtifacts returns the sorted artifacts providing the third-party
+// imports of result.
+func (p *treeSitterParser) resolveArtifacts(result *ParseResult) []string {
+	var artifacts []string
+	for _, imp := range result.ImportGroups.ThirdParty {
+		for _, artifact := range p.artifacts.Resolve(imp) {
+			if !containsString(artifacts, artifact) {
+				artifacts = append(artifacts, artifact)
+			}
+		}
+	}
+	sort.Strings(artifacts)
+	return artifacts
+}
+
+// runIndexJarsCommand implements `index-jars [label=]<jar>...`, printing the
+// artifact index of the jars for `--artifacts`. Each jar is recorded under its
+// path, or under the label given before it, e.g. `@maven//:com_google_guava_guava=guava.jar`.
+func runIndexJarsCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: index-jars [label=]<jar>...")
+		os.Exit(exitUsage)
+	}
+
+	idx := NewArtifactIndex()
+	for _, arg := range args {
+		artifact, path := arg, arg
+		if label, jar, ok := strings.Cut(arg, "="); ok {
+			artifact, path = label, jar
+		}
+		if err := idx.IndexJar(path, artifact); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			setExitCode(exitInternal)
+		}
+	}
+
+	if err := idx.Write(os.Stdout); err != nil {
+		panic(err)
+	}
+}
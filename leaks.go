@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// QualifiedPrivate is a definition only visible within an enclosing package or
+// object, e.g. `private[foo] class Bar`. Definitions leaves these out, like every
+// definition with an access modifier.
+type QualifiedPrivate struct {
+	// Name is the definition's name relative to the file's package, as in Symbol.
+	Name string
+	Line int
+	// Modifier is `private` or `protected`, and Qualifier the package or object it
+	// is visible within, e.g. `foo`.
+	Modifier  string
+	Qualifier string
+}
+
+// readAccessQualifier returns the modifier and qualifier of a definition's
+// qualified access modifier, e.g. `private` and `foo` for `private[foo]`, or
+// empty strings if it has none. `private[this]` has none, as it is narrower
+// than any package.
+func readAccessQualifier(node *sitter.Node, sourceCode []byte) (string, string) {
+	modifiers := getLoneChild(node, "modifiers")
+	if modifiers == nil {
+		return "", ""
+	}
+	access := getLoneChild(modifiers, "access_modifier")
+	if access == nil {
+		return "", ""
+	}
+	qualifier := getLoneChild(access, "access_qualifier")
+	if qualifier == nil || qualifier.NamedChildCount() == 0 {
+		return "", ""
+	}
+
+	name := qualifier.NamedChild(0).Content(sourceCode)
+	if name == "this" {
+		return "", ""
+	}
+	modifier := "private"
+	if strings.HasPrefix(access.Content(sourceCode), "protected") {
+		modifier = "protected"
+	}
+	return modifier, name
+}
+
+// readQualifiedPrivates finds the qualified private definitions that could be
+// imported from node: those at the top level, and inside objects and package
+// objects, named relative to namespace.
+func readQualifiedPrivates(node *sitter.Node, sourceCode []byte, namespace string) []QualifiedPrivate {
+	privates := make([]QualifiedPrivate, 0)
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if _, ok := symbolKinds[child.Type()]; !ok && child.Type() != "package_object" {
+			continue
+		}
+
+		var name string
+		if child.Type() == "val_definition" || child.Type() == "var_definition" {
+			if pattern := child.ChildByFieldName("pattern"); pattern != nil && pattern.Type() == "identifier" {
+				name = pattern.Content(sourceCode)
+			}
+		} else if nameNode := child.ChildByFieldName("name"); nameNode != nil {
+			name = nameNode.Content(sourceCode)
+		}
+		if name == "" {
+			continue
+		}
+
+		if modifier, qualifier := readAccessQualifier(child, sourceCode); qualifier != "" {
+			privates = append(privates, QualifiedPrivate{
+				Name:      namespace + name,
+				Line:      int(child.StartPoint().Row) + 1,
+				Modifier:  modifier,
+				Qualifier: qualifier,
+			})
+		}
+		if child.Type() == "object_definition" || child.Type() == "package_object" {
+			if body := child.ChildByFieldName("body"); body != nil {
+				privates = append(privates, readQualifiedPrivates(body, sourceCode, namespace+name+".")...)
+			}
+		}
+	}
+
+	return privates
+}
+
+// PrivateLeak is an import of a qualified private definition from outside the
+// package or object it is visible within, which the compiler will reject.
+type PrivateLeak struct {
+	File   string
+	Import string
+	// Symbol is the fully-qualified private definition, defined at DefinedAt,
+	// and Scope what it is visible within.
+	Symbol    string
+	DefinedAt string
+	Modifier  string
+	Scope     string
+}
+
+// privateScope returns what the qualified private definition symbol, of the
+// given qualifier, is visible within: the innermost enclosing package or object
+// named by the qualifier, e.g. `com.foo` for `private[foo]` on `com.foo.bar.Baz`.
+func privateScope(symbol, qualifier string) (string, bool) {
+	for scope := symbol; ; {
+		i := strings.LastIndex(scope, ".")
+		if i < 0 {
+			return "", false
+		}
+		scope = scope[:i]
+		if scope == qualifier || strings.HasSuffix(scope, "."+qualifier) {
+			return scope, true
+		}
+	}
+}
+
+// FindPrivateLeaks returns the imports among results of qualified private
+// definitions of other results, or of their members, from files whose package
+// is outside the definition's scope, sorted by file.
+func FindPrivateLeaks(results []*ParseResult) []PrivateLeak {
+	type private struct {
+		QualifiedPrivate
+		file, scope string
+	}
+	privates := make(map[string]private)
+	for _, result := range results {
+		for _, definition := range result.QualifiedPrivates {
+			symbol := qualify(result.Package, definition.Name)
+			if scope, ok := privateScope(symbol, definition.Qualifier); ok {
+				privates[symbol] = private{definition, result.File, scope}
+			}
+		}
+	}
+	if len(privates) == 0 {
+		return nil
+	}
+
+	var leaks []PrivateLeak
+	for _, result := range results {
+		for _, imp := range result.Imports {
+			// NOTE: a wildcard import only imports what is visible, so never leaks.
+			if strings.HasSuffix(imp, "._") || strings.HasSuffix(imp, ".*") {
+				continue
+			}
+			for name := imp; name != ""; {
+				// NOTE: a public member of a private object is as hidden as the object.
+				p, ok := privates[name]
+				if ok && p.file != result.File && result.Package != p.scope && !strings.HasPrefix(result.Package, p.scope+".") {
+					leaks = append(leaks, PrivateLeak{
+						File:      result.File,
+						Import:    imp,
+						Symbol:    name,
+						DefinedAt: fmt.Sprintf("%s:%d", p.file, p.Line),
+						Modifier:  p.Modifier,
+						Scope:     p.scope,
+					})
+					break
+				}
+				i := strings.LastIndex(name, ".")
+				if i < 0 {
+					break
+				}
+				name = name[:i]
+			}
+		}
+	}
+
+	sort.SliceStable(leaks, func(i, j int) bool { return leaks[i].File < leaks[j].File })
+	return leaks
+}
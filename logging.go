@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// LogLevel controls how much a parser, and the CLI, log to stderr. Results are
+// never logged, so they can always be piped.
+type LogLevel int
+
+const (
+	// LogQuiet logs nothing but errors.
+	LogQuiet LogLevel = iota
+	// LogDefault also logs warnings.
+	LogDefault
+	// LogVerbose also logs each file as it is parsed.
+	LogVerbose
+	// LogDebug also logs the nodes seen during extraction.
+	LogDebug
+)
+
+// WithLogLevel sets the level of the parser's logging, LogDefault by default.
+func WithLogLevel(level LogLevel) Option {
+	return func(p *treeSitterParser) {
+		p.logLevel = level
+	}
+}
+
+func (p *treeSitterParser) logf(level LogLevel, format string, args ...any) {
+	if p.logLevel >= level {
+		p.logger.Printf(format, args...)
+	}
+}
+
+// cliLogLevel is the level of the CLI's own logging, set by -q, -v and -vv.
+var cliLogLevel = LogDefault
+
+func logf(level LogLevel, format string, args ...any) {
+	if cliLogLevel >= level {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
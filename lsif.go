@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// lsifVersion is the version of the LSIF specification the dump follows.
+const lsifVersion = "0.4.3"
+
+// lsifWriter writes an LSIF dump, one JSON element per line, numbering the
+// elements as it goes.
+type lsifWriter struct {
+	out     *bufio.Writer
+	encoder *json.Encoder
+	id      int
+}
+
+func newLSIFWriter(w io.Writer) *lsifWriter {
+	out := bufio.NewWriter(w)
+	return &lsifWriter{out: out, encoder: json.NewEncoder(out)}
+}
+
+func (w *lsifWriter) element(kind, label string, fields map[string]any) int {
+	w.id++
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+	fields["id"] = w.id
+	fields["type"] = kind
+	fields["label"] = label
+	if err := w.encoder.Encode(fields); err != nil {
+		panic(err)
+	}
+	return w.id
+}
+
+func (w *lsifWriter) vertex(label string, fields map[string]any) int {
+	return w.element("vertex", label, fields)
+}
+
+func (w *lsifWriter) edge(label string, outV int, inVs []int, fields map[string]any) {
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+	fields["outV"] = outV
+	if label == "item" || label == "contains" {
+		fields["inVs"] = inVs
+	} else {
+		fields["inV"] = inVs[0]
+	}
+	w.element("edge", label, fields)
+}
+
+// lsifRange is a span of one line of a document, with 0-based columns in bytes.
+type lsifRange struct {
+	line, start, end int
+}
+
+func (r lsifRange) fields() map[string]any {
+	return map[string]any{
+		"start": lspPosition{Line: r.line, Character: r.start},
+		"end":   lspPosition{Line: r.line, Character: r.end},
+	}
+}
+
+// lsifSymbol is the result set shared by a symbol's definition and references.
+type lsifSymbol struct {
+	resultSet   int
+	definitions map[int][]int
+	references  map[int][]int
+}
+
+// WriteLSIF writes an LSIF dump of results to w, with a definition for every
+// extracted symbol, and a reference for every import of a symbol defined in
+// results. Imports of anything else are linked to an import moniker, so tools
+// can join the dump with those of other projects. Columns are in UTF-16 code
+// units, as the specification requires.
+func WriteLSIF(w io.Writer, results []*ParseResult) error {
+	lsif := newLSIFWriter(w)
+
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	lsif.vertex("metaData", map[string]any{
+		"version":     lsifVersion,
+		"projectRoot": pathToURI(root),
+		"toolInfo":    map[string]string{"name": "scala-tree-parser", "version": version},
+	})
+	project := lsif.vertex("project", map[string]any{"kind": "scala"})
+
+	symbols := make(map[string]*lsifSymbol)
+	newSymbol := func(name, monikerKind string) *lsifSymbol {
+		symbol := &lsifSymbol{
+			resultSet:   lsif.vertex("resultSet", nil),
+			definitions: make(map[int][]int),
+			references:  make(map[int][]int),
+		}
+		moniker := lsif.vertex("moniker", map[string]any{"scheme": "scala", "identifier": name, "kind": monikerKind})
+		lsif.edge("moniker", symbol.resultSet, []int{moniker}, nil)
+		symbols[name] = symbol
+		return symbol
+	}
+
+	sources := make([][]byte, len(results))
+	documents := make([]int, len(results))
+	for i, result := range results {
+		// NOTE: a file that can't be read back, e.g. stdin, gets no ranges.
+		sources[i], _ = os.ReadFile(result.File)
+		documents[i] = lsif.vertex("document", map[string]any{"uri": pathToURI(absPath(result.File)), "languageId": "scala"})
+	}
+	lsif.edge("contains", project, documents, nil)
+
+	// Definitions come first, so references in any document can be linked to them.
+	for i, result := range results {
+		var ranges []int
+		for _, definition := range result.Definitions {
+			name := qualify(result.Package, definition.Name)
+			if symbols[name] != nil {
+				continue
+			}
+			r, ok := definitionRange(sources[i], definition)
+			if !ok {
+				continue
+			}
+
+			symbol := newSymbol(name, "export")
+			rangeID := lsif.vertex("range", r.fields())
+			lsif.edge("next", rangeID, []int{symbol.resultSet}, nil)
+			symbol.definitions[documents[i]] = append(symbol.definitions[documents[i]], rangeID)
+			ranges = append(ranges, rangeID)
+		}
+		if len(ranges) > 0 {
+			lsif.edge("contains", documents[i], ranges, nil)
+		}
+	}
+
+	for i, result := range results {
+		var ranges []int
+		for _, ref := range importReferences(result.File, sources[i]) {
+			symbol := symbols[ref.name]
+			if symbol == nil {
+				symbol = newSymbol(ref.name, "import")
+			}
+			rangeID := lsif.vertex("range", ref.r.fields())
+			lsif.edge("next", rangeID, []int{symbol.resultSet}, nil)
+			symbol.references[documents[i]] = append(symbol.references[documents[i]], rangeID)
+			ranges = append(ranges, rangeID)
+		}
+		if len(ranges) > 0 {
+			lsif.edge("contains", documents[i], ranges, nil)
+		}
+	}
+
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		symbol := symbols[name]
+		if len(symbol.definitions) > 0 {
+			definitionResult := lsif.vertex("definitionResult", nil)
+			lsif.edge("textDocument/definition", symbol.resultSet, []int{definitionResult}, nil)
+			writeLSIFItems(lsif, definitionResult, symbol.definitions, "")
+		}
+
+		referenceResult := lsif.vertex("referenceResult", nil)
+		lsif.edge("textDocument/references", symbol.resultSet, []int{referenceResult}, nil)
+		writeLSIFItems(lsif, referenceResult, symbol.definitions, "definitions")
+		writeLSIFItems(lsif, referenceResult, symbol.references, "references")
+	}
+
+	return lsif.out.Flush()
+}
+
+// writeLSIFItems links result to the ranges of each document, in document order.
+func writeLSIFItems(lsif *lsifWriter, result int, ranges map[int][]int, property string) {
+	documents := make([]int, 0, len(ranges))
+	for document := range ranges {
+		documents = append(documents, document)
+	}
+	sort.Ints(documents)
+
+	for _, document := range documents {
+		fields := map[string]any{"document": document}
+		if property != "" {
+			fields["property"] = property
+		}
+		lsif.edge("item", result, ranges[document], fields)
+	}
+}
+
+// definitionRange returns the range of the name of definition in its line of
+// sourceCode.
+func definitionRange(sourceCode []byte, definition Symbol) (lsifRange, bool) {
+	lines := strings.Split(string(sourceCode), "\n")
+	if definition.Line < 1 || definition.Line > len(lines) {
+		return lsifRange{}, false
+	}
+	line := lines[definition.Line-1]
+	name := definition.Name[strings.LastIndex(definition.Name, ".")+1:]
+
+	// NOTE: search past the keyword, so `object Foo { def Foo` finds each in turn.
+	from := 0
+	if definition.Kind != "" {
+		if i := strings.Index(line, definition.Kind+" "); i >= 0 {
+			from = i + len(definition.Kind)
+		}
+	}
+	start := strings.Index(line[from:], name)
+	if start < 0 {
+		return lsifRange{line: definition.Line - 1}, true
+	}
+	start += from
+	return lsifRange{
+		line:  definition.Line - 1,
+		start: utf16Column([]byte(line), start),
+		end:   utf16Column([]byte(line), start+len(name)),
+	}, true
+}
+
+// importReference is a name imported by an import declaration.
+type importReference struct {
+	name string
+	r    lsifRange
+}
+
+// importReferences returns the names imported by the declarations of the file
+// at filePath, each with the range of its last identifier. Wildcards, which name
+// no single symbol, are skipped.
+func importReferences(filePath string, sourceCode []byte) []importReference {
+	if len(sourceCode) == 0 {
+		return nil
+	}
+	root, parsed, offset, err := parseForCodemod(filePath, sourceCode)
+	if err != nil {
+		return nil
+	}
+
+	lineStarts := []int{0}
+	for i, b := range sourceCode {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	rangeOf := func(node *sitter.Node) lsifRange {
+		start, end := int(node.StartByte())-offset, int(node.EndByte())-offset
+		line := sort.SearchInts(lineStarts, start+1) - 1
+		text := sourceCode[lineStarts[line]:]
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+		return lsifRange{
+			line:  line,
+			start: utf16Column(text, start-lineStarts[line]),
+			end:   utf16Column(text, end-lineStarts[line]),
+		}
+	}
+
+	var refs []importReference
+	WalkNode(root, func(node *sitter.Node) bool {
+		if node.Type() != "import_declaration" {
+			return true
+		}
+		path := node.ChildByFieldName("path")
+		if path == nil {
+			return false
+		}
+		name, err := readImportPath(path, parsed)
+		if err != nil {
+			return false
+		}
+		name = strings.TrimPrefix(name, "_root_.")
+
+		selectors := getLoneChild(node, "import_selectors")
+		switch {
+		case selectors != nil:
+			for i := 0; i < int(selectors.NamedChildCount()); i++ {
+				selector := selectors.NamedChild(i)
+				if selector.Type() == "renamed_identifier" {
+					selector = selector.ChildByFieldName("name")
+				}
+				if selector != nil && selector.Type() == "identifier" {
+					refs = append(refs, importReference{name + "." + selector.Content(parsed), rangeOf(selector)})
+				}
+			}
+		case getLoneChild(node, "import_wildcard") == nil:
+			last := path
+			if n := int(path.NamedChildCount()); n > 0 {
+				last = path.NamedChild(n - 1)
+			}
+			refs = append(refs, importReference{name, rangeOf(last)})
+		}
+		return false
+	})
+	return refs
+}
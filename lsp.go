@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LSP symbol kinds, from the SymbolKind enumeration of the specification.
+const (
+	lspModule    = 2
+	lspClass     = 5
+	lspMethod    = 6
+	lspField     = 8
+	lspInterface = 11
+	lspVariable  = 13
+	lspConstant  = 14
+)
+
+var lspSymbolKinds = map[string]int{
+	"object":      lspModule,
+	"case object": lspModule,
+	"class":       lspClass,
+	"trait":       lspInterface,
+	"type":        lspClass,
+	"def":         lspMethod,
+	"val":         lspConstant,
+	"var":         lspVariable,
+}
+
+// maxWorkspaceSymbols caps the results of a workspace/symbol request, which an
+// empty query would otherwise fill with every symbol of the workspace.
+const maxWorkspaceSymbols = 500
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspSymbolInformation struct {
+	Name          string      `json:"name"`
+	Kind          int         `json:"kind"`
+	Location      lspLocation `json:"location"`
+	ContainerName string      `json:"containerName,omitempty"`
+}
+
+type lspRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// lspDiagnostic is a problem in a document, reported to the client by
+// textDocument/publishDiagnostics.
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// lspError is the severity of diagnostics for errors.
+const lspError = 1
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// lspServer answers documentSymbol and workspace/symbol requests from the
+// results of parsing the workspace, kept up to date with the documents open in
+// the editor.
+type lspServer struct {
+	f      *parseFlags
+	parser Parser
+	out    io.Writer
+
+	// results holds the latest result for each file, by absolute path.
+	results  map[string]*ParseResult
+	shutdown bool
+}
+
+// runLSPCommand implements `lsp`, a language server speaking LSP over stdin and
+// stdout. The workspace is the files and directories given, or else the root
+// the client initializes the server with.
+func runLSPCommand(args []string) {
+	f := newParseFlags("lsp", "[flags] [file or directory]...")
+	// NOTE: an editor has no use for the exit code, so don't fail on syntax errors.
+	f.failOn = failOnNone
+	f.argsOptional = true
+	f.parse(args)
+
+	server := &lspServer{
+		f:       f,
+		parser:  NewParser(f.options()...),
+		out:     os.Stdout,
+		results: make(map[string]*ParseResult),
+	}
+	defer server.parser.Close()
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		body, err := readLSPMessage(in)
+		if err == io.EOF {
+			os.Exit(exitInternal)
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitInternal)
+		}
+
+		var request lspRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid message: %v\n", err)
+			continue
+		}
+		server.handle(request)
+	}
+}
+
+// readLSPMessage reads the body of a message framed by a Content-Length header.
+func readLSPMessage(in *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid header %q", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(in, body)
+	return body, err
+}
+
+func (s *lspServer) write(message map[string]any) {
+	message["jsonrpc"] = "2.0"
+	body, err := json.Marshal(message)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *lspServer) reply(request lspRequest, result any) {
+	s.write(map[string]any{"id": request.ID, "result": result})
+}
+
+func (s *lspServer) notify(method string, params any) {
+	s.write(map[string]any{"method": method, "params": params})
+}
+
+// publishDiagnostics reports the errors found parsing the file at filePath,
+// replacing those reported before.
+func (s *lspServer) publishDiagnostics(filePath string, errs []SyntaxError) {
+	diagnostics := make([]lspDiagnostic, 0, len(errs))
+	for _, err := range errs {
+		start := lspPosition{Line: err.Line - 1, Character: err.Column - 1}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lspRange{Start: start, End: start},
+			Severity: lspError,
+			Source:   "scala-tree-parser",
+			Message:  err.Message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]any{"uri": pathToURI(filePath), "diagnostics": diagnostics})
+}
+
+func (s *lspServer) handle(request lspRequest) {
+	switch request.Method {
+	case "initialize":
+		var params struct {
+			RootURI string `json:"rootUri"`
+		}
+		json.Unmarshal(request.Params, &params)
+		s.indexWorkspace(params.RootURI)
+		s.reply(request, map[string]any{
+			"capabilities": map[string]any{
+				// NOTE: 1 is full document sync, so each change carries the whole text.
+				"textDocumentSync":        1,
+				"documentSymbolProvider":  true,
+				"workspaceSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "scala-tree-parser", "version": version},
+		})
+		// NOTE: nothing may be published before the reply to initialize.
+		s.publishWorkspaceDiagnostics()
+
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didClose":
+		var params struct {
+			TextDocument   lspTextDocument   `json:"textDocument"`
+			ContentChanges []lspTextDocument `json:"contentChanges"`
+		}
+		if json.Unmarshal(request.Params, &params) != nil {
+			return
+		}
+		text := []byte(params.TextDocument.Text)
+		if n := len(params.ContentChanges); n > 0 {
+			text = []byte(params.ContentChanges[n-1].Text)
+		}
+		if request.Method == "textDocument/didClose" {
+			// NOTE: drop any unsaved changes by reading the file back from disk.
+			text = nil
+		}
+		s.update(uriToPath(params.TextDocument.URI), text)
+
+	case "textDocument/documentSymbol":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		json.Unmarshal(request.Params, &params)
+		filePath := uriToPath(params.TextDocument.URI)
+		if _, ok := s.results[filePath]; !ok {
+			s.update(filePath, nil)
+		}
+		symbols := make([]lspSymbolInformation, 0)
+		if result, ok := s.results[filePath]; ok {
+			for _, definition := range result.Definitions {
+				symbols = append(symbols, lspSymbol(filePath, result.Package, definition))
+			}
+		}
+		s.reply(request, symbols)
+
+	case "workspace/symbol":
+		var params struct {
+			Query string `json:"query"`
+		}
+		json.Unmarshal(request.Params, &params)
+		s.reply(request, s.workspaceSymbols(params.Query))
+
+	case "shutdown":
+		s.shutdown = true
+		s.reply(request, nil)
+
+	case "exit":
+		if s.shutdown {
+			os.Exit(exitOK)
+		}
+		// NOTE: the specification has the server exit with 1 when it was not shut
+		// down first.
+		os.Exit(exitUsage)
+
+	default:
+		// Notifications the server doesn't handle are ignored, but requests must
+		// be answered.
+		if request.ID != nil {
+			s.write(map[string]any{"id": request.ID, "error": map[string]any{
+				"code":    -32601,
+				"message": "method not found: " + request.Method,
+			}})
+		}
+	}
+}
+
+// indexWorkspace parses every file of the workspace: the files and directories
+// given on the command line, or else those beneath rootURI.
+func (s *lspServer) indexWorkspace(rootURI string) {
+	var files []string
+	if s.f.NArg() > 0 {
+		files = s.f.files()
+	} else if rootURI != "" {
+		files = s.f.expand([]string{uriToPath(rootURI)})
+	}
+
+	s.f.parseFiles(files, func(result *ParseResult) {
+		s.results[absPath(result.File)] = result
+	})
+	logf(LogDefault, "indexed %d files\n", len(s.results))
+}
+
+// publishWorkspaceDiagnostics reports the syntax errors of each file of the
+// workspace that has any.
+func (s *lspServer) publishWorkspaceDiagnostics() {
+	filePaths := make([]string, 0, len(s.results))
+	for filePath, result := range s.results {
+		if len(result.SyntaxErrors) > 0 {
+			filePaths = append(filePaths, filePath)
+		}
+	}
+	sort.Strings(filePaths)
+	for _, filePath := range filePaths {
+		s.publishDiagnostics(filePath, s.results[filePath].SyntaxErrors)
+	}
+}
+
+// update parses the file at filePath, from text or, if text is nil, from disk,
+// and publishes the errors found in it.
+func (s *lspServer) update(filePath string, text []byte) {
+	if text == nil {
+		var err error
+		if text, err = os.ReadFile(filePath); err != nil {
+			delete(s.results, filePath)
+			return
+		}
+	}
+	result, errs := s.parser.ParseBytes(filePath, text)
+	s.results[filePath] = result
+	s.publishDiagnostics(filePath, positionedErrors(result, errs))
+}
+
+func (s *lspServer) workspaceSymbols(query string) []lspSymbolInformation {
+	query = strings.ToLower(query)
+	symbols := make([]lspSymbolInformation, 0)
+	for filePath, result := range s.results {
+		for _, definition := range result.Definitions {
+			name := definition.Name[strings.LastIndex(definition.Name, ".")+1:]
+			if strings.Contains(strings.ToLower(name), query) {
+				symbols = append(symbols, lspSymbol(filePath, result.Package, definition))
+			}
+		}
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Name != symbols[j].Name {
+			return symbols[i].Name < symbols[j].Name
+		}
+		return symbols[i].Location.URI < symbols[j].Location.URI
+	})
+	if len(symbols) > maxWorkspaceSymbols {
+		symbols = symbols[:maxWorkspaceSymbols]
+	}
+	return symbols
+}
+
+// lspSymbol describes a definition of the file at filePath in package pkg. Only
+// the line of a definition is known, so its range is the start of that line.
+func lspSymbol(filePath, pkg string, definition Symbol) lspSymbolInformation {
+	name := qualify(pkg, definition.Name)
+	container := ""
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name, container = name[i+1:], name[:i]
+	}
+
+	kind, ok := lspSymbolKinds[definition.Kind]
+	if !ok {
+		kind = lspField
+	}
+	start := lspPosition{Line: definition.Line - 1}
+	return lspSymbolInformation{
+		Name:          name,
+		Kind:          kind,
+		Location:      lspLocation{URI: pathToURI(filePath), Range: lspRange{Start: start, End: start}},
+		ContainerName: container,
+	}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	// NOTE: Windows paths are written after a slash, e.g. `file:///C:/foo`.
+	path := u.Path
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' && isDriveLetter(path[1]) {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+func pathToURI(filePath string) string {
+	path := filepath.ToSlash(filePath)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+func isDriveLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// absPath returns the absolute form of a result's File.
+func absPath(file string) string {
+	if filePath, err := filepath.Abs(filepath.FromSlash(file)); err == nil {
+		return filePath
+	}
+	return file
+}
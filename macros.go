@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// scala3Macro matches an inline def whose body is a splice, e.g.
+// `inline def assert(expr: => Boolean): Unit = ${ assertImpl('expr) }`.
+var scala3Macro = regexp.MustCompile(`\binline\s+def\b(?:[^=]|=>)*=\s*\$\{`)
+
+// definesMacros reports whether the file defines a macro: a Scala 2
+// `def f: T = macro impl`, or a Scala 3 inline def that splices in its
+// implementation. Private macros count too, since the file still needs to be
+// compiled separately from its callers.
+func definesMacros(node *sitter.Node, sourceCode []byte) bool {
+	return scala3Macro.Match(sourceCode) || hasScala2Macro(node, sourceCode)
+}
+
+func hasScala2Macro(node *sitter.Node, sourceCode []byte) bool {
+	if node.Type() == "function_definition" {
+		if body := node.ChildByFieldName("body"); body != nil {
+			content := sourceCode[body.StartByte():body.EndByte()]
+			if bytes.Equal(content, []byte("macro")) || bytes.HasPrefix(content, []byte("macro ")) ||
+				bytes.HasPrefix(content, []byte("macro\n")) {
+				return true
+			}
+		}
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if hasScala2Macro(node.NamedChild(i), sourceCode) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// MainMethod is a Scala 3 `@main` method, from which the compiler generates a
+// main class named after the method.
+type MainMethod struct {
+	// Class is the fully-qualified name of the generated main class.
+	Class string
+	// Method is the name of the annotated method.
+	Method string
+	// Arity is the number of parameters, which the generated class parses from the
+	// command line; with Varargs, the last of them takes any remaining arguments.
+	Arity   int
+	Varargs bool
+}
+
+// readAnnotations returns the names of a definition's annotations, as written.
+func readAnnotations(node *sitter.Node, sourceCode []byte) []string {
+	annotations := make([]string, 0)
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() != "annotation" {
+			continue
+		}
+		if name := child.ChildByFieldName("name"); name != nil {
+			annotations = append(annotations, readTypeNames(name, sourceCode)...)
+		}
+	}
+
+	return annotations
+}
+
+// readMainMethods finds every `@main` method beneath node.
+func readMainMethods(node *sitter.Node, sourceCode []byte, pkg string) []MainMethod {
+	mains := make([]MainMethod, 0)
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() != "function_definition" {
+			mains = append(mains, readMainMethods(child, sourceCode, pkg)...)
+			continue
+		}
+
+		annotations := readAnnotations(child, sourceCode)
+		if !containsString(annotations, "main") && !containsString(annotations, "scala.main") {
+			continue
+		}
+
+		method := child.ChildByFieldName("name").Content(sourceCode)
+		main := MainMethod{
+			Class:  qualify(pkg, method),
+			Method: method,
+		}
+		if params := child.ChildByFieldName("parameters"); params != nil {
+			main.Arity = int(params.NamedChildCount())
+			if main.Arity > 0 {
+				last := params.NamedChild(main.Arity - 1).ChildByFieldName("type")
+				main.Varargs = last != nil && last.Type() == "repeated_parameter_type"
+			}
+		}
+
+		mains = append(mains, main)
+	}
+
+	return mains
+}
+
+// readMainObjects returns the fully-qualified names of the top-level objects
+// usable as Scala 2 main classes: those extending App, or defining
+// `def main(args: Array[String])`. Nested objects have no static forwarders, so
+// they can't be main classes.
+func readMainObjects(topLevel *sitter.Node, sourceCode []byte, pkg string) []string {
+	mains := make([]string, 0)
+
+	for i := 0; i < int(topLevel.NamedChildCount()); i++ {
+		child := topLevel.NamedChild(i)
+		if child.Type() != "object_definition" || hasAccessModifier(child) {
+			continue
+		}
+
+		name := child.ChildByFieldName("name").Content(sourceCode)
+		if containsString(readParents(child, sourceCode), "App") || hasMainMethod(child, sourceCode) {
+			mains = append(mains, qualify(pkg, name))
+		}
+	}
+
+	return mains
+}
+
+func hasMainMethod(object *sitter.Node, sourceCode []byte) bool {
+	body := object.ChildByFieldName("body")
+	if body == nil {
+		return false
+	}
+
+	for i := 0; i < int(body.NamedChildCount()); i++ {
+		child := body.NamedChild(i)
+		if child.Type() != "function_definition" || child.ChildByFieldName("name").Content(sourceCode) != "main" {
+			continue
+		}
+		if params := child.ChildByFieldName("parameters"); params != nil && params.NamedChildCount() == 1 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Metrics summarises the size and shape of a file, for codebase-health dashboards.
+type Metrics struct {
+	Lines int
+	// CodeLines counts lines holding at least one token other than a comment.
+	CodeLines int
+	// CommentLines counts lines holding only comments.
+	CommentLines int
+	BlankLines   int
+
+	Classes int
+	Objects int
+	Traits  int
+	Defs    int
+
+	// MaxNestingDepth is the deepest nesting of template bodies and blocks.
+	MaxNestingDepth int
+}
+
+// WithMetrics adds a Metrics block to every result.
+func WithMetrics() Option {
+	return func(p *treeSitterParser) {
+		p.metrics = true
+	}
+}
+
+// nestingNodes are the node types that open a new level of nesting.
+var nestingNodes = map[string]bool{
+	"template_body": true,
+	"block":         true,
+	"case_block":    true,
+}
+
+// readMetrics computes the metrics for the nodes beneath topLevel. originalSource
+// is the file as given to the parser, before any script wrapping.
+func readMetrics(topLevel *sitter.Node, originalSource []byte) *Metrics {
+	metrics := &Metrics{Lines: countLines(originalSource)}
+
+	code := make(map[uint32]bool)
+	comments := make(map[uint32]bool)
+
+	var visit func(node *sitter.Node, depth int)
+	visit = func(node *sitter.Node, depth int) {
+		switch node.Type() {
+		case "class_definition":
+			metrics.Classes++
+		case "object_definition":
+			metrics.Objects++
+		case "trait_definition":
+			metrics.Traits++
+		case "function_definition", "function_declaration":
+			metrics.Defs++
+		case "comment":
+			for row := node.StartPoint().Row; row <= node.EndPoint().Row; row++ {
+				comments[row] = true
+			}
+			return
+		}
+
+		if nestingNodes[node.Type()] {
+			depth++
+			if depth > metrics.MaxNestingDepth {
+				metrics.MaxNestingDepth = depth
+			}
+		}
+
+		if node.ChildCount() == 0 {
+			for row := node.StartPoint().Row; row <= node.EndPoint().Row; row++ {
+				code[row] = true
+			}
+			return
+		}
+
+		for i := 0; i < int(node.ChildCount()); i++ {
+			visit(node.Child(i), depth)
+		}
+	}
+
+	// Only named children, so the braces of a script's wrapper are not counted.
+	for i := 0; i < int(topLevel.NamedChildCount()); i++ {
+		visit(topLevel.NamedChild(i), 0)
+	}
+
+	metrics.CodeLines = len(code)
+	for row := range comments {
+		if !code[row] {
+			metrics.CommentLines++
+		}
+	}
+
+	for _, line := range bytes.Split(originalSource, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			metrics.BlankLines++
+		}
+	}
+	if len(originalSource) > 0 && originalSource[len(originalSource)-1] == '\n' {
+		// The empty string after the final newline is not a line.
+		metrics.BlankLines--
+	}
+
+	return metrics
+}
+
+func countLines(source []byte) int {
+	lines := bytes.Count(source, []byte("\n"))
+	if len(source) > 0 && source[len(source)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
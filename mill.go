@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// millTestModules maps test framework packages to the Mill TestModule running
+// their tests.
+var millTestModules = map[string]string{
+	"org.scalatest.": "TestModule.ScalaTest",
+	"org.specs2.":    "TestModule.Specs2",
+	"munit.":         "TestModule.Munit",
+	"utest.":         "TestModule.Utest",
+	"zio.test.":      "TestModule.ZioTest",
+	"weaver.":        "TestModule.Weaver",
+	"org.junit.":     "TestModule.Junit4",
+}
+
+// millModuleName returns the name of the Mill module of target, built from its
+// package, e.g. `core_util` for `//core/util:util` and `core_util_test` for its
+// scala_test.
+func millModuleName(target *BuildTarget) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, target.Package)
+	if name == "" {
+		name = target.Name
+	} else if target.Kind == "scala_test" {
+		name += "_test"
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// writeMillModules writes targets to w as the modules of a Mill build, one per
+// library or test. Binaries become the mainClass of their library; the
+// artifacts that are dependency coordinates, as index-deps writes, become ivyDeps.
+func writeMillModules(w io.Writer, targets []*BuildTarget, scalaVersion string) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "// Generated by `scala-tree-parser generate --format=mill`.")
+	fmt.Fprintln(out, "import mill._, scalalib._")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "trait GeneratedModule extends ScalaModule {")
+	fmt.Fprintf(out, "  def scalaVersion = %s\n", strconv.Quote(scalaVersion))
+	fmt.Fprintln(out, "}")
+
+	modules := make(map[string]*BuildTarget)
+	mainClasses := make(map[*BuildTarget][]string)
+	for _, target := range targets {
+		if target.Kind != "scala_binary" {
+			modules[normalizeLabel(target.Label(""), "")] = target
+		}
+	}
+	for _, target := range targets {
+		if target.Kind == "scala_binary" {
+			library := modules[normalizeLabel(target.Deps[0], target.Package)]
+			mainClasses[library] = append(mainClasses[library], target.MainClass)
+		}
+	}
+
+	for _, target := range targets {
+		if target.Kind == "scala_binary" {
+			continue
+		}
+
+		extends := "GeneratedModule"
+		if target.Kind == "scala_test" {
+			if testModule, ok := millTestModules[target.TestFramework]; ok {
+				extends += " with " + testModule
+			}
+		}
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "object %s extends %s {\n", millModuleName(target), extends)
+		if target.Kind == "scala_test" && !strings.Contains(extends, "TestModule") {
+			fmt.Fprintln(out, "  // TODO: mix in the TestModule of the test framework.")
+		}
+
+		var sources []string
+		for _, src := range target.Srcs {
+			sources = append(sources, "T.workspace / os.RelPath("+strconv.Quote(path.Join(target.Package, src))+")")
+		}
+		writeMillSeq(out, "sources", "T.sources", sources)
+
+		var moduleDeps, ivyDeps []string
+		for _, dep := range target.Deps {
+			if module, ok := modules[normalizeLabel(dep, target.Package)]; ok {
+				moduleDeps = append(moduleDeps, millModuleName(module))
+			}
+		}
+		for _, artifact := range target.Artifacts {
+			if dep, ok := parseDependency(artifact); ok && dep.Version != "" && !strings.Contains(artifact, "//") {
+				ivyDeps = append(ivyDeps, "ivy"+strconv.Quote(dep.String()))
+			}
+		}
+		writeMillSeq(out, "moduleDeps", "Seq", moduleDeps)
+		writeMillSeq(out, "ivyDeps", "Agg", ivyDeps)
+
+		if mains := mainClasses[target]; len(mains) > 0 {
+			if len(mains) > 1 {
+				fmt.Fprintf(out, "  // NOTE: also defines %s.\n", strings.Join(mains[1:], ", "))
+			}
+			fmt.Fprintf(out, "  override def mainClass = Some(%s)\n", strconv.Quote(mains[0]))
+		}
+		fmt.Fprintln(out, "}")
+	}
+	return out.Flush()
+}
+
+// writeMillSeq writes `override def name = constructor(values...)`, one value per line,
+// unless values is empty.
+func writeMillSeq(out *bufio.Writer, name, constructor string, values []string) {
+	switch len(values) {
+	case 0:
+	case 1:
+		fmt.Fprintf(out, "  override def %s = %s(%s)\n", name, constructor, values[0])
+	default:
+		fmt.Fprintf(out, "  override def %s = %s(\n", name, constructor)
+		for _, value := range values {
+			fmt.Fprintf(out, "    %s,\n", value)
+		}
+		fmt.Fprintln(out, "  )")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Option configures a parser created by NewParser.
+type Option func(*treeSitterParser)
+
+// Dialect selects the Scala language version whose extraction rules are used.
+type Dialect int
+
+const (
+	Scala2 Dialect = iota
+	Scala3
+	// DialectAuto detects the dialect of each file; see detectDialect.
+	DialectAuto
+)
+
+// SymbolDepth controls how far into nested definitions symbols are extracted.
+type SymbolDepth int
+
+const (
+	// SymbolDepthTop extracts only top-level definitions.
+	SymbolDepthTop SymbolDepth = iota
+	// SymbolDepthMembers also extracts the members of objects, which are statically
+	// accessible from other files, objects nested in classes and traits, and the
+	// secondary constructors of classes.
+	SymbolDepthMembers
+	// SymbolDepthAll extracts every nested definition, including class and trait members.
+	SymbolDepthAll
+)
+
+func (d SymbolDepth) String() string {
+	switch d {
+	case SymbolDepthTop:
+		return "top"
+	case SymbolDepthMembers:
+		return "members"
+	default:
+		return "all"
+	}
+}
+
+// Set parses a depth as printed by String, so a SymbolDepth can be used as a
+// flag.Value.
+func (d *SymbolDepth) Set(name string) error {
+	switch name {
+	case "top":
+		*d = SymbolDepthTop
+	case "members":
+		*d = SymbolDepthMembers
+	case "all":
+		*d = SymbolDepthAll
+	default:
+		return fmt.Errorf("unknown symbol depth %q, expected top, members or all", name)
+	}
+	return nil
+}
+
+// ImportScope controls which import declarations are extracted.
+type ImportScope int
+
+const (
+	// ImportScopeTopLevel extracts only imports at the top of the file.
+	ImportScopeTopLevel ImportScope = iota
+	// ImportScopeAll also extracts imports nested inside templates and blocks.
+	ImportScopeAll
+)
+
+func WithDialect(dialect Dialect) Option {
+	return func(p *treeSitterParser) {
+		p.dialect = dialect
+	}
+}
+
+func WithSymbolDepth(depth SymbolDepth) Option {
+	return func(p *treeSitterParser) {
+		p.symbolDepth = depth
+	}
+}
+
+func WithImportScopes(scope ImportScope) Option {
+	return func(p *treeSitterParser) {
+		p.importScope = scope
+	}
+}
+
+// WithRootPrefix keeps the `_root_.` marker on imports such as
+// `import _root_.com.foo.Bar`, which are otherwise recorded as `com.foo.Bar`.
+func WithRootPrefix() Option {
+	return func(p *treeSitterParser) {
+		p.keepRootPrefix = true
+	}
+}
+
+// WithRelativeImports resolves relative imports against the file's package
+// clauses and preceding imports, reporting any that are ambiguous.
+func WithRelativeImports() Option {
+	return func(p *treeSitterParser) {
+		p.resolveImports = true
+	}
+}
+
+// WithFrameworkRoles tags files with the roles they play in Akka or Pekko actor
+// systems.
+func WithFrameworkRoles() Option {
+	return WithExtractors(ActorRoles)
+}
+
+// WithIndex expands wildcard imports of indexed packages into explicit imports of
+// the members each file references, and reports references to symbols defined
+// elsewhere in the same package.
+func WithIndex(index *Index) Option {
+	return func(p *treeSitterParser) {
+		p.index = index
+	}
+}
+
+func WithLogger(logger *log.Logger) Option {
+	return func(p *treeSitterParser) {
+		p.logger = logger
+	}
+}
+
+// WithTreeRetention retains parsed trees for Parser.Tree, evicting the least
+// recently used ones once their sources exceed maxTreeBytes.
+func WithTreeRetention(maxTreeBytes int) Option {
+	return func(p *treeSitterParser) {
+		p.trees = newTreeCache(maxTreeBytes)
+	}
+}
+
+func defaultLogger() *log.Logger {
+	return log.New(os.Stderr, "", 0)
+}
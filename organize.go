@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ImportStyle is how OrganizeImports groups and orders imports.
+type ImportStyle struct {
+	// Groups are the prefixes of each group of imports, in order. An import joins
+	// the group with its longest matching prefix, and "*" matches any import, for
+	// the group of everything else. Groups are separated by blank lines, and
+	// sorted within.
+	Groups [][]string
+}
+
+// importStyles are the styles selected by --style.
+var importStyles = map[string]ImportStyle{
+	// scalafix matches the default of scalafix's OrganizeImports rule.
+	"scalafix": {Groups: [][]string{{"java.", "javax."}, {"scala."}, {"*"}}},
+	// intellij matches IntelliJ IDEA's default Scala import layout.
+	"intellij": {Groups: [][]string{{"java.", "javax."}, {"*"}, {"scala."}}},
+}
+
+// group returns the index in s.Groups of the group of imp, which is the text of
+// an import declaration without the `import` keyword.
+func (s ImportStyle) group(imp string) int {
+	best, bestLength := len(s.Groups), -1
+	for i, prefixes := range s.Groups {
+		for _, prefix := range prefixes {
+			if prefix == "*" && bestLength < 0 {
+				best, bestLength = i, 0
+			} else if prefix != "*" && strings.HasPrefix(imp, prefix) && len(prefix) > bestLength {
+				best, bestLength = i, len(prefix)
+			}
+		}
+	}
+	return best
+}
+
+// OrganizeImports returns sourceCode with each block of consecutive import
+// declarations sorted, deduplicated and grouped according to style. A comment or
+// any other statement ends a block, so comments are never moved away from the
+// imports they describe.
+func OrganizeImports(filePath string, sourceCode []byte, style ImportStyle) ([]byte, error) {
+	root, parsed, offset, err := parseForCodemod(filePath, sourceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	newline := lineEnding(sourceCode)
+	var edits []textEdit
+	WalkNode(root, func(node *sitter.Node) bool {
+		for _, block := range importBlocks(node, parsed) {
+			first, last := block[0], block[len(block)-1]
+			edits = append(edits, textEdit{
+				start: int(first.StartByte()) - offset,
+				end:   int(last.EndByte()) - offset,
+				text:  formatImportBlock(block, parsed, style, newline),
+			})
+		}
+		return true
+	})
+
+	return applyEdits(sourceCode, edits), nil
+}
+
+// importBlocks returns the runs of import declarations among the children of
+// node that are separated by nothing but whitespace.
+func importBlocks(node *sitter.Node, sourceCode []byte) [][]*sitter.Node {
+	var blocks [][]*sitter.Node
+	var block []*sitter.Node
+	flush := func() {
+		if len(block) > 0 {
+			blocks = append(blocks, block)
+		}
+		block = nil
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() != "import_declaration" {
+			flush()
+			continue
+		}
+		if len(block) > 0 && len(bytes.TrimSpace(sourceCode[block[len(block)-1].EndByte():child.StartByte()])) > 0 {
+			flush()
+		}
+		block = append(block, child)
+	}
+	flush()
+	return blocks
+}
+
+// formatImportBlock formats the declarations of block, indented like the first
+// and separated by newline, the line ending of the file.
+func formatImportBlock(block []*sitter.Node, sourceCode []byte, style ImportStyle, newline string) string {
+	groups := make([][]string, len(style.Groups)+1)
+	seen := make(map[string]bool)
+	for _, node := range block {
+		imp := strings.TrimSpace(strings.TrimPrefix(normalizeWhitespace(node.Content(sourceCode)), "import"))
+		if seen[imp] {
+			continue
+		}
+		seen[imp] = true
+		i := style.group(imp)
+		groups[i] = append(groups[i], imp)
+	}
+
+	start := int(block[0].StartByte())
+	lineStart := bytes.LastIndexByte(sourceCode[:start], '\n') + 1
+	indent := string(sourceCode[lineStart:start])
+	if strings.TrimSpace(indent) != "" {
+		indent = ""
+	}
+
+	var out strings.Builder
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		if out.Len() > 0 {
+			out.WriteString(newline + newline + indent)
+		}
+		sort.Strings(group)
+		for i, imp := range group {
+			if i > 0 {
+				out.WriteString(newline + indent)
+			}
+			out.WriteString("import " + imp)
+		}
+	}
+	return out.String()
+}
+
+// runOrganizeImportsCommand implements `organize-imports`.
+func runOrganizeImportsCommand(args []string) {
+	f := newParseFlags("organize-imports", "[flags] <file or directory>...")
+	styleName := f.String("style", "scalafix", "grouping of imports: scalafix (java, scala, then the rest) or intellij (java, the rest, then scala)")
+	var groups stringList
+	f.Var(&groups, "group", "comma-separated prefixes of a group of imports, or * for the rest, overriding --style (repeatable, in order)")
+	codemod := addCodemodFlags(f)
+	f.parse(args)
+
+	style, ok := importStyles[*styleName]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "invalid --style %q, expected scalafix or intellij\n", *styleName)
+		os.Exit(exitUsage)
+	}
+	if len(groups) > 0 {
+		style = ImportStyle{}
+		for _, group := range groups {
+			style.Groups = append(style.Groups, splitPatterns([]string{group}))
+		}
+	}
+
+	codemod.rewriteFiles(f.files(), func(filePath string, sourceCode []byte) ([]byte, error) {
+		return OrganizeImports(filePath, sourceCode, style)
+	})
+}
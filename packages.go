@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// expectedPackage returns the package implied by the directory of filePath
+// relative to sourceRoot, e.g. `com.foo` for `src/main/scala/com/foo/A.scala`.
+func expectedPackage(filePath, sourceRoot string) string {
+	dir := path.Dir(filepath.ToSlash(filePath))
+	rel := strings.TrimPrefix(strings.TrimPrefix(dir, sourceRoot), "/")
+	return strings.ReplaceAll(rel, "/", ".")
+}
+
+// checkPackageDirectory warns if a file under a source root declares a package
+// other than the one its directory implies, which breaks tools that locate
+// sources by package.
+func checkPackageDirectory(result *ParseResult, topLevel *sitter.Node) []Warning {
+	if result.SourceRoot == "" {
+		return nil
+	}
+
+	expected := expectedPackage(result.File, result.SourceRoot)
+	if expected == result.Package {
+		return nil
+	}
+
+	line := 1
+	for i := 0; i < int(topLevel.NamedChildCount()); i++ {
+		if child := topLevel.NamedChild(i); child.Type() == "package_clause" {
+			line = int(child.StartPoint().Row) + 1
+			break
+		}
+	}
+
+	declared := result.Package
+	if declared == "" {
+		declared = "the empty package"
+	}
+	if expected == "" {
+		expected = "the empty package"
+	}
+	return []Warning{{
+		Kind:    "package-mismatch",
+		Message: fmt.Sprintf("declares %s but its directory implies %s", declared, expected),
+		Line:    line,
+	}}
+}
+
+// PackageSummary merges the results of every file in a package, the granularity
+// most BUILD generation works at.
+type PackageSummary struct {
+	Package string
+	Files   []string
+	// Symbols are the fully-qualified symbols defined by the package's files.
+	Symbols []string
+	// ExternalImports are the imports of the package's files from other packages.
+	ExternalImports []string
+	HasTests        bool
+	HasMains        bool
+}
+
+// testFrameworks are the packages whose import marks a file as a test.
+var testFrameworks = []string{"org.scalatest.", "org.scalacheck.", "org.specs2.", "munit.", "utest.", "zio.test.", "weaver.", "org.junit."}
+
+func isTestFile(result *ParseResult) bool {
+	if result.SourceRole == TestSource || result.SourceRole == IntegrationSource {
+		return true
+	}
+	for _, imp := range result.Imports {
+		for _, framework := range testFrameworks {
+			if strings.HasPrefix(imp, framework) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AggregatePackages merges results into one summary per declared package,
+// sorted by package name.
+func AggregatePackages(results []*ParseResult) []PackageSummary {
+	summaries := make(map[string]*PackageSummary)
+	externalImports := make(map[string]*ScalaImports)
+	var packages []string
+
+	for _, result := range results {
+		summary, ok := summaries[result.Package]
+		if !ok {
+			summary = &PackageSummary{Package: result.Package}
+			summaries[result.Package] = summary
+			externalImports[result.Package] = NewScalaImports()
+			packages = append(packages, result.Package)
+		}
+
+		summary.Files = append(summary.Files, result.File)
+		for _, symbol := range result.Symbols {
+			summary.Symbols = append(summary.Symbols, qualify(result.Package, symbol))
+		}
+		for _, imp := range result.Imports {
+			if !isPackageImport(imp, result.Package) {
+				externalImports[result.Package].Add(imp)
+			}
+		}
+		summary.HasTests = summary.HasTests || isTestFile(result)
+		summary.HasMains = summary.HasMains || result.HasMain
+	}
+
+	sort.Strings(packages)
+	aggregated := make([]PackageSummary, 0, len(packages))
+	for _, pkg := range packages {
+		summary := summaries[pkg]
+		summary.Symbols = sortedUnique(summary.Symbols)
+		summary.ExternalImports = externalImports[pkg].SortedSlice()
+		aggregated = append(aggregated, *summary)
+	}
+	return aggregated
+}
+
+// isPackageImport reports whether imp names something inside pkg.
+func isPackageImport(imp, pkg string) bool {
+	return pkg != "" && strings.HasPrefix(imp, pkg+".")
+}
+
+// sortedUnique sorts values and removes duplicates, in place.
+func sortedUnique(values []string) []string {
+	sort.Strings(values)
+	unique := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
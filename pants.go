@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+)
+
+// pantsFlavor writes targets as Pants `scala_sources`, `scala_tests` and
+// `deploy_jar` targets.
+var pantsFlavor = buildFlavor{
+	kinds:     map[string]string{"scala_binary": "deploy_jar", "scala_library": "scala_sources", "scala_test": "scala_tests"},
+	srcs:      "sources",
+	deps:      "dependencies",
+	mainClass: "main",
+	label:     pantsAddress,
+}
+
+// pantsAddress returns the Pants address of a Bazel label within the
+// repository, e.g. `core/util:util` for `//core/util:util`. Labels in other
+// repositories, e.g. `@maven//:cats_core`, have none, since Pants names
+// third-party artifacts by the jvm_artifact targets declaring them.
+func pantsAddress(label string) (string, bool) {
+	if strings.HasPrefix(label, "@") {
+		return "", false
+	}
+	return strings.TrimPrefix(label, "//"), true
+}
@@ -2,66 +2,360 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"log"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	treeutils "aspect.build/cli/gazelle/common/treesitter"
-	"github.com/emirpasic/gods/sets/treeset"
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/scala"
+	"golang.org/x/text/unicode/norm"
 )
 
 type ParseResult struct {
+	// SchemaVersion is the version of this structure's JSON form; see SchemaVersion.
+	SchemaVersion int `json:"schemaVersion"`
+
 	File    string
 	Imports []string
-  Symbols []string
-	Package string
+	// ImportGroups holds Imports grouped by where they come from.
+	ImportGroups ImportGroups
+	// Artifacts are the jars or Bazel labels providing the third-party imports;
+	// only populated WithArtifactIndex.
+	Artifacts []string
+	Symbols   []string
+	Package   string
+
+	// Definitions holds a structured record for each entry of Symbols.
+	Definitions []Symbol
+	// QualifiedPrivates are the importable definitions only visible within an
+	// enclosing package or object, which Symbols leaves out.
+	QualifiedPrivates []QualifiedPrivate
+
 	HasMain bool
+	Dialect Dialect
+
+	// ScriptDeps are the dependencies loaded by a script's magic imports.
+	ScriptDeps []Dependency
+	// Using holds the file's scala-cli `//> using` directives, if any.
+	Using *UsingDirectives
+	// UsesXMLLiterals is set for Scala 2 files with XML literals, which need the
+	// scala-xml module.
+	UsesXMLLiterals bool
+	// AmbiguousImports are relative imports that could not be resolved to a single
+	// name; only populated when parsing WithRelativeImports.
+	AmbiguousImports []AmbiguousImport
+	// SamePackageRefs are symbols from other files of the same package that this
+	// file references without an import; only populated when parsing WithIndex.
+	SamePackageRefs []string
+
+	// Directives are the magic comments found in the file.
+	Directives []CommentDirective
+	// IgnoreFile is set by a `scala-tree-parser:ignore-file` directive.
+	IgnoreFile bool
+
+	// SealedHierarchies lists each sealed trait or class with its subtypes.
+	SealedHierarchies []SealedHierarchy
+
+	// MainMethods are the file's Scala 3 `@main` methods.
+	MainMethods []MainMethod
+	// MainClasses are the fully-qualified names of every entry point in the file:
+	// the classes generated for `@main` methods, and objects extending App or
+	// defining a main method. HasMain is set if there are any.
+	MainClasses []string
+
+	// IsBenchmark is set for files holding JMH benchmarks.
+	IsBenchmark bool
+
+	// FrameworkRoles tags the roles the file plays in a framework, e.g. `actor`;
+	// only populated when parsing WithFrameworkRoles.
+	FrameworkRoles []string
+	// Extra holds the findings of registered Extractors, keyed by their names.
+	Extra map[string]any
+
+	// LanguageFeatures are the features enabled by `scala.language` imports, e.g.
+	// `higherKinds`, matching scalac's `-language:` flags.
+	LanguageFeatures []string
+
+	// DefinesMacros is set for files defining Scala 2 or Scala 3 macros, which need
+	// to be compiled separately from the code using them.
+	DefinesMacros bool
+	// UsesQuasiquotes is set for files using Scala 2 quasiquotes, e.g. `q"..."`,
+	// which are usually macro implementations.
+	UsesQuasiquotes bool
+
+	// TypeReferences are types the file depends on other than through imports and
+	// extends clauses: typeclasses named in Scala 3 `derives` clauses, and self-types.
+	TypeReferences []TypeReference
+
+	// ResolvedReferences are the fully-qualified top-level classes and objects the
+	// compiler resolved the file's references to; only populated when parsing
+	// WithSemanticDB, for files with a SemanticDB.
+	ResolvedReferences []string
+
+	// Metrics is nil unless the parser was created with WithMetrics.
+	Metrics *Metrics
+
+	// SourceRoot is the sbt or Maven source root containing the file, e.g.
+	// `core/src/test/scala`, and SourceRole the role of the files beneath it. Both
+	// are empty for files outside a conventional source root.
+	SourceRoot string
+	SourceRole SourceRole
+	// Module is the module of an existing build containing the file; only
+	// populated WithBloopProjects.
+	Module string
+
+	// Warnings are problems found in the file that did not stop it being parsed.
+	Warnings []Warning
+	// SyntaxErrors are the positions of the file's syntax errors, located while
+	// its tree was parsed.
+	SyntaxErrors []SyntaxError
 }
 
-type Parser interface {
-	Parse(filePath, source string) (*ParseResult, []error)
+// Symbol is an extracted definition.
+type Symbol struct {
+	// Name is the symbol's name relative to the file's package, e.g. `Futures.within`.
+	Name string
+	// Kind is the keyword introducing the definition, e.g. `def` or `object`.
+	Kind string
+	// Line is the 1-based line of the definition.
+	Line int
+	// Annotations are the names of the definition's annotations, e.g. `deprecated`.
+	Annotations []string
+	// Modifiers are the definition's modifier keywords, e.g. `case` or `sealed`.
+	Modifiers []string
+	// Parents are the types the definition extends, as written.
+	Parents []string
+	// SelfTypes are the types in a trait or class's self-type annotation.
+	SelfTypes []string
+	// TypeParams are the type parameters of a class, trait or def.
+	TypeParams []TypeParam
+	// Signature is the parameter lists and return type of a def as written, with
+	// whitespace normalized.
+	Signature string
+	// Fields are the constructor parameters of a case class.
+	Fields []Field
+	// Implicit is set for implicit definitions and Scala 3 givens.
+	Implicit bool
+	// Inline is set for Scala 3 inline definitions, which are expanded at their
+	// call sites and so force dependents to recompile.
+	Inline bool
+	// ValueClass is set for classes extending `AnyVal`, which are represented by
+	// their single field at runtime.
+	ValueClass bool
+	// Constructor is set for secondary constructors, named `Class.this`, and for
+	// the `apply` methods of companion objects.
+	Constructor bool
+	// Doc is the definition's scaladoc, if any.
+	Doc *Scaladoc
+	// Deprecation is set for definitions annotated `@deprecated`.
+	Deprecation *Deprecation
 }
 
-type ScalaImports struct {
-	imports *treeset.Set
+type Parser interface {
+	Parse(filePath, source string) (*ParseResult, []error)
+	ParseBytes(filePath string, source []byte) (*ParseResult, []error)
+	ParseReader(filePath string, r io.Reader) (*ParseResult, []error)
+
+	// Tree returns the retained tree and source for a previously parsed file. Trees
+	// are only retained by parsers created with WithTreeRetention. The returned
+	// tree is a copy the caller owns and should Close; later Parse calls evicting
+	// the retained tree do not affect it.
+	Tree(filePath string) (*sitter.Tree, []byte, bool)
+
+	// Close releases all retained trees.
+	Close()
+
+	// parsePrepared is ParseBytes for a source already decoded by prepareSource,
+	// for callers that need the decoded source themselves.
+	parsePrepared(filePath string, sourceCode []byte) (*ParseResult, []error)
+
+	// restoreResult makes a result decoded from a result cache, with the JSON
+	// form of its Extra findings, match one this parser returned: its strings
+	// are interned and its findings decoded with the parser's extractors.
+	restoreResult(result *ParseResult, extra map[string]json.RawMessage) error
 }
 
+// treeSitterParser is safe for concurrent use. A single sitter.Parser is not, so
+// each call to Parse borrows one from a pool for the duration of the parse.
 type treeSitterParser struct {
 	Parser
 
-	parser *sitter.Parser
-}
+	parsers *sync.Pool
+
+	// trees is nil unless the parser retains trees after extraction.
+	trees *treeCache
+
+	dialect     Dialect
+	symbolDepth SymbolDepth
+	importScope ImportScope
+	backticks   BacktickMode
+	logger      *log.Logger
+	logLevel    LogLevel
+	encoding    Encoding
+
+	// interned holds the packages and imports of every result.
+	interned *interner
+
+	keepRootPrefix  bool
+	encodeOperators bool
+	resolveImports  bool
+
+	extractors []Extractor
+
+	// index, if set, is used to expand wildcard imports.
+	index *Index
 
-func NewParser() Parser {
-	sitter := sitter.NewParser()
-	sitter.SetLanguage(scala.GetLanguage())
+	// firstPartyPrefixes are the import prefixes classified as first-party.
+	firstPartyPrefixes []string
 
+	// artifacts, if set, resolves third-party imports to the artifacts providing them.
+	artifacts *ArtifactIndex
+
+	// bloopProjects, if set, are the modules files are attributed to.
+	bloopProjects []BloopProject
+
+	// semanticDB, if set, locates the SemanticDB of each file.
+	semanticDB *semanticDB
+
+	// filters, if set, are applied to each result before it is returned.
+	filters *Filters
+
+	metrics bool
+
+	// importsOnly skips everything but the package and imports.
+	importsOnly bool
+
+	// stats, if set, records the parse time of every file.
+	stats *Stats
+}
+
+// NewParser returns a parser configured by opts. By default it keeps only the
+// extracted results; each tree is closed as soon as its ParseResult has been built.
+func NewParser(opts ...Option) Parser {
 	p := treeSitterParser{
-		parser: sitter,
+		parsers:     newParserPool(),
+		dialect:     DialectAuto,
+		symbolDepth: SymbolDepthMembers,
+		importScope: ImportScopeTopLevel,
+		backticks:   StripBackticks,
+		logger:      defaultLogger(),
+		logLevel:    LogDefault,
+		interned:    newInterner(),
+	}
+
+	for _, opt := range opts {
+		opt(&p)
 	}
 
 	return &p
 }
 
+func newParserPool() *sync.Pool {
+	return &sync.Pool{
+		New: func() any {
+			sitter := sitter.NewParser()
+			sitter.SetLanguage(ScalaLang)
+			return sitter
+		},
+	}
+}
+
+// NewRetainingParser returns a parser that also retains parsed trees for Tree.
+// It is equivalent to NewParser(WithTreeRetention(maxTreeBytes)).
+func NewRetainingParser(maxTreeBytes int) Parser {
+	return NewParser(WithTreeRetention(maxTreeBytes))
+}
+
+func (p *treeSitterParser) Tree(filePath string) (*sitter.Tree, []byte, bool) {
+	if p.trees == nil {
+		return nil, nil, false
+	}
+
+	tree, source, ok := p.trees.get(filePath)
+	if p.stats != nil {
+		p.stats.recordTreeLookup(ok)
+	}
+	return tree, source, ok
+}
+
+func (p *treeSitterParser) Close() {
+	if p.trees != nil {
+		p.trees.close()
+	}
+}
+
 var ScalaTreeSitterName = "scala"
 var ScalaLang = scala.GetLanguage()
 
 func (p *treeSitterParser) Parse(filePath, source string) (*ParseResult, []error) {
+	return p.ParseBytes(filePath, []byte(source))
+}
+
+func (p *treeSitterParser) ParseReader(filePath string, r io.Reader) (*ParseResult, []error) {
+	sourceCode, err := io.ReadAll(r)
+	if err != nil {
+		return &ParseResult{File: filePath}, []error{err}
+	}
+
+	return p.ParseBytes(filePath, sourceCode)
+}
+
+// ParseBytes parses source without copying it; the caller must not modify source
+// while the parse is in progress.
+func (p *treeSitterParser) ParseBytes(filePath string, sourceCode []byte) (*ParseResult, []error) {
+	return p.parsePrepared(filePath, prepareSource(sourceCode, p.encoding))
+}
+
+func (p *treeSitterParser) parsePrepared(filePath string, sourceCode []byte) (*ParseResult, []error) {
+	if p.stats != nil {
+		defer p.stats.record(filePath, len(sourceCode), time.Now())
+	}
+
 	var result = &ParseResult{
-		File:    filePath,
-		Imports: make([]string, 0),
-    Symbols: make([]string, 0),
+		SchemaVersion: SchemaVersion,
+		File:          filepath.ToSlash(filePath),
+		Imports:       make([]string, 0),
+		Symbols:       make([]string, 0),
+		Definitions:   make([]Symbol, 0),
+		Extra:         make(map[string]any),
+		Dialect:       p.dialect,
+	}
+
+	if result.Dialect == DialectAuto {
+		result.Dialect = detectDialect(filePath, sourceCode)
+	}
+
+	result.Using = readUsingDirectives(sourceCode)
+	result.SourceRoot, result.SourceRole = readSourceRoot(filePath)
+	if p.bloopProjects != nil {
+		result.Module = p.bloopModule(filePath)
 	}
 
 	errs := make([]error, 0)
 
 	ctx := context.Background()
 
-	sourceCode := []byte(source)
+	originalSource := sourceCode
+	isScript := isScriptFile(filePath)
+	if isScript {
+		sourceCode = wrapScript(sourceCode)
+	} else if isTwirlTemplate(filePath) {
+		sourceCode = twirlImports(sourceCode)
+	}
+
+	if result.Dialect == Scala2 {
+		sourceCode, result.UsesXMLLiterals = blankXMLLiterals(sourceCode)
+	}
 
-	tree, err := p.parser.ParseCtx(ctx, nil, sourceCode)
+	parser := p.parsers.Get().(*sitter.Parser)
+	tree, err := parser.ParseCtx(ctx, nil, grammarInput(sourceCode))
+	p.parsers.Put(parser)
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -69,117 +363,448 @@ func (p *treeSitterParser) Parse(filePath, source string) (*ParseResult, []error
 	if tree != nil {
 		rootNode := tree.RootNode()
 
+		topLevel := rootNode
+		if isScript {
+			topLevel = scriptBody(rootNode)
+		}
+
+		if p.importsOnly {
+			errs = append(errs, p.readImportsOnly(topLevel, sourceCode, result)...)
+			p.normalizeNames(result)
+			return result, p.finishResult(filePath, tree, sourceCode, result, errs)
+		}
+
+		resolver := newImportResolver()
+		importLines := make(map[uint32][]string)
+
 		// Extract imports from the root nodes
-		for i := 0; i < int(rootNode.NamedChildCount()); i++ {
-			nodeI := rootNode.NamedChild(i)
+		for i := 0; i < int(topLevel.NamedChildCount()); i++ {
+			nodeI := topLevel.NamedChild(i)
 
-      // fmt.Printf("%s\n", nodeI.Type())
+			p.logf(LogDebug, "%s: top-level %s\n", filePath, nodeI.Type())
 
 			if nodeI.Type() == "package_clause" {
-				if result.Package != "" {
-					fmt.Printf("Multiple package declarations found in %s\n", filePath)
-					os.Exit(1)
+				// chained package clauses, e.g. `package com.foo` then `package bar`,
+				// declare the nested package com.foo.bar
+				if err := addPackageClause(nodeI, sourceCode, result, resolver); err != nil {
+					errs = append(errs, err)
 				}
 
-				result.Package = readPackageIdentifier(getLoneChild(nodeI, "package_identifier"), sourceCode, false)
-
 			} else if nodeI.Type() == "import_declaration" {
-        // import packages are nested stable_identifiers, with the first two packages in
-        // the innermost tuple: (((identifier, identifier), identifier), identifier)
-        // e.g. path = ((("com", "twitter"), "finagle"), "http")
-        path := nodeI.ChildByFieldName("path")
-        importPackage := ""
-        for path != nil {
-            if importPackage != "" {
-              importPackage = "." + importPackage
-            }
-            importPackage = readStableIdentifier(path, sourceCode, false) + importPackage
-            path = getLoneChild(path, "stable_identifier")
-        }
-
-        selectors := getLoneChild(nodeI, "import_selectors")
-        // TODO(jacob): figure out how to do better checks on what type child nodes are
-        if selectors == nil {
-          if getLoneChild(nodeI, "import_wildcard") != nil {
-            result.Imports = append(result.Imports, importPackage + "._")
-          } else {
-            result.Imports = append(result.Imports, importPackage)
-          }
-        } else {
-          symbols := readImportSelectors(selectors, sourceCode)
-          for _, symbol := range(symbols) {
-            result.Imports = append(result.Imports, importPackage + "." + symbol)
-          }
-        }
-
-      } else {
-        childSymbols := recursivelyParseSymbols(nodeI, sourceCode, "")
-        result.Symbols = append(result.Symbols, childSymbols...)
-      }
-		}
-
-		treeErrors := treeutils.QueryErrors(ScalaTreeSitterName, ScalaLang, sourceCode, rootNode)
-		if treeErrors != nil {
-			errs = append(errs, treeErrors...)
+				if deps, magic := readMagicImports(nodeI, sourceCode); magic {
+					result.ScriptDeps = append(result.ScriptDeps, deps...)
+					continue
+				}
+
+				imports, err := p.addImportDeclaration(nodeI, sourceCode, result, resolver)
+				if err != nil {
+					errs = append(errs, err)
+				}
+
+				row := nodeI.EndPoint().Row
+				importLines[row] = append(importLines[row], imports...)
+
+			} else {
+				childSymbols := p.recursivelyParseSymbols(nodeI, sourceCode, "", &result.Warnings)
+				result.Definitions = append(result.Definitions, childSymbols...)
+
+				if p.importScope == ImportScopeAll {
+					imports, err := readNestedImports(nodeI, sourceCode, result.Dialect)
+					if err != nil {
+						errs = append(errs, err)
+					}
+					for _, imp := range imports {
+						result.Imports = append(result.Imports, p.normalizeImport(imp))
+					}
+				}
+			}
+		}
+
+		p.normalizeNames(result)
+		result.Warnings = append(result.Warnings, checkPackageDirectory(result, topLevel)...)
+		result.SealedHierarchies = readSealedHierarchies(result.Definitions)
+		markCompanionApplies(result.Definitions)
+
+		result.LanguageFeatures = languageFeatures(result.Imports, result.Dialect)
+		result.QualifiedPrivates = readQualifiedPrivates(topLevel, sourceCode, "")
+		result.MainMethods = readMainMethods(topLevel, sourceCode, result.Package)
+		for _, main := range result.MainMethods {
+			result.MainClasses = append(result.MainClasses, main.Class)
+		}
+		if !isScript {
+			result.MainClasses = append(result.MainClasses, readMainObjects(topLevel, sourceCode, result.Package)...)
+		}
+		result.HasMain = len(result.MainClasses) > 0
+		result.DefinesMacros = definesMacros(rootNode, sourceCode)
+		result.UsesQuasiquotes = usesQuasiquotes(rootNode, sourceCode)
+		result.IsBenchmark = isBenchmark(rootNode, sourceCode, result.Imports)
+		runExtractors(p.extractors, rootNode, sourceCode, result)
+		result.TypeReferences = selfTypeReferences(result.Definitions)
+		if result.Dialect == Scala3 {
+			result.TypeReferences = append(result.TypeReferences, readDerivesClauses(sourceCode)...)
+		}
+
+		result.Directives = readCommentDirectives(rootNode, sourceCode, importLines)
+		for _, directive := range result.Directives {
+			if directive.Name == "ignore-file" {
+				result.IgnoreFile = true
+			}
+		}
+
+		if p.index != nil {
+			references := collectReferences(topLevel, sourceCode, make(map[string]bool))
+			expandWildcards(result, p.index, references)
+			result.SamePackageRefs = samePackageRefs(result, p.index, references)
+		}
+
+		if p.semanticDB != nil {
+			references, err := p.semanticDB.resolvedReferences(filePath)
+			if err != nil {
+				result.Warnings = append(result.Warnings, Warning{Kind: "semanticdb", Message: err.Error()})
+			}
+			result.ResolvedReferences = references
 		}
+
+		if p.metrics {
+			result.Metrics = readMetrics(topLevel, originalSource)
+		}
+
+		errs = p.finishResult(filePath, tree, sourceCode, result, errs)
 	}
 
 	return result, errs
 }
 
-func recursivelyParseSymbols(node *sitter.Node, sourceCode []byte, namespace string) []string {
-  symbols := make([]string, 0)
+// finishResult filters, interns and groups the imports of result, and appends the syntax errors in
+// tree to errs, and locates them in result, before retaining or closing it.
+func (p *treeSitterParser) finishResult(filePath string, tree *sitter.Tree, sourceCode []byte, result *ParseResult, errs []error) []error {
+	if p.filters != nil {
+		p.filters.apply(result)
+	}
+	p.interned.internResult(result)
+	result.ImportGroups = p.groupImports(result)
+	if p.artifacts != nil {
+		result.Artifacts = p.resolveArtifacts(result)
+	}
+
+	treeErrors := treeutils.QueryErrors(ScalaTreeSitterName, ScalaLang, sourceCode, tree.RootNode())
+	if treeErrors != nil {
+		errs = append(errs, treeErrors...)
+	}
+	if tree.RootNode().HasError() {
+		result.SyntaxErrors = findSyntaxErrors(tree.RootNode(), sourceCode, filePath)
+	}
+
+	if p.trees != nil {
+		p.trees.put(filePath, tree, sourceCode)
+	} else {
+		tree.Close()
+	}
+	return errs
+}
+
+// addPackageClause appends the package declared by node to result's package.
+func addPackageClause(node *sitter.Node, sourceCode []byte, result *ParseResult, resolver *importResolver) error {
+	pkg, err := readPackageIdentifier(getLoneChild(node, "package_identifier"), sourceCode, false)
+	if err != nil {
+		return err
+	}
+	resolver.addPackage(pkg)
+	if result.Package != "" {
+		result.Package += "."
+	}
+	result.Package += pkg
+	return nil
+}
+
+// addImportDeclaration appends the imports declared by node to result, resolved
+// and normalized, and returns them.
+func (p *treeSitterParser) addImportDeclaration(node *sitter.Node, sourceCode []byte, result *ParseResult, resolver *importResolver) ([]string, error) {
+	imports, err := readImportDeclaration(node, sourceCode, result.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	if p.resolveImports {
+		var ambiguous []AmbiguousImport
+		imports, ambiguous = resolver.resolve(imports, readImportAliases(node, sourceCode), result.Dialect)
+		result.AmbiguousImports = append(result.AmbiguousImports, ambiguous...)
+	}
+	for i := range imports {
+		imports[i] = p.normalizeImport(imports[i])
+	}
+	result.Imports = append(result.Imports, imports...)
+	return imports, nil
+}
+
+// normalizeNames applies the parser's BacktickMode and NFC normalization to the
+// package and symbols, and encodes operator names if configured to.
+func (p *treeSitterParser) normalizeNames(result *ParseResult) {
+	result.Package = norm.NFC.String(normalizeBackticks(result.Package, p.backticks))
+	for i := range result.Definitions {
+		result.Definitions[i].Name = norm.NFC.String(normalizeBackticks(result.Definitions[i].Name, p.backticks))
+		if p.encodeOperators {
+			result.Definitions[i].Name = encodeOperators(result.Definitions[i].Name)
+		}
+		result.Symbols = append(result.Symbols, result.Definitions[i].Name)
+	}
+}
+
+// normalizeImport applies the parser's BacktickMode and NFC normalization to an
+// import, drops the `_root_` marker unless configured to keep it, and encodes
+// operator names if configured to.
+func (p *treeSitterParser) normalizeImport(imp string) string {
+	imp = norm.NFC.String(normalizeBackticks(imp, p.backticks))
+	if !p.keepRootPrefix {
+		imp = strings.TrimPrefix(imp, "_root_.")
+	}
+	if p.encodeOperators {
+		imp = encodeOperators(imp)
+	}
+	return imp
+}
+
+func readImportDeclaration(node *sitter.Node, sourceCode []byte, dialect Dialect) ([]string, error) {
+	imports := make([]string, 0)
+	importPackage, err := readImportPath(node.ChildByFieldName("path"), sourceCode)
+	if err != nil {
+		return imports, err
+	}
+
+	selectors := getLoneChild(node, "import_selectors")
+	// TODO(jacob): figure out how to do better checks on what type child nodes are
+	if selectors == nil {
+		if getLoneChild(node, "import_wildcard") != nil {
+			imports = append(imports, importPackage+"."+dialect.Wildcard())
+		} else if operator, ok := readTrailingOperatorImport(node, sourceCode); ok {
+			imports = append(imports, importPackage+"."+operator)
+		} else {
+			imports = append(imports, importPackage)
+		}
+	} else {
+		symbols, err := readImportSelectors(selectors, sourceCode, dialect)
+		if err != nil {
+			return imports, err
+		}
+		for _, symbol := range symbols {
+			imports = append(imports, importPackage+"."+symbol)
+		}
+	}
+
+	return imports, nil
+}
+
+// readNestedImports finds import declarations anywhere beneath node, e.g. inside
+// object bodies or method blocks. Declarations that cannot be read are skipped,
+// and the first of their errors returned.
+func readNestedImports(node *sitter.Node, sourceCode []byte, dialect Dialect) ([]string, error) {
+	imports := make([]string, 0)
+	var firstErr error
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		var childImports []string
+		var err error
+		if child.Type() == "import_declaration" {
+			childImports, err = readImportDeclaration(child, sourceCode, dialect)
+		} else {
+			childImports, err = readNestedImports(child, sourceCode, dialect)
+		}
+		imports = append(imports, childImports...)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return imports, firstErr
+}
+
+// recursivelyParseSymbols returns the symbols defined by node and, depending on
+// the parser's SymbolDepth, its members. Nodes it does not understand are
+// reported in warnings.
+func (p *treeSitterParser) recursivelyParseSymbols(node *sitter.Node, sourceCode []byte, namespace string, warnings *[]Warning) []Symbol {
+	symbols := make([]Symbol, 0)
 
-  if hasAccessModifier(node) {
-    // NOTE(jacob): For now, just assume any access modifier means this symbol is
-    //    not exported. Note this is particularly untrue for class constructors.
-    return symbols
-  }
+	if hasAccessModifier(node) {
+		// NOTE(jacob): For now, just assume any access modifier means this symbol is
+		//    not exported. Note this is particularly untrue for class constructors.
+		return symbols
+	}
 
-  if node.Type() == "function_definition" ||
-    node.Type() == "type_definition" ||
-    node.Type() == "class_definition" ||
-    node.Type() == "trait_definition" ||
-    node.Type() == "object_definition" {
+	if node.Type() == "function_definition" ||
+		node.Type() == "type_definition" ||
+		node.Type() == "class_definition" ||
+		node.Type() == "trait_definition" ||
+		node.Type() == "object_definition" {
 
-    name := node.ChildByFieldName("name")
-    symbol := namespace + name.Content(sourceCode)
-    symbols = append(symbols, symbol)
+		name := node.ChildByFieldName("name").Content(sourceCode)
+		if recovered, ok := recoverSymbolicName(node, sourceCode); ok {
+			name = recovered
+		}
+		symbol := namespace + name
+		symbols = append(symbols, newSymbol(node, sourceCode, symbol))
+
+		if p.descendInto(node) {
+			if body := node.ChildByFieldName("body"); body != nil {
+				for i := 0; i < int(body.NamedChildCount()); i++ {
+					child := body.NamedChild(i)
+					if !p.extractMember(node, child) {
+						continue
+					}
+					childSymbols := p.recursivelyParseSymbols(child, sourceCode, symbol+".", warnings)
+					symbols = append(symbols, childSymbols...)
+				}
+			}
+		}
+		// NOTE: at SymbolDepthAll they are among the members already.
+		if node.Type() == "class_definition" && p.symbolDepth == SymbolDepthMembers {
+			symbols = append(symbols, readSecondaryConstructors(node, sourceCode, symbol)...)
+		}
 
-    if node.Type() == "object_definition" {
-      if body := node.ChildByFieldName("body"); body != nil {
-        for i := 0; i < int(body.NamedChildCount()); i++ {
-          childSymbols := recursivelyParseSymbols(body.NamedChild(i), sourceCode, symbol + ".")
-          symbols = append(symbols, childSymbols...)
-        }
-      }
-    }
+	} else if node.Type() == "val_definition" || node.Type() == "var_definition" {
+		pattern := node.ChildByFieldName("pattern")
+		if pattern.Type() == "case_class_pattern" {
+			// NOTE(jacob): We could also be binding symbols via pattern case syntax, e.g.
+			//    `val Array(one, two) = Array(1, 2)`. Just ignore this for now.
+			return symbols
+		}
+
+		name := pattern.Content(sourceCode)
+		if recovered, ok := recoverSymbolicName(node, sourceCode); ok {
+			name = recovered
+		}
+		symbols = append(symbols, newSymbol(node, sourceCode, namespace+name))
+
+	} else if recovered, ok := recoverSymbolicDefinition(node, sourceCode, namespace); ok {
+		symbols = append(symbols, recovered)
+
+	} else if recovered := recoverDefinitions(node, sourceCode, namespace); len(recovered) > 0 {
+		symbols = append(symbols, recovered...)
+
+	} else if node.Type() != "comment" && node.Type() != "import_declaration" && !isLiteral(node, sourceCode) && !isSplitModifiers(node, sourceCode) {
+		// NOTE: this includes misparsed nodes nothing could be recovered from, so
+		// definitions the grammar lost are reported rather than silently dropped.
+		p.logf(LogDebug, "Unknown symbol type: %s\n", node.Type())
+		*warnings = append(*warnings, Warning{
+			Kind:    "unknown-node",
+			Message: fmt.Sprintf("unknown symbol type %s", node.Type()),
+			Line:    int(node.StartPoint().Row) + 1,
+			Node:    node.Type(),
+		})
+	}
+
+	return symbols
+}
+
+var symbolKinds = map[string]string{
+	"function_definition": "def",
+	"type_definition":     "type",
+	"class_definition":    "class",
+	"trait_definition":    "trait",
+	"object_definition":   "object",
+	"val_definition":      "val",
+	"var_definition":      "var",
+}
+
+func newSymbol(node *sitter.Node, sourceCode []byte, name string) Symbol {
+	symbol := Symbol{
+		Name:        name,
+		Kind:        symbolKinds[node.Type()],
+		Line:        int(node.StartPoint().Row) + 1,
+		Annotations: readAnnotations(node, sourceCode),
+		Modifiers:   readModifiers(node, sourceCode),
+		Parents:     readParents(node, sourceCode),
+		SelfTypes:   readSelfTypes(node, sourceCode),
+		TypeParams:  readTypeParams(node, sourceCode),
+		Doc:         readScaladoc(node, sourceCode),
+	}
+
+	symbol.Deprecation = readDeprecation(node, sourceCode, symbol.Doc)
+	symbol.Implicit = containsString(symbol.Modifiers, "implicit")
+	symbol.Inline = containsString(symbol.Modifiers, "inline") || precededByInline(node, sourceCode)
+	symbol.Constructor = symbol.Kind == "def" && strings.HasSuffix(name, ".this")
+	if symbol.Kind == "def" {
+		symbol.Signature = readSignature(node, sourceCode)
+	}
+	if symbol.Kind == "object" && containsString(symbol.Modifiers, "case") {
+		symbol.Kind = "case object"
+	}
+	if symbol.Kind == "class" && containsString(symbol.Modifiers, "case") {
+		symbol.Fields = readFields(node, sourceCode)
+	}
+	symbol.ValueClass = symbol.Kind == "class" && extendsAnyVal(symbol.Parents)
 
-  } else if node.Type() == "val_definition" || node.Type() == "var_definition" {
-    pattern := node.ChildByFieldName("pattern")
-    if pattern.Type() == "case_class_pattern" {
-      // NOTE(jacob): We could also be binding symbols via pattern case syntax, e.g.
-      //    `val Array(one, two) = Array(1, 2)`. Just ignore this for now.
-      return symbols
-    }
+	return symbol
+}
 
-    symbols = append(symbols, namespace + pattern.Content(sourceCode))
+// extendsAnyVal reports whether parents, as written, include `AnyVal`.
+func extendsAnyVal(parents []string) bool {
+	for _, parent := range parents {
+		parent = strings.TrimPrefix(strings.TrimPrefix(parent, "_root_."), "scala.")
+		if parent == "AnyVal" {
+			return true
+		}
+	}
+	return false
+}
 
-  } else if node.Type() != "comment" {
-    fmt.Printf("Unknown symbol type: %s\n", node.Type())
-  }
+// descendInto reports whether the members of the definition node should be
+// extracted under the parser's SymbolDepth.
+func (p *treeSitterParser) descendInto(node *sitter.Node) bool {
+	switch p.symbolDepth {
+	case SymbolDepthTop:
+		return false
+	default:
+		return node.Type() != "function_definition" && node.Type() != "type_definition"
+	}
+}
 
-  return symbols
+// extractMember reports whether member, a definition in the body of owner,
+// should be extracted under the parser's SymbolDepth. Objects are extracted at
+// any nesting, whatever they are nested in, so `case object`s in a trait are
+// found as those in an object are.
+func (p *treeSitterParser) extractMember(owner, member *sitter.Node) bool {
+	if p.symbolDepth != SymbolDepthMembers || owner.Type() == "object_definition" {
+		return true
+	}
+	return member.Type() == "object_definition"
 }
 
 func hasAccessModifier(node *sitter.Node) bool {
-  if modifiers := getLoneChild(node, "modifiers"); modifiers != nil {
-    if access_modifier := getLoneChild(modifiers, "access_modifier"); access_modifier != nil {
-      return true
-    }
-  }
+	if modifiers := getLoneChild(node, "modifiers"); modifiers != nil {
+		if access_modifier := getLoneChild(modifiers, "access_modifier"); access_modifier != nil {
+			return true
+		}
+	}
 
-  return false
+	return false
+}
+
+// readImportPath returns the dotted name of the path of an import declaration,
+// e.g. `com.twitter.finagle` for `import com.twitter.finagle.{Http, Service}`.
+func readImportPath(path *sitter.Node, sourceCode []byte) (string, error) {
+	// import packages are nested stable_identifiers, with the first two packages in
+	// the innermost tuple: (((identifier, identifier), identifier), identifier)
+	// e.g. path = ((("com", "twitter"), "finagle"), "http")
+	importPackage := ""
+	if path != nil && path.Type() == "identifier" {
+		// a single package, e.g. `import models.{User, Account}`
+		return path.Content(sourceCode), nil
+	} else if dotted, ok := dottedRange(path, path, sourceCode); ok {
+		// the whole path is one contiguous range, so slice it rather than walking it
+		return dotted, nil
+	}
+	for path != nil {
+		if importPackage != "" {
+			importPackage = "." + importPackage
+		}
+		segment, err := readStableIdentifier(path, sourceCode, false)
+		if err != nil {
+			return "", err
+		}
+		importPackage = segment + importPackage
+		path = getLoneChild(path, "stable_identifier")
+	}
+	return importPackage, nil
 }
 
 func getLoneChild(node *sitter.Node, name string) *sitter.Node {
@@ -192,10 +817,37 @@ func getLoneChild(node *sitter.Node, name string) *sitter.Node {
 	return nil
 }
 
-func readPackageIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) string {
-	if node.Type() != "package_identifier" {
-		fmt.Printf("Must be type 'package_identifier': %v - %s", node.Type(), node.Content(sourceCode))
-		os.Exit(1)
+// unexpectedNodeError reports a node that the readers below do not understand,
+// at its 1-based position, so the file is reported rather than the process
+// stopped.
+func unexpectedNodeError(node, within *sitter.Node, sourceCode []byte) error {
+	point := node.StartPoint()
+	return SyntaxError{
+		Line:    int(point.Row) + 1,
+		Column:  int(point.Column) + 1,
+		Message: fmt.Sprintf("unexpected node type %s within %q", node.Type(), within.Content(sourceCode)),
+	}
+}
+
+// checkNodeType returns an error unless node is of type want.
+func checkNodeType(node *sitter.Node, want string, sourceCode []byte) error {
+	if node == nil {
+		return fmt.Errorf("expected a %s node", want)
+	}
+	if node.Type() != want {
+		point := node.StartPoint()
+		return SyntaxError{
+			Line:    int(point.Row) + 1,
+			Column:  int(point.Column) + 1,
+			Message: fmt.Sprintf("expected a %s node, got %s %q", want, node.Type(), node.Content(sourceCode)),
+		}
+	}
+	return nil
+}
+
+func readPackageIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) (string, error) {
+	if err := checkNodeType(node, "package_identifier", sourceCode); err != nil {
+		return "", err
 	}
 
 	var s strings.Builder
@@ -204,6 +856,11 @@ func readPackageIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool
 	if ignoreLast {
 		total = total - 1
 	}
+	if total > 0 {
+		if dotted, ok := dottedRange(node.NamedChild(0), node.NamedChild(total-1), sourceCode); ok {
+			return dotted, nil
+		}
+	}
 
 	for c := 0; c < total; c++ {
 		nodeC := node.NamedChild(c)
@@ -214,20 +871,18 @@ func readPackageIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool
 			if s.Len() > 0 {
 				s.WriteString(".")
 			}
-			s.WriteString(nodeC.Content(sourceCode))
+			s.Write(nodeBytes(nodeC, sourceCode))
 		} else {
-			fmt.Printf("Unexpected node type '%v' within: %s", nodeC.Type(), node.Content(sourceCode))
-			os.Exit(1)
+			return "", unexpectedNodeError(nodeC, node, sourceCode)
 		}
 	}
 
-	return s.String()
+	return s.String(), nil
 }
 
-func readStableIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) string {
-	if node.Type() != "stable_identifier" {
-		fmt.Printf("Must be type 'stable_identifier': %v - %s", node.Type(), node.Content(sourceCode))
-		os.Exit(1)
+func readStableIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) (string, error) {
+	if err := checkNodeType(node, "stable_identifier", sourceCode); err != nil {
+		return "", err
 	}
 
 	var s strings.Builder
@@ -236,6 +891,16 @@ func readStableIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool)
 	if ignoreLast {
 		total = total - 1
 	}
+	// a nested stable_identifier, if any, comes first and is read by the caller
+	first := 0
+	for first < total && node.NamedChild(first).Type() == "stable_identifier" {
+		first++
+	}
+	if first < total {
+		if dotted, ok := dottedRange(node.NamedChild(first), node.NamedChild(total-1), sourceCode); ok {
+			return dotted, nil
+		}
+	}
 
 	for c := 0; c < total; c++ {
 		nodeC := node.NamedChild(c)
@@ -246,20 +911,51 @@ func readStableIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool)
 			if s.Len() > 0 {
 				s.WriteString(".")
 			}
-			s.WriteString(nodeC.Content(sourceCode))
+			s.Write(nodeBytes(nodeC, sourceCode))
 		} else if nodeC.Type() != "stable_identifier" {
-			fmt.Printf("Unexpected node type '%v' within: %s", nodeC.Type(), node.Content(sourceCode))
-			os.Exit(1)
+			return "", unexpectedNodeError(nodeC, node, sourceCode)
 		}
 	}
 
-	return s.String()
+	return s.String(), nil
 }
 
-func readImportSelectors(node *sitter.Node, sourceCode []byte) []string {
-	if node.Type() != "import_selectors" {
-		fmt.Printf("Must be type 'package_identifier': %v - %s", node.Type(), node.Content(sourceCode))
-		os.Exit(1)
+// readImportAliases returns the names bound by the renaming selectors of an
+// import declaration, keyed by the names they import, e.g. B for Bar in
+// `import foo.{Bar => B}`. A name hidden with `Bar => _` is bound to "_".
+func readImportAliases(node *sitter.Node, sourceCode []byte) map[string]string {
+	aliases := make(map[string]string)
+	selectors := getLoneChild(node, "import_selectors")
+	if selectors == nil {
+		return aliases
+	} else if hasErrorChild(selectors) {
+		return readImportAliasesText(selectors.Content(sourceCode))
+	}
+
+	for i := 0; i < int(selectors.NamedChildCount()); i++ {
+		selector := selectors.NamedChild(i)
+		if selector.Type() != "renamed_identifier" {
+			continue
+		}
+		name, alias := selector.ChildByFieldName("name"), selector.ChildByFieldName("alias")
+		if name != nil && alias != nil {
+			aliases[name.Content(sourceCode)] = alias.Content(sourceCode)
+		}
+	}
+	return aliases
+}
+
+// readImportSelectors returns the names imported by the selectors of an import,
+// e.g. `Http` and `Service` for `{Http, Service => S}`. A wildcard among them,
+// e.g. `{b, _}`, or a Scala 3 `given` selector, is read as dialect's wildcard.
+func readImportSelectors(node *sitter.Node, sourceCode []byte, dialect Dialect) ([]string, error) {
+	if err := checkNodeType(node, "import_selectors", sourceCode); err != nil {
+		return nil, err
+	}
+
+	// NOTE: the grammar fails on some operator selectors, e.g. `{<*> => ap}`.
+	if hasErrorChild(node) {
+		return readImportSelectorsText(node.Content(sourceCode)), nil
 	}
 
 	total := int(node.NamedChildCount())
@@ -268,26 +964,24 @@ func readImportSelectors(node *sitter.Node, sourceCode []byte) []string {
 	for c := 0; c < total; c++ {
 		nodeC := node.NamedChild(c)
 
-		// TODO: are there any other node types under an "identifier"
-
 		if nodeC.Type() == "identifier" {
 			imports[c] = nodeC.Content(sourceCode)
 		} else if nodeC.Type() == "renamed_identifier" {
-      // see also: nodeC.ChildByFieldName("alias")
-      imports[c] = nodeC.ChildByFieldName("name").Content(sourceCode)
-    } else {
-			fmt.Printf("Unexpected node type '%v' within: %s", nodeC.Type(), node.Content(sourceCode))
-			os.Exit(1)
+			// see also: nodeC.ChildByFieldName("alias")
+			imports[c] = nodeC.ChildByFieldName("name").Content(sourceCode)
+		} else if nodeC.Type() == "import_wildcard" {
+			imports[c] = dialect.Wildcard()
+		} else {
+			return nil, unexpectedNodeError(nodeC, node, sourceCode)
 		}
 	}
 
-	return imports
+	return imports, nil
 }
 
-func readIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) string {
-	if node.Type() != "identifier" {
-		fmt.Printf("Must be type 'identifier': %v - %s", node.Type(), node.Content(sourceCode))
-		os.Exit(1)
+func readIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) (string, error) {
+	if err := checkNodeType(node, "identifier", sourceCode); err != nil {
+		return "", err
 	}
 
 	var s strings.Builder
@@ -297,6 +991,12 @@ func readIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) strin
 		total = total - 1
 	}
 
+	if total > 0 {
+		if dotted, ok := dottedRange(node.NamedChild(0), node.NamedChild(total-1), sourceCode); ok {
+			return dotted, nil
+		}
+	}
+
 	for c := 0; c < total; c++ {
 		nodeC := node.NamedChild(c)
 
@@ -306,30 +1006,11 @@ func readIdentifier(node *sitter.Node, sourceCode []byte, ignoreLast bool) strin
 			if s.Len() > 0 {
 				s.WriteString(".")
 			}
-			s.WriteString(nodeC.Content(sourceCode))
+			s.Write(nodeBytes(nodeC, sourceCode))
 		} else if nodeC.Type() != "comment" {
-			fmt.Printf("Unexpected node type '%v' within: %s", nodeC.Type(), node.Content(sourceCode))
-			os.Exit(1)
+			return "", unexpectedNodeError(nodeC, node, sourceCode)
 		}
 	}
 
-	return s.String()
-}
-
-func main() {
-    filePath := os.Args[1]
-
-    fileBytes, err := os.ReadFile(filePath)
-    if err != nil {
-        panic(err)
-    }
-    sourceString := string(fileBytes)
-
-    parser := NewParser()
-    parseResult, errs := parser.Parse(filePath, sourceString)
-    if len(errs) != 0 {
-        fmt.Printf("%+v\n", errs)
-    }
-    fmt.Printf("%+v\n", *parseResult)
+	return s.String(), nil
 }
-
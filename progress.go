@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// progressInterval is the minimum time between redraws of the status line.
+const progressInterval = 100 * time.Millisecond
+
+// progress draws a status line on stderr during batch runs: files done, files
+// with errors and the estimated time remaining. A nil *progress draws nothing.
+type progress struct {
+	total     int
+	done      int
+	errors    int
+	start     time.Time
+	lastDrawn time.Time
+	width     int
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newProgress returns a progress line for total files, or nil if it should not
+// be shown: when stderr is not a terminal, or would be shared with other logging.
+func newProgress(total int, enabled bool) *progress {
+	if !enabled || total < 2 || cliLogLevel != LogDefault || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return &progress{total: total, start: time.Now()}
+}
+
+// fileDone records a parsed file, and whether it had errors.
+func (p *progress) fileDone(failed bool) {
+	if p == nil {
+		return
+	}
+
+	p.done++
+	if failed {
+		p.errors++
+	}
+	if time.Since(p.lastDrawn) >= progressInterval || p.done == p.total {
+		p.draw()
+	}
+}
+
+func (p *progress) draw() {
+	elapsed := time.Since(p.start)
+	eta := "?"
+	if p.done > 0 {
+		remaining := elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+		eta = remaining.Round(time.Second).String()
+	}
+
+	line := fmt.Sprintf("%d/%d files (%d%%), %d with errors, ETA %s", p.done, p.total, 100*p.done/p.total, p.errors, eta)
+	fmt.Fprintf(os.Stderr, "\r%-*s", p.width, line)
+	p.width = len(line)
+	p.lastDrawn = time.Now()
+}
+
+// clear erases the status line, before other output is written to stderr.
+func (p *progress) clear() {
+	if p == nil || p.width == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r%*s\r", p.width, "")
+	p.width = 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Capture is a node captured by a tree-sitter query.
+type Capture struct {
+	// Name is the capture's name in the query, without the `@`.
+	Name string
+	// Type is the captured node's type.
+	Type string
+	Text string
+
+	// StartLine, StartColumn, EndLine and EndColumn are 1-based.
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+}
+
+// RunQuery runs a tree-sitter query, in the `.scm` S-expression syntax, over src
+// and returns its captures in match order. Predicates such as `#eq?` and
+// `#match?` are applied.
+func RunQuery(querySource string, src []byte) ([]Capture, error) {
+	query, err := sitter.NewQuery([]byte(querySource), ScalaLang)
+	if err != nil {
+		return nil, err
+	}
+	defer query.Close()
+
+	root, err := sitter.ParseCtx(context.Background(), grammarInput(src), ScalaLang)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(query, root)
+
+	captures := make([]Capture, 0)
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+
+		match = cursor.FilterPredicates(match, src)
+		for _, capture := range match.Captures {
+			start := capture.Node.StartPoint()
+			end := capture.Node.EndPoint()
+			captures = append(captures, Capture{
+				Name:        query.CaptureNameForId(capture.Index),
+				Type:        capture.Node.Type(),
+				Text:        capture.Node.Content(src),
+				StartLine:   int(start.Row) + 1,
+				StartColumn: int(start.Column) + 1,
+				EndLine:     int(end.Row) + 1,
+				EndColumn:   int(end.Column) + 1,
+			})
+		}
+	}
+
+	return captures, nil
+}
+
+// runQueryCommand implements `query <query.scm> <file>...`, printing each capture
+// as `file:line:column: @name: text`.
+func runQueryCommand(args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: query <query.scm> <file>...")
+		os.Exit(exitUsage)
+	}
+
+	querySource, err := os.ReadFile(args[0])
+	if err != nil {
+		panic(err)
+	}
+
+	for _, filePath := range args[1:] {
+		source, err := os.ReadFile(filePath)
+		if err != nil {
+			panic(err)
+		}
+		source = prepareSource(source, UTF8)
+
+		captures, err := RunQuery(string(querySource), source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+			os.Exit(exitUsage)
+		}
+
+		for _, capture := range captures {
+			text, _, _ := strings.Cut(capture.Text, "\n")
+			fmt.Printf("%s:%d:%d: @%s: %s\n", filePath, capture.StartLine, capture.StartColumn, capture.Name, text)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// The grammar's Scala 2 symbol literals run on past the end of the name, so
+// `Map('a -> 1, 'b -> 2)` lexes as two literals that swallow the definitions
+// after them. Before parsing we replace the quote of each literal with `_`, so
+// the grammar sees an identifier of the same length in its place.
+
+// maskSymbolLiterals returns sourceCode with the quote of each symbol literal,
+// e.g. `'foo`, replaced by `_`. sourceCode is only copied if it contains one.
+func maskSymbolLiterals(sourceCode []byte) []byte {
+	if bytes.IndexByte(sourceCode, '\'') < 0 {
+		return sourceCode
+	}
+
+	masked := sourceCode
+	found := false
+
+	for i := 0; i < len(sourceCode); i++ {
+		switch {
+		case bytes.HasPrefix(sourceCode[i:], []byte("//")):
+			i = skipUntil(sourceCode, i, "\n") - 1
+		case bytes.HasPrefix(sourceCode[i:], []byte("/*")):
+			i = skipBlockComment(sourceCode, i) - 1
+		case bytes.HasPrefix(sourceCode[i:], []byte(`"""`)):
+			i = skipUntil(sourceCode, i+3, `"""`) - 1
+		case sourceCode[i] == '"':
+			i = skipStringLiteral(sourceCode, i) - 1
+		case sourceCode[i] == '\'' && startsSymbolLiteral(sourceCode, i):
+			if !found {
+				masked = bytes.Clone(sourceCode)
+				found = true
+			}
+			masked[i] = '_'
+		case sourceCode[i] == '\'' && i+2 < len(sourceCode) && (sourceCode[i+2] == '\'' || sourceCode[i+1] == '\\'):
+			i = skipStringLiteral(sourceCode, i) - 1
+		}
+	}
+
+	return masked
+}
+
+// startsSymbolLiteral reports whether the quote at i starts a symbol literal: it
+// is followed by a name that is not a character literal, e.g. `'a'`. Scala 3
+// quotes, e.g. `'{ expr }`, are left alone.
+func startsSymbolLiteral(sourceCode []byte, i int) bool {
+	if i+1 >= len(sourceCode) || !isIdentifierStart(sourceCode[i+1]) {
+		return false
+	}
+	if i > 0 && isIdentifierPart(sourceCode[i-1]) {
+		return false
+	}
+	return i+2 >= len(sourceCode) || sourceCode[i+2] != '\''
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
+
+// quasiquoteInterpolators are the string interpolators of Scala 2 quasiquotes,
+// which build trees for macros.
+var quasiquoteInterpolators = map[string]bool{"q": true, "tq": true, "cq": true, "pq": true, "fq": true}
+
+// usesQuasiquotes reports whether any string beneath node is a quasiquote, e.g.
+// `q"$x + 1"`, a hint that the file implements macros.
+func usesQuasiquotes(node *sitter.Node, sourceCode []byte) bool {
+	found := false
+	WalkNode(node, func(n *sitter.Node) bool {
+		if n.Type() == "interpolated_string_expression" && n.NamedChildCount() > 0 {
+			interpolator := n.NamedChild(0)
+			found = found || interpolator.Type() == "identifier" && quasiquoteInterpolators[interpolator.Content(sourceCode)]
+		}
+		return !found
+	})
+	return found
+}
+
+// isLiteral reports whether node is a literal, e.g. a symbol or string literal,
+// which declares nothing when it appears as a statement. Symbol literals are
+// parsed as the identifiers they are masked as.
+func isLiteral(node *sitter.Node, sourceCode []byte) bool {
+	switch node.Type() {
+	case "string":
+		return true
+	case "identifier":
+		return bytes.HasPrefix(nodeBytes(node, sourceCode), []byte("'"))
+	}
+	return strings.HasSuffix(node.Type(), "_literal")
+}
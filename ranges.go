@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// nodeBytes returns the source of node without copying it, unlike Node.Content.
+func nodeBytes(node *sitter.Node, sourceCode []byte) []byte {
+	return sourceCode[node.StartByte():node.EndByte()]
+}
+
+// dottedRange returns the source from the start of first to the end of last as
+// a single string when it is already a plain dotted name, e.g. `com.twitter.util`,
+// so readers can slice it from the buffer in one allocation rather than joining
+// each identifier. It reports false if the range holds whitespace, comments or
+// backquoted identifiers, which readers must still walk segment by segment.
+func dottedRange(first, last *sitter.Node, sourceCode []byte) (string, bool) {
+	if first == nil || last == nil || last.EndByte() < first.StartByte() {
+		return "", false
+	}
+
+	text := sourceCode[first.StartByte():last.EndByte()]
+	if len(text) == 0 || bytes.ContainsAny(text, " \t\r\n/`") {
+		return "", false
+	}
+	return string(text), true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// samePackageRefs returns the fully-qualified symbols of the file's package that
+// it references but are defined only in other files. These need no import, so
+// they're invisible to import-based dependency resolution.
+func samePackageRefs(result *ParseResult, index *Index, references map[string]bool) []string {
+	refs := make([]string, 0)
+	if result.Package == "" {
+		return refs
+	}
+
+	for _, member := range index.Members(result.Package) {
+		if !references[member[len(result.Package)+1:]] {
+			continue
+		}
+
+		files := index.Files(member)
+		if !containsString(files, result.File) {
+			refs = append(refs, member)
+		}
+	}
+
+	return refs
+}
+
+// collectReferences returns the simple names referenced anywhere in the file
+// outside of package names and imports. The bodies of package clauses in block
+// form, `package x { ... }`, are walked like the rest of the file.
+func collectReferences(node *sitter.Node, sourceCode []byte, references map[string]bool) map[string]bool {
+	switch node.Type() {
+	case "package_clause":
+		if body := node.ChildByFieldName("body"); body != nil {
+			collectReferences(body, sourceCode, references)
+		}
+		return references
+	case "import_declaration":
+		return references
+	case "identifier", "type_identifier":
+		references[strings.Trim(node.Content(sourceCode), "`")] = true
+		return references
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		collectReferences(node.NamedChild(i), sourceCode, references)
+	}
+
+	return references
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+func TestCollectReferences(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "package clause",
+			source: "package a.b\nimport c.D\nobject E extends F\n",
+			want:   []string{"E", "F"},
+		},
+		{
+			name:   "package block",
+			source: "package a.b {\n  import c.D\n  object E extends F\n}\n",
+			want:   []string{"E", "F"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := newParserPool().Get().(*sitter.Parser)
+			tree, err := parser.ParseCtx(context.Background(), nil, []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tree.Close()
+
+			var got []string
+			for name := range collectReferences(tree.RootNode(), []byte(tt.source), make(map[string]bool)) {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collectReferences = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+)
+
+// rootPackages are treated as absolute even where a package clause or wildcard
+// could shadow them; a nested package named e.g. `com` is vanishingly rare. An
+// explicit import of the same name still shadows them, as with
+// `import scala.io` followed by `import io.Source`.
+var rootPackages = map[string]bool{
+	"java": true, "javax": true, "scala": true, "com": true, "org": true, "net": true,
+}
+
+// AmbiguousImport is a relative import that could refer to more than one
+// fully-qualified name.
+type AmbiguousImport struct {
+	Import     string
+	Candidates []string
+}
+
+// importResolver expands relative imports, e.g. the second import in
+//
+//	import com.twitter.util
+//	import util.Future
+//
+// into fully-qualified names, using the file's package clauses and the imports
+// that precede it.
+type importResolver struct {
+	// packages are the prefixes brought into scope by chained package clauses,
+	// e.g. `package com.foo` followed by `package bar` gives com.foo and com.foo.bar.
+	packages []string
+
+	// bindings maps the simple names bound by explicit imports to their
+	// fully-qualified names.
+	bindings map[string]string
+
+	// wildcards are the prefixes of wildcard imports, any of which may bind a name.
+	wildcards []string
+}
+
+func newImportResolver() *importResolver {
+	return &importResolver{
+		packages: make([]string, 0),
+		bindings: make(map[string]string),
+	}
+}
+
+func (r *importResolver) addPackage(pkg string) {
+	if len(r.packages) > 0 {
+		pkg = r.packages[len(r.packages)-1] + "." + pkg
+	}
+	r.packages = append(r.packages, pkg)
+}
+
+// resolve expands the imports of a single declaration, returning any that are
+// ambiguous. All imports of a declaration share the same head. aliases are the
+// names its renaming selectors bind, keyed by the names they import; see
+// readImportAliases.
+func (r *importResolver) resolve(imports []string, aliases map[string]string, dialect Dialect) ([]string, []AmbiguousImport) {
+	ambiguous := make([]AmbiguousImport, 0)
+	resolved := make([]string, len(imports))
+
+	for i, imp := range imports {
+		head, rest, _ := strings.Cut(imp, ".")
+
+		if head == "_root_" {
+			resolved[i] = imp
+		} else if binding, ok := r.bindings[head]; ok {
+			// NOTE: explicit imports take precedence over everything else in scope,
+			//    so this is the only case that is never ambiguous.
+			if rest == "" {
+				resolved[i] = binding
+			} else {
+				resolved[i] = binding + "." + rest
+			}
+		} else if rootPackages[head] {
+			resolved[i] = imp
+		} else {
+			resolved[i] = imp
+			if candidates := r.candidates(imp); len(candidates) > 0 {
+				ambiguous = append(ambiguous, AmbiguousImport{
+					Import:     imp,
+					Candidates: append(candidates, imp),
+				})
+			}
+		}
+	}
+
+	for _, imp := range resolved {
+		if prefix, ok := strings.CutSuffix(imp, "."+dialect.Wildcard()); ok {
+			r.wildcards = append(r.wildcards, prefix)
+		} else if i := strings.LastIndex(imp, "."); i >= 0 {
+			name := imp[i+1:]
+			if alias, ok := aliases[name]; ok {
+				// NOTE: `Bar => _` hides Bar rather than binding a name.
+				if alias == "_" {
+					continue
+				}
+				name = alias
+			}
+			r.bindings[name] = imp
+		}
+	}
+
+	return resolved, ambiguous
+}
+
+// candidates lists the names an unbound relative import might refer to, other
+// than the absolute one: a member of a wildcard-imported package, or of the
+// package of any package clause, innermost first. We can't tell whether those
+// packages have a member named after the import's head, so each is a candidate.
+func (r *importResolver) candidates(imp string) []string {
+	candidates := make([]string, 0)
+
+	for _, prefix := range r.wildcards {
+		candidates = append(candidates, prefix+"."+imp)
+	}
+
+	for i := len(r.packages) - 1; i >= 0; i-- {
+		candidates = append(candidates, r.packages[i]+"."+imp)
+	}
+
+	return candidates
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveRelativeImports(t *testing.T) {
+	tests := []struct {
+		name          string
+		source        string
+		wantImports   []string
+		wantAmbiguous []AmbiguousImport
+	}{
+		{
+			name:        "import of an imported package",
+			source:      "import com.twitter.util\nimport util.Future\n",
+			wantImports: []string{"com.twitter.util", "com.twitter.util.Future"},
+		},
+		{
+			name:        "import shadowing a root package",
+			source:      "import scala.io\nimport io.Source\n",
+			wantImports: []string{"scala.io", "scala.io.Source"},
+		},
+		{
+			name:        "import of an imported name",
+			source:      "import com.twitter.util\nimport util\n",
+			wantImports: []string{"com.twitter.util", "com.twitter.util"},
+		},
+		{
+			name:        "renamed import",
+			source:      "import com.twitter.{util => tu}\nimport tu.Future\n",
+			wantImports: []string{"com.twitter.util", "com.twitter.util.Future"},
+		},
+		{
+			name:        "name of a renamed import",
+			source:      "import com.twitter.{util => tu}\nimport util.Future\n",
+			wantImports: []string{"com.twitter.util", "util.Future"},
+		},
+		{
+			name:        "hidden import",
+			source:      "import com.twitter.{util => _, _}\nimport util.Future\n",
+			wantImports: []string{"com.twitter.util", "com.twitter._", "util.Future"},
+			wantAmbiguous: []AmbiguousImport{
+				{Import: "util.Future", Candidates: []string{"com.twitter.util.Future", "util.Future"}},
+			},
+		},
+		{
+			name:        "Scala 3 renamed import",
+			source:      "import com.twitter.{util as tu}\nimport tu.Future\n",
+			wantImports: []string{"com.twitter.util", "com.twitter.util.Future"},
+		},
+		{
+			name:        "root package",
+			source:      "package a.b\nimport scala.collection.mutable\n",
+			wantImports: []string{"scala.collection.mutable"},
+		},
+		{
+			name:        "single package clause",
+			source:      "package a.b\nimport c.D\n",
+			wantImports: []string{"c.D"},
+			wantAmbiguous: []AmbiguousImport{
+				{Import: "c.D", Candidates: []string{"a.b.c.D", "c.D"}},
+			},
+		},
+		{
+			name:        "chained package clauses",
+			source:      "package a\npackage b\nimport c.D\n",
+			wantImports: []string{"c.D"},
+			wantAmbiguous: []AmbiguousImport{
+				{Import: "c.D", Candidates: []string{"a.b.c.D", "a.c.D", "c.D"}},
+			},
+		},
+		{
+			name:        "wildcard import",
+			source:      "import cats._\nimport syntax.all\n",
+			wantImports: []string{"cats._", "syntax.all"},
+			wantAmbiguous: []AmbiguousImport{
+				{Import: "syntax.all", Candidates: []string{"cats.syntax.all", "syntax.all"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := NewParser(WithRelativeImports()).ParseBytes("A.scala", []byte(tt.source))
+			if !reflect.DeepEqual(result.Imports, tt.wantImports) {
+				t.Errorf("Imports = %q, want %q", result.Imports, tt.wantImports)
+			}
+			if len(result.AmbiguousImports) > 0 || len(tt.wantAmbiguous) > 0 {
+				if !reflect.DeepEqual(result.AmbiguousImports, tt.wantAmbiguous) {
+					t.Errorf("AmbiguousImports = %v, want %v", result.AmbiguousImports, tt.wantAmbiguous)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveImportsFlag(t *testing.T) {
+	source := "package a.b\nimport com.twitter.util\nimport util.Future\n"
+	resolved := []string{"com.twitter.util", "com.twitter.util.Future"}
+
+	tests := []struct {
+		args        []string
+		config      map[string][]string
+		wantImports []string
+	}{
+		{nil, nil, []string{"com.twitter.util", "util.Future"}},
+		{[]string{"--resolve-imports"}, nil, resolved},
+		{nil, map[string][]string{"resolve-imports": {"true"}}, resolved},
+	}
+
+	for _, tt := range tests {
+		f := newParseFlags("imports", "[flags] <file>...")
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyConfig(f.FlagSet, tt.config, configFileName); err != nil {
+			t.Fatal(err)
+		}
+		result, _ := NewParser(f.options()...).ParseBytes("A.scala", []byte(source))
+		if !reflect.DeepEqual(result.Imports, tt.wantImports) {
+			t.Errorf("%q %v: Imports = %q, want %q", tt.args, tt.config, result.Imports, tt.wantImports)
+		}
+	}
+}
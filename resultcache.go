@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// resultCache stores the outcomes of parsing files by a hash of their contents
+// and of everything else affecting the outcome, so files that have not changed
+// need not be parsed again: on local disk, or on a server many machines share.
+type resultCache interface {
+	get(key string) ([]byte, error)
+	put(key string, value []byte) error
+}
+
+// errCacheMiss is returned by a resultCache's get for a key it does not hold.
+var errCacheMiss = errors.New("cache miss")
+
+// cachedParse is a parsedFile as a resultCache stores it. The findings in the
+// result's Extra are stored apart from it, in their JSON form, so they can be
+// decoded into the types the extractors recorded them as; see restoreResult.
+type cachedParse struct {
+	Result     *ParseResult
+	Extra      map[string]json.RawMessage `json:",omitempty"`
+	Errors     []string                   `json:",omitempty"`
+	ErrorLines []string                   `json:",omitempty"`
+}
+
+// diskCache is a resultCache of files in a local directory.
+type diskCache struct {
+	dir string
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+func (c *diskCache) get(key string) ([]byte, error) {
+	value, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errCacheMiss
+	}
+	return value, err
+}
+
+func (c *diskCache) put(key string, value []byte) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// NOTE: write a temporary file and rename it, so concurrent readers never see
+	// a partial entry.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// httpCache is a resultCache on an HTTP server storing each entry at its key
+// beneath a base URL, with GET and PUT, as bazel-remote and most object stores do.
+type httpCache struct {
+	base   string
+	client *http.Client
+}
+
+func (c *httpCache) get(key string) ([]byte, error) {
+	resp, err := c.client.Get(c.base + "/" + key)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, errCacheMiss
+	}
+	return nil, fmt.Errorf("GET %s: %s", c.base, resp.Status)
+}
+
+func (c *httpCache) put(key string, value []byte) error {
+	req, err := http.NewRequest(http.MethodPut, c.base+"/"+key, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT %s: %s", c.base, resp.Status)
+	}
+	return nil
+}
+
+// redisCache is a resultCache on a Redis server.
+type redisCache struct {
+	client *redis.Client
+}
+
+func newRedisCache(location string) (*redisCache, error) {
+	opts, err := redis.ParseURL(location)
+	if err != nil {
+		return nil, err
+	}
+	return &redisCache{client: redis.NewClient(opts)}, nil
+}
+
+func (c *redisCache) get(key string) ([]byte, error) {
+	value, err := c.client.Get(context.Background(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errCacheMiss
+	}
+	return value, err
+}
+
+func (c *redisCache) put(key string, value []byte) error {
+	return c.client.Set(context.Background(), key, value, 0).Err()
+}
+
+// tieredCache is a resultCache of a local cache in front of a remote one. Hits
+// in the remote cache are stored locally, and once the remote cache fails it is
+// no longer used, so an unreachable server only costs one timeout.
+type tieredCache struct {
+	local, remote resultCache
+
+	mu       sync.Mutex
+	disabled bool
+}
+
+func (c *tieredCache) remoteUsable(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil && !c.disabled {
+		logf(LogDefault, "disabling the remote cache: %v\n", err)
+		c.disabled = true
+	}
+	return !c.disabled
+}
+
+func (c *tieredCache) get(key string) ([]byte, error) {
+	if c.local != nil {
+		if value, err := c.local.get(key); err == nil {
+			return value, nil
+		}
+	}
+	if c.remote == nil || !c.remoteUsable(nil) {
+		return nil, errCacheMiss
+	}
+
+	value, err := c.remote.get(key)
+	if err != nil {
+		if err != errCacheMiss {
+			c.remoteUsable(err)
+		}
+		return nil, errCacheMiss
+	}
+	if c.local != nil {
+		c.local.put(key, value)
+	}
+	return value, nil
+}
+
+func (c *tieredCache) put(key string, value []byte) error {
+	var err error
+	if c.local != nil {
+		err = c.local.put(key, value)
+	}
+	if c.remote != nil && c.remoteUsable(nil) {
+		c.remoteUsable(c.remote.put(key, value))
+	}
+	return err
+}
+
+// openRemoteCache returns the resultCache at location: an http:// or https://
+// base URL, or a redis://[:password@]host[:port][/db] server, or rediss:// for
+// one over TLS.
+func openRemoteCache(location string) (resultCache, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpCache{base: strings.TrimSuffix(location, "/"), client: &http.Client{Timeout: 30 * time.Second}}, nil
+	case "redis", "rediss":
+		return newRedisCache(location)
+	}
+	return nil, fmt.Errorf("unsupported remote cache %q, expected an http://, https://, redis:// or rediss:// URL", location)
+}
+
+// cacheFingerprint identifies the build of the running binary, so results are
+// never shared between versions that might parse files differently.
+var cacheFingerprint = sync.OnceValue(func() string {
+	fingerprint := version
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+				fingerprint += " " + setting.Value
+			}
+		}
+	}
+	// NOTE: a development build without VCS information is identified by its
+	// own contents instead.
+	if fingerprint == "dev" {
+		if exe, err := os.Executable(); err == nil {
+			if contents, err := os.ReadFile(exe); err == nil {
+				sum := sha256.Sum256(contents)
+				fingerprint += " " + hex.EncodeToString(sum[:])
+			}
+		}
+	}
+	return fingerprint
+})
+
+// resultCacheExcludedFlags are the parse flags that never affect the outcome of
+// parsing a file, and so are left out of its cache key. --grammar is keyed by the
+// contents of the library instead.
+var resultCacheExcludedFlags = map[string]bool{
+	"config": true, "include": true, "exclude": true, "no-ignore": true, "follow-symlinks": true,
+	"git-diff": true, "stats": true, "q": true, "v": true, "vv": true, "no-progress": true,
+	"jobs": true, "max-memory": true, "cache-dir": true, "remote-cache": true,
+	"grammar": true,
+}
+
+// openResultCache returns the cache selected by --cache-dir and --remote-cache,
+// opened on first use, or nil if there is none.
+func (f *parseFlags) openResultCache() resultCache {
+	if f.cacheDir == "" && f.remoteCache == "" {
+		return nil
+	}
+	if f.cache != nil {
+		return f.cache
+	}
+
+	cache := &tieredCache{}
+	if f.cacheDir != "" {
+		cache.local = &diskCache{dir: f.cacheDir}
+	}
+	if f.remoteCache != "" {
+		remote, err := openRemoteCache(f.remoteCache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--remote-cache: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		cache.remote = remote
+	}
+
+	// NOTE: the settings are hashed into every key: the flags that can affect
+	// results, and the contents of the artifact index and of any grammar loaded
+	// in place of the bundled one.
+	var settings []string
+	f.Visit(func(fl *flag.Flag) {
+		if f.parseFlagNames[fl.Name] && !resultCacheExcludedFlags[fl.Name] {
+			settings = append(settings, fl.Name+"="+fl.Value.String())
+		}
+	})
+	sort.Strings(settings)
+	if f.artifacts != "" {
+		if contents, err := os.ReadFile(f.artifacts); err == nil {
+			sum := sha256.Sum256(contents)
+			settings = append(settings, "artifacts-sha256="+hex.EncodeToString(sum[:]))
+		}
+	}
+	if loadedGrammar != "" {
+		if contents, err := os.ReadFile(loadedGrammar); err == nil {
+			sum := sha256.Sum256(contents)
+			settings = append(settings, "grammar-sha256="+hex.EncodeToString(sum[:]))
+		}
+	}
+	f.cacheSettings = cacheFingerprint() + "\n" + strings.Join(settings, "\n")
+	f.cache = cache
+	return cache
+}
+
+// resultCacheFor returns the cache for a parse with the extra options, or nil
+// if its results cannot be cached: those depending on other files, such as
+// an Index, a Bloop build or SemanticDB files, are not.
+func (f *parseFlags) resultCacheFor(extra []Option) resultCache {
+	if len(extra) > 0 || f.bloop != "" || f.semanticDBTargetRoot != "" {
+		return nil
+	}
+	return f.openResultCache()
+}
+
+// cacheKey returns the key of the outcome of parsing sourceCode as filePath.
+func (f *parseFlags) cacheKey(filePath string, sourceCode []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n%d\n", SchemaVersion, f.cacheSettings, filePath, len(sourceCode))
+	h.Write(sourceCode)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookupParse returns the outcome of parsing a file stored in cache under key,
+// restored to match the results of parser.
+func lookupParse(cache resultCache, key string, parser Parser) (parsedFile, bool) {
+	value, err := cache.get(key)
+	if err != nil {
+		return parsedFile{}, false
+	}
+	var cached cachedParse
+	if err := json.Unmarshal(value, &cached); err != nil || cached.Result == nil {
+		logf(LogVerbose, "ignoring corrupt cache entry %s: %v\n", key, err)
+		return parsedFile{}, false
+	}
+	if err := parser.restoreResult(cached.Result, cached.Extra); err != nil {
+		logf(LogVerbose, "ignoring corrupt cache entry %s: %v\n", key, err)
+		return parsedFile{}, false
+	}
+
+	parsed := parsedFile{result: cached.Result, errorLines: cached.ErrorLines}
+	for _, err := range cached.Errors {
+		parsed.errs = append(parsed.errs, errors.New(err))
+	}
+	return parsed, true
+}
+
+// storeParse stores the outcome of parsing a file in cache under key.
+func storeParse(cache resultCache, key string, parsed parsedFile) {
+	result := *parsed.result
+	result.Extra = nil
+	cached := cachedParse{Result: &result, ErrorLines: parsed.errorLines}
+	for _, err := range parsed.errs {
+		cached.Errors = append(cached.Errors, err.Error())
+	}
+
+	var err error
+	for name, finding := range parsed.result.Extra {
+		if cached.Extra == nil {
+			cached.Extra = make(map[string]json.RawMessage, len(parsed.result.Extra))
+		}
+		if cached.Extra[name], err = json.Marshal(finding); err != nil {
+			break
+		}
+	}
+	var value []byte
+	if err == nil {
+		value, err = json.Marshal(cached)
+	}
+	if err == nil {
+		err = cache.put(key, value)
+	}
+	if err != nil {
+		logf(LogVerbose, "not caching %s: %v\n", parsed.result.File, err)
+	}
+}
+
+func (p *treeSitterParser) restoreResult(result *ParseResult, extra map[string]json.RawMessage) error {
+	decoded, err := decodeExtra(p.extractors, extra)
+	if err != nil {
+		return err
+	}
+	result.Extra = decoded
+	p.interned.internResult(result)
+	// NOTE: a fresh result's groups share the interned imports too.
+	p.interned.internAll(result.ImportGroups.Stdlib)
+	p.interned.internAll(result.ImportGroups.FirstParty)
+	p.interned.internAll(result.ImportGroups.ThirdParty)
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// classCount is the finding of classCounter.
+type classCount struct {
+	Classes int
+}
+
+// classCounter is an Extractor recording a typed finding, counting classes.
+type classCounter struct{}
+
+func (classCounter) Name() string {
+	return "class-count"
+}
+
+func (c classCounter) Visit(node *sitter.Node, sourceCode []byte, result *ParseResult) {
+	if node.Type() != "class_definition" {
+		return
+	}
+	count, _ := result.Extra[c.Name()].(classCount)
+	count.Classes++
+	result.Extra[c.Name()] = count
+}
+
+func (classCounter) DecodeExtra(data []byte) (any, error) {
+	var count classCount
+	err := json.Unmarshal(data, &count)
+	return count, err
+}
+
+func TestResultCacheRoundTrip(t *testing.T) {
+	source := `package com.example
+
+import scala.concurrent.Future
+import com.example.util.Clock
+
+/** A service. */
+@deprecated("use V2", "1.0")
+class Service[+A <: AnyRef](clock: Clock) extends Base with Logging {
+  def call(x: Int)(implicit ec: ExecutionContext): Future[A] = Future.never
+}
+
+case class Config(name: String, port: Int)
+
+object Main extends App
+`
+
+	parser := NewParser(WithExtractors(classCounter{}), WithMetrics())
+	fresh, errs := parser.ParseBytes("Service.scala", []byte(source))
+	if len(errs) > 0 {
+		t.Fatalf("errors parsing: %v", errs)
+	}
+	if _, ok := fresh.Extra["class-count"].(classCount); !ok {
+		t.Fatalf("Extra = %v, want a classCount", fresh.Extra)
+	}
+
+	cache := &diskCache{dir: t.TempDir()}
+	storeParse(cache, "0123456789", parsedFile{result: fresh})
+	parsed, ok := lookupParse(cache, "0123456789", parser)
+	if !ok {
+		t.Fatal("lookupParse missed the stored result")
+	}
+
+	if !reflect.DeepEqual(parsed.result, fresh) {
+		got, _ := json.Marshal(parsed.result)
+		want, _ := json.Marshal(fresh)
+		t.Errorf("cached result differs from a fresh parse:\n got %s (Extra %#v)\nwant %s (Extra %#v)",
+			got, parsed.result.Extra, want, fresh.Extra)
+	}
+	if unsafe.StringData(parsed.result.Imports[0]) != unsafe.StringData(fresh.Imports[0]) {
+		t.Errorf("cached import %q is not interned", parsed.result.Imports[0])
+	}
+}
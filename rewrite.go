@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// PrefixRename renames imports from the package Old, and its subpackages, to
+// New, e.g. `com.old` to `com.new`.
+type PrefixRename struct {
+	Old string
+	New string
+}
+
+// ImportRewrite is the set of transforms applied by RewriteImports.
+type ImportRewrite struct {
+	Renames []PrefixRename
+	// Expand are the wildcard imports, e.g. `com.foo._`, to replace with imports of
+	// the members each file references. A wildcard with no members referenced is
+	// kept, since it may still bring implicits into scope.
+	Expand []string
+	// Index supplies the members of expanded wildcards.
+	Index *Index
+}
+
+// rename returns the name of the import path renamed by the first matching
+// PrefixRename.
+func (r ImportRewrite) rename(path string) (string, bool) {
+	for _, rename := range r.Renames {
+		if path == rename.Old {
+			return rename.New, true
+		}
+		if rest, ok := strings.CutPrefix(path, rename.Old+"."); ok {
+			return rename.New + "." + rest, true
+		}
+	}
+	return "", false
+}
+
+func (r ImportRewrite) expands(owner string) bool {
+	return containsString(r.Expand, owner+"._") || containsString(r.Expand, owner+".*")
+}
+
+// RewriteImports returns sourceCode with rewrite applied to each of its import
+// declarations. Only the paths and wildcards of declarations are rewritten, so
+// renamed packages named inside selectors, e.g. `import com.{old => o}`, are left
+// alone.
+func RewriteImports(filePath string, sourceCode []byte, rewrite ImportRewrite) ([]byte, error) {
+	root, parsed, offset, err := parseForCodemod(filePath, sourceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	var references map[string]bool
+	if len(rewrite.Expand) > 0 {
+		references = collectReferences(root, parsed, make(map[string]bool))
+	}
+
+	var edits []textEdit
+	var walkErr error
+	replace := func(node *sitter.Node, text string) {
+		edits = append(edits, textEdit{int(node.StartByte()) - offset, int(node.EndByte()) - offset, text})
+	}
+
+	WalkNode(root, func(node *sitter.Node) bool {
+		if node.Type() != "import_declaration" {
+			return true
+		}
+		path := node.ChildByFieldName("path")
+		if path == nil {
+			return false
+		}
+		name, err := readImportPath(path, parsed)
+		if err != nil {
+			walkErr = err
+			return false
+		}
+
+		if wildcard := getLoneChild(node, "import_wildcard"); wildcard != nil && rewrite.expands(name) && rewrite.Index != nil {
+			if selectors := referencedMembers(rewrite.Index, name, references); len(selectors) > 0 {
+				replace(wildcard, formatSelectors(selectors))
+			}
+		}
+		if renamed, ok := rewrite.rename(name); ok {
+			replace(path, renamed)
+		}
+		return false
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	return applyEdits(sourceCode, edits), nil
+}
+
+// referencedMembers returns the simple names of the members of owner in index
+// that are among references.
+func referencedMembers(index *Index, owner string, references map[string]bool) []string {
+	var names []string
+	for _, member := range index.Members(owner) {
+		name := member[strings.LastIndex(member, ".")+1:]
+		if references[name] && !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// formatSelectors formats names as the selectors of an import, e.g. `{A, B}`.
+func formatSelectors(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return fmt.Sprintf("{%s}", strings.Join(names, ", "))
+}
+
+// runRewriteImportsCommand implements `rewrite-imports`.
+func runRewriteImportsCommand(args []string) {
+	f := newParseFlags("rewrite-imports", "[flags] <file or directory>...")
+	var renames, expand stringList
+	f.Var(&renames, "rename", "rename imports from a package and its subpackages, e.g. com.old=com.new (repeatable)")
+	f.Var(&expand, "expand", "replace a wildcard import, e.g. com.foo._, with imports of the members each file references (repeatable)")
+	codemod := addCodemodFlags(f)
+	f.parse(args)
+
+	rewrite := ImportRewrite{Expand: splitPatterns(expand)}
+	for _, value := range renames {
+		old, new, ok := strings.Cut(value, "=")
+		if !ok || old == "" || new == "" {
+			fmt.Fprintf(os.Stderr, "invalid --rename %q, expected old=new\n", value)
+			os.Exit(exitUsage)
+		}
+		rewrite.Renames = append(rewrite.Renames, PrefixRename{Old: old, New: new})
+	}
+
+	files := f.files()
+	if len(rewrite.Expand) > 0 {
+		// NOTE: a wildcard's members may be defined by any of the files, so index
+		// them all before rewriting any.
+		rewrite.Index = NewIndex()
+		f.parseFiles(files, rewrite.Index.Add)
+	}
+
+	codemod.rewriteFiles(files, func(filePath string, sourceCode []byte) ([]byte, error) {
+		return RewriteImports(filePath, sourceCode, rewrite)
+	})
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	treeutils "aspect.build/cli/gazelle/common/treesitter"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// SbtBuild is the dependency information declared by an sbt build definition,
+// either a `build.sbt` file or a Scala file under `project/`.
+type SbtBuild struct {
+	// SchemaVersion is the version of this structure's JSON form; see SchemaVersion.
+	SchemaVersion int `json:"schemaVersion"`
+
+	File string
+
+	Organization       string
+	ScalaVersion       string
+	CrossScalaVersions []string
+
+	// LibraryDependencies are declared outside of any project, and so apply to
+	// the root project (or to every project, when set via `ThisBuild`).
+	LibraryDependencies []SbtDependency
+	Projects            []SbtProject
+}
+
+// SbtProject is a project defined with `project`, `project.in(file(...))` or
+// `Project(id, file(...))`.
+type SbtProject struct {
+	ID                  string
+	Directory           string
+	ModuleName          string
+	LibraryDependencies []SbtDependency
+	DependsOn           []string
+}
+
+// SbtDependency is a library dependency, e.g. `"org" %% "name" % "1.0" % Test`.
+type SbtDependency struct {
+	Dependency
+	Configuration string
+}
+
+// SbtParser extracts dependency coordinates from sbt build definitions. Like the
+// source parser it is safe for concurrent use.
+type SbtParser struct {
+	parsers *sync.Pool
+}
+
+func NewSbtParser() *SbtParser {
+	return &SbtParser{
+		parsers: newParserPool(),
+	}
+}
+
+func isSbtBuildFile(filePath string) bool {
+	return strings.HasSuffix(filePath, ".sbt") ||
+		(strings.HasSuffix(filePath, ".scala") && filepath.Base(filepath.Dir(filePath)) == "project")
+}
+
+func (s *SbtParser) Parse(filePath string, sourceCode []byte) (*SbtBuild, []error) {
+	build := &SbtBuild{
+		SchemaVersion:       SchemaVersion,
+		File:                filePath,
+		CrossScalaVersions:  make([]string, 0),
+		LibraryDependencies: make([]SbtDependency, 0),
+		Projects:            make([]SbtProject, 0),
+	}
+
+	errs := make([]error, 0)
+
+	// .sbt files are a sequence of settings expressions, which only parse inside a
+	// template body; see wrapScript.
+	if strings.HasSuffix(filePath, ".sbt") {
+		sourceCode = wrapScript(sourceCode)
+	}
+
+	parser := s.parsers.Get().(*sitter.Parser)
+	tree, err := parser.ParseCtx(context.Background(), nil, grammarInput(sourceCode))
+	s.parsers.Put(parser)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if tree != nil {
+		defer tree.Close()
+		rootNode := tree.RootNode()
+
+		walker := sbtWalker{
+			build:      build,
+			sourceCode: sourceCode,
+			strings:    make(map[string]string),
+		}
+		walker.collectStrings(rootNode)
+		walker.walk(rootNode, noProject)
+
+		treeErrors := treeutils.QueryErrors(ScalaTreeSitterName, ScalaLang, sourceCode, rootNode)
+		if treeErrors != nil {
+			errs = append(errs, treeErrors...)
+		}
+	}
+
+	return build, errs
+}
+
+type sbtWalker struct {
+	build      *SbtBuild
+	sourceCode []byte
+
+	// strings holds `val name = "literal"` definitions, so versions kept in vals
+	// can be resolved.
+	strings map[string]string
+}
+
+func (w *sbtWalker) collectStrings(node *sitter.Node) {
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() == "val_definition" {
+			pattern := child.ChildByFieldName("pattern")
+			value := child.ChildByFieldName("value")
+			if pattern != nil && value != nil && pattern.Type() == "identifier" && value.Type() == "string" {
+				w.strings[pattern.Content(w.sourceCode)] = unquote(value.Content(w.sourceCode))
+			}
+		} else {
+			w.collectStrings(child)
+		}
+	}
+}
+
+// noProject is the project index of settings outside any project definition.
+const noProject = -1
+
+// project returns the project at index i of the build, or nil for noProject.
+// Projects are addressed by index because appending a project may move the
+// others, so a pointer is only valid until the next project is defined.
+func (w *sbtWalker) project(i int) *SbtProject {
+	if i == noProject {
+		return nil
+	}
+	return &w.build.Projects[i]
+}
+
+func (w *sbtWalker) walk(node *sitter.Node, project int) {
+	switch node.Type() {
+	case "val_definition":
+		pattern := node.ChildByFieldName("pattern")
+		if pattern != nil && w.isProjectDefinition(node.ChildByFieldName("value")) {
+			id := pattern.Content(w.sourceCode)
+			w.build.Projects = append(w.build.Projects, SbtProject{
+				ID:                  id,
+				Directory:           id,
+				LibraryDependencies: make([]SbtDependency, 0),
+				DependsOn:           make([]string, 0),
+			})
+			project = len(w.build.Projects) - 1
+		}
+
+	case "infix_expression":
+		w.readSettings(flattenInfix(node), project)
+		return
+
+	case "call_expression":
+		w.readProjectCall(node, project)
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		w.walk(node.NamedChild(i), project)
+	}
+}
+
+// isProjectDefinition reports whether value is a project expression, i.e. its
+// receiver chain starts with `project` or `Project(...)`.
+func (w *sbtWalker) isProjectDefinition(value *sitter.Node) bool {
+	for value != nil {
+		switch value.Type() {
+		case "identifier":
+			return value.Content(w.sourceCode) == "project"
+		case "call_expression":
+			value = value.ChildByFieldName("function")
+		case "field_expression":
+			value = value.ChildByFieldName("value")
+		case "infix_expression":
+			value = value.ChildByFieldName("left")
+		case "parenthesized_expression":
+			value = value.NamedChild(0)
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+// readProjectCall handles the calls that configure a project: `file(...)`,
+// `Project(id, file(...))` and `dependsOn(...)`.
+func (w *sbtWalker) readProjectCall(node *sitter.Node, index int) {
+	project := w.project(index)
+	if project == nil {
+		return
+	}
+
+	function := node.ChildByFieldName("function")
+	arguments := node.ChildByFieldName("arguments")
+	if function == nil || arguments == nil {
+		return
+	}
+
+	name := function.Content(w.sourceCode)
+	if function.Type() == "field_expression" {
+		name = function.ChildByFieldName("field").Content(w.sourceCode)
+	}
+
+	switch name {
+	case "file":
+		if dir, ok := w.readString(arguments.NamedChild(0)); ok {
+			project.Directory = dir
+		}
+	case "Project":
+		if id, ok := w.readString(arguments.NamedChild(0)); ok {
+			project.ModuleName = id
+		}
+	case "dependsOn":
+		for i := 0; i < int(arguments.NamedChildCount()); i++ {
+			// e.g. `core % "test->test"`
+			terms := flattenInfix(arguments.NamedChild(i))
+			if len(terms) > 0 && terms[0].Type() == "identifier" {
+				project.DependsOn = append(project.DependsOn, terms[0].Content(w.sourceCode))
+			}
+		}
+	}
+}
+
+// readSettings reads settings and dependencies from a flattened infix chain. The
+// grammar gives every operator the same precedence, so e.g.
+// `libraryDependencies += "org" %% "name" % "1.0"` becomes one left-nested chain.
+func (w *sbtWalker) readSettings(terms []*sitter.Node, index int) {
+	for i := 0; i < len(terms); i++ {
+		// Walking a term may define a project, so the pointer is read afresh.
+		project := w.project(index)
+		if dep, n, ok := w.readDependency(terms[i:]); ok {
+			if project != nil {
+				project.LibraryDependencies = append(project.LibraryDependencies, dep)
+			} else {
+				w.build.LibraryDependencies = append(w.build.LibraryDependencies, dep)
+			}
+			i += n - 1
+			continue
+		}
+
+		if terms[i].Type() != "operator_identifier" || terms[i].Content(w.sourceCode) != ":=" ||
+			i == 0 || i+1 == len(terms) {
+			if terms[i].Type() != "operator_identifier" && terms[i].Type() != "identifier" {
+				w.walk(terms[i], index)
+			}
+			continue
+		}
+
+		key := terms[i-1].Content(w.sourceCode)
+		value := terms[i+1]
+		switch key {
+		case "name", "moduleName":
+			if name, ok := w.readString(value); ok && project != nil {
+				project.ModuleName = name
+			}
+		case "organization":
+			if organization, ok := w.readString(value); ok {
+				w.build.Organization = organization
+			}
+		case "scalaVersion":
+			if version, ok := w.readString(value); ok {
+				w.build.ScalaVersion = version
+			}
+		case "crossScalaVersions":
+			if arguments := value.ChildByFieldName("arguments"); arguments != nil {
+				for j := 0; j < int(arguments.NamedChildCount()); j++ {
+					if version, ok := w.readString(arguments.NamedChild(j)); ok {
+						w.build.CrossScalaVersions = append(w.build.CrossScalaVersions, version)
+					}
+				}
+			}
+		}
+	}
+}
+
+// readDependency matches `org %% name % version [% configuration]` at the start of
+// terms, returning the number of terms consumed.
+func (w *sbtWalker) readDependency(terms []*sitter.Node) (SbtDependency, int, bool) {
+	var dep SbtDependency
+	if len(terms) < 5 {
+		return dep, 0, false
+	}
+
+	organization, ok := w.readString(terms[0])
+	if !ok {
+		return dep, 0, false
+	}
+
+	switch terms[1].Content(w.sourceCode) {
+	case "%":
+	case "%%", "%%%":
+		dep.CrossVersion = true
+	default:
+		return dep, 0, false
+	}
+
+	name, ok := w.readString(terms[2])
+	if !ok || terms[3].Content(w.sourceCode) != "%" {
+		return dep, 0, false
+	}
+
+	version, ok := w.readString(terms[4])
+	if !ok {
+		return dep, 0, false
+	}
+
+	dep.Organization = organization
+	dep.Name = name
+	dep.Version = version
+	consumed := 5
+
+	if len(terms) >= 7 && terms[5].Content(w.sourceCode) == "%" {
+		dep.Configuration = unquote(terms[6].Content(w.sourceCode))
+		consumed = 7
+	}
+
+	return dep, consumed, true
+}
+
+// readString reads a string literal, or an identifier naming a string val.
+func (w *sbtWalker) readString(node *sitter.Node) (string, bool) {
+	if node == nil {
+		return "", false
+	} else if node.Type() == "string" {
+		return unquote(node.Content(w.sourceCode)), true
+	} else if node.Type() == "identifier" {
+		value, ok := w.strings[node.Content(w.sourceCode)]
+		return value, ok
+	}
+
+	return "", false
+}
+
+// flattenInfix returns the operands and operators of a chain of infix
+// expressions, in source order. Fields missing from a partial or erroneous
+// expression are left out.
+func flattenInfix(node *sitter.Node) []*sitter.Node {
+	if node == nil {
+		return nil
+	} else if node.Type() != "infix_expression" {
+		return []*sitter.Node{node}
+	}
+
+	terms := flattenInfix(node.ChildByFieldName("left"))
+	if operator := node.ChildByFieldName("operator"); operator != nil {
+		terms = append(terms, operator)
+	}
+	return append(terms, flattenInfix(node.ChildByFieldName("right"))...)
+}
+
+func unquote(literal string) string {
+	return strings.Trim(literal, "\"")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// The grammar predates several Scala 3 definition forms, and misparses them as
+// expressions or errors. Where the symbol walker meets such a node, we recover
+// the definitions from its source text one line at a time.
+
+// namedGiven matches `given intOrd: Ord[Int] with` and `given intOrd(using ...): ...`.
+var namedGiven = regexp.MustCompile(`^\s*given\s+([A-Za-z_][A-Za-z0-9_]*)\s*(?:\[[^\]]*\])?\s*(?:\(.*\))?\s*:`)
+
+// anonymousGiven matches `given Ord[Int] with` and `given Ord[String] = ...`.
+var anonymousGiven = regexp.MustCompile(`^\s*given\s+([A-Za-z_][A-Za-z0-9_.]*(?:\[[^=]*\])?)\s*(?:with\b|=)`)
+
+// inlineDefinition matches `inline def f`, `transparent inline def f` and
+// `inline val x`, after any modifiers.
+var inlineDefinition = regexp.MustCompile("^\\s*((?:(?:override|final|private|protected)(?:\\[\\w+\\])?\\s+)*)(?:transparent\\s+)?inline\\s+(def|val)\\s+([A-Za-z_]\\w*|[!#%&*+\\-/:<=>?@\\\\^|~]+|`[^`]+`)")
+
+// definitionModifiers are the modifier keywords that may precede `inline` on the
+// line of a definition.
+var definitionModifiers = map[string]bool{
+	"inline": true, "transparent": true, "override": true, "final": true, "implicit": true,
+	"private": true, "protected": true, "abstract": true, "lazy": true,
+}
+
+// precededByInline reports whether the definition node follows an `inline`
+// modifier on its line. The grammar leaves the modifier out of top-level
+// definitions, in an ERROR node before them or in the expression before that,
+// e.g. `(ERROR "inline") (function_definition ...)`.
+func precededByInline(node *sitter.Node, sourceCode []byte) bool {
+	start := int(node.StartByte())
+	lineStart := start
+	for lineStart > 0 && sourceCode[lineStart-1] != '\n' {
+		lineStart--
+	}
+
+	inline := false
+	for _, word := range strings.Fields(string(sourceCode[lineStart:start])) {
+		if !definitionModifiers[word] {
+			return false
+		}
+		inline = inline || word == "inline"
+	}
+	return inline
+}
+
+// misparsedDefinitions are the node types the grammar makes of the Scala 3
+// definitions it does not know, e.g. an infix_expression of `given Ord[Int] with
+// {...}` or an assignment_expression of `inline val x = 1`.
+var misparsedDefinitions = map[string]bool{
+	"ERROR":                 true,
+	"assignment_expression": true,
+	"ascription_expression": true,
+	"call_expression":       true,
+	"infix_expression":      true,
+}
+
+// isSplitModifiers reports whether node is an ERROR holding only the modifiers
+// of the definition after it, e.g. `transparent inline`; see precededByInline.
+func isSplitModifiers(node *sitter.Node, sourceCode []byte) bool {
+	if !node.IsError() {
+		return false
+	}
+	words := strings.Fields(node.Content(sourceCode))
+	for _, word := range words {
+		if !definitionModifiers[word] {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// recoverDefinitions returns the Scala 3 definitions found in the source text of
+// a misparsed node, or none for nodes of other types.
+func recoverDefinitions(node *sitter.Node, sourceCode []byte, namespace string) []Symbol {
+	symbols := make([]Symbol, 0)
+	if !misparsedDefinitions[node.Type()] {
+		return symbols
+	}
+	firstLine := int(node.StartPoint().Row) + 1
+
+	// NOTE: the first line of a node may start mid-line, so take the line it is on.
+	start := int(node.StartByte())
+	for start > 0 && sourceCode[start-1] != '\n' {
+		start--
+	}
+
+	lines := strings.Split(string(sourceCode[start:node.EndByte()]), "\n")
+	for i, line := range lines {
+		if match := namedGiven.FindStringSubmatch(line); match != nil {
+			symbols = append(symbols, Symbol{
+				Name:     namespace + match[1],
+				Kind:     "given",
+				Line:     firstLine + i,
+				Implicit: true,
+			})
+		} else if match := inlineDefinition.FindStringSubmatch(line); match != nil {
+			// NOTE: as with parsed definitions, any access modifier hides the symbol
+			if strings.Contains(match[1], "private") || strings.Contains(match[1], "protected") {
+				continue
+			}
+			symbols = append(symbols, Symbol{
+				Name:   namespace + match[3],
+				Kind:   match[2],
+				Line:   firstLine + i,
+				Inline: true,
+			})
+		} else if match := anonymousGiven.FindStringSubmatch(line); match != nil {
+			symbols = append(symbols, Symbol{
+				Name:     namespace + anonymousGivenName(match[1]),
+				Kind:     "given",
+				Line:     firstLine + i,
+				Implicit: true,
+			})
+		}
+	}
+
+	return symbols
+}
+
+// anonymousGivenName approximates the compiler's naming of anonymous givens, e.g.
+// `given Ord[Int]` is named `given_Ord_Int`.
+func anonymousGivenName(givenType string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '[' || r == ']' || r == ',' || r == ' ' || r == '.' {
+			return '_'
+		}
+		return r
+	}, givenType)
+
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '_' })
+	return "given_" + strings.Join(parts, "_")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Scaladoc is a `/** ... */` comment attached to a definition.
+type Scaladoc struct {
+	// Raw is the comment exactly as written, including delimiters.
+	Raw string
+	// Description is the text before the first tag, with comment markup removed.
+	Description string
+	// Params maps `@param` names to their descriptions.
+	Params map[string]string
+	// Deprecated is set by a `@deprecated` tag, with its message, if any.
+	Deprecated        bool
+	DeprecatedMessage string
+	// Tags holds every other tag, e.g. `@return` or `@see`, keyed without the `@`.
+	Tags map[string][]string
+}
+
+// readScaladoc returns the scaladoc immediately preceding a definition, or nil.
+func readScaladoc(node *sitter.Node, sourceCode []byte) *Scaladoc {
+	prev := node.PrevNamedSibling()
+	if prev == nil || prev.Type() != "comment" {
+		return nil
+	}
+
+	raw := prev.Content(sourceCode)
+	if !strings.HasPrefix(raw, "/**") || raw == "/**/" {
+		return nil
+	}
+
+	return parseScaladoc(raw)
+}
+
+func parseScaladoc(raw string) *Scaladoc {
+	doc := &Scaladoc{
+		Raw:    raw,
+		Params: make(map[string]string),
+		Tags:   make(map[string][]string),
+	}
+
+	body := strings.TrimSuffix(strings.TrimPrefix(raw, "/**"), "*/")
+
+	var description []string
+	tag := ""
+	var tagText []string
+
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(tagText, " "))
+		switch tag {
+		case "":
+			description = tagText
+		case "param":
+			name, text, _ := strings.Cut(text, " ")
+			doc.Params[name] = strings.TrimSpace(text)
+		case "deprecated":
+			doc.Deprecated = true
+			doc.DeprecatedMessage = text
+		default:
+			doc.Tags[tag] = append(doc.Tags[tag], text)
+		}
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+
+		if strings.HasPrefix(line, "@") {
+			flush()
+			name, text, _ := strings.Cut(line[1:], " ")
+			tag = name
+			tagText = []string{text}
+		} else {
+			tagText = append(tagText, line)
+		}
+	}
+	flush()
+
+	doc.Description = strings.TrimSpace(strings.Join(description, "\n"))
+	return doc
+}
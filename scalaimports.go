@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/emirpasic/gods/sets/treeset"
+)
+
+// ScalaImports is a sorted set of imports, for callers such as Gazelle resolvers
+// that need to deduplicate imports across files and look them up by package.
+// The zero value is not usable; create one with NewScalaImports. It is not safe
+// for concurrent use.
+type ScalaImports struct {
+	imports *treeset.Set
+}
+
+// NewScalaImports returns a set holding imports.
+func NewScalaImports(imports ...string) *ScalaImports {
+	set := &ScalaImports{imports: treeset.NewWithStringComparator()}
+	set.Add(imports...)
+	return set
+}
+
+// Add adds imports to the set, ignoring any it already holds.
+func (s *ScalaImports) Add(imports ...string) {
+	for _, imp := range imports {
+		s.imports.Add(imp)
+	}
+}
+
+func (s *ScalaImports) Contains(imp string) bool {
+	return s.imports.Contains(imp)
+}
+
+func (s *ScalaImports) Len() int {
+	return s.imports.Size()
+}
+
+// SortedSlice returns the imports in the set in lexical order.
+func (s *ScalaImports) SortedSlice() []string {
+	imports := make([]string, 0, s.imports.Size())
+	for it := s.imports.Iterator(); it.Next(); {
+		imports = append(imports, it.Value().(string))
+	}
+	return imports
+}
+
+// WithPrefix returns the imports in the set starting with prefix, in lexical
+// order. Use a trailing dot, e.g. "com.twitter.", to find the imports from a
+// package.
+func (s *ScalaImports) WithPrefix(prefix string) []string {
+	var imports []string
+	it := s.imports.Iterator()
+	// NOTE: imports sharing a prefix are adjacent, so stop at the first one past them.
+	if !it.NextTo(func(_ int, value any) bool { return value.(string) >= prefix }) {
+		return imports
+	}
+	for {
+		imp := it.Value().(string)
+		if !strings.HasPrefix(imp, prefix) {
+			break
+		}
+		imports = append(imports, imp)
+		if !it.Next() {
+			break
+		}
+	}
+	return imports
+}
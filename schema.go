@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+)
+
+// SchemaVersion is embedded in all JSON output as `schemaVersion`. It is bumped
+// whenever a field is removed or changes meaning; adding fields does not bump it.
+const SchemaVersion = 1
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// ParseResultSchema returns a JSON Schema document describing the JSON form of
+// ParseResult, generated from its Go definition.
+func ParseResultSchema() map[string]any {
+	defs := make(map[string]any)
+	schema := structSchema(reflect.TypeOf(ParseResult{}), defs)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "ParseResult"
+	schema["properties"].(map[string]any)["schemaVersion"] = map[string]any{"const": SchemaVersion}
+	schema["$defs"] = defs
+	return schema
+}
+
+// typeSchema returns the schema of t, adding the schemas of named structs to defs
+// and referring to them by name.
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	if t.Implements(textMarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return map[string]any{"anyOf": []any{typeSchema(t.Elem(), defs), map[string]any{"type": "null"}}}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		// NOTE: encoding/json writes nil slices as null.
+		return map[string]any{"type": []string{"array", "null"}, "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = true // reserve the name, in case t refers to itself
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+	addStructFields(t, defs, properties, &required)
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// addStructFields adds the properties encoding/json writes for t's fields,
+// including those promoted from embedded structs.
+func addStructFields(t reflect.Type, defs map[string]any, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addStructFields(field.Type, defs, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = typeSchema(field.Type, defs)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
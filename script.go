@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Scripts (Ammonite, scala-cli and worksheets) allow top-level statements, which
+// the grammar rejects. Like Ammonite itself, we wrap the script in an object
+// before parsing and then treat that object's body as the top level. The prefix
+// has no newline so that line numbers are unchanged.
+const scriptPrefix = "object $script {"
+const scriptSuffix = "\n}\n"
+
+// Dependency is a Maven artifact in the coordinate syntax shared by Ammonite,
+// scala-cli and coursier: `org::name:version`, where `::` marks an artifact that
+// is cross-published per Scala version.
+type Dependency struct {
+	Organization string
+	Name         string
+	Version      string
+	CrossVersion bool
+}
+
+func (d Dependency) String() string {
+	separator := ":"
+	if d.CrossVersion {
+		separator = "::"
+	}
+
+	coordinates := d.Organization + separator + d.Name
+	if d.Version != "" {
+		coordinates += ":" + d.Version
+	}
+
+	return coordinates
+}
+
+// parseDependency parses coordinates such as `com.lihaoyi::os-lib:0.9.1`. The
+// version is optional; a Scala platform suffix (`:::`) is treated as a cross version.
+func parseDependency(coordinates string) (Dependency, bool) {
+	coordinates = strings.Trim(strings.TrimSpace(coordinates), "`\"")
+
+	var dep Dependency
+	organization, rest, found := strings.Cut(coordinates, ":")
+	if !found || organization == "" {
+		return dep, false
+	}
+
+	dep.Organization = organization
+	if strings.HasPrefix(rest, ":") {
+		dep.CrossVersion = true
+		rest = strings.TrimLeft(rest, ":")
+	}
+
+	dep.Name, dep.Version, _ = strings.Cut(rest, ":")
+	if dep.Name == "" {
+		return dep, false
+	}
+
+	return dep, true
+}
+
+func isScriptFile(filePath string) bool {
+	return strings.HasSuffix(filePath, ".sc")
+}
+
+func wrapScript(sourceCode []byte) []byte {
+	wrapped := make([]byte, 0, len(scriptPrefix)+len(sourceCode)+len(scriptSuffix))
+	wrapped = append(wrapped, scriptPrefix...)
+	wrapped = append(wrapped, sourceCode...)
+
+	// Blank out a shebang line, keeping byte offsets intact.
+	if bytes.HasPrefix(sourceCode, []byte("#!")) {
+		for i := len(scriptPrefix); i < len(wrapped) && wrapped[i] != '\n'; i++ {
+			wrapped[i] = ' '
+		}
+	}
+
+	return append(wrapped, scriptSuffix...)
+}
+
+// scriptBody returns the body of the object wrapping a script, whose children are
+// the script's top-level statements.
+func scriptBody(rootNode *sitter.Node) *sitter.Node {
+	if wrapper := getLoneChild(rootNode, "object_definition"); wrapper != nil {
+		if body := wrapper.ChildByFieldName("body"); body != nil {
+			return body
+		}
+	}
+
+	return rootNode
+}
+
+// readMagicImports handles Ammonite's $ivy (and the newer $dep) imports, e.g.
+// import $ivy.`org::name:version`, which load dependencies rather than naming symbols.
+// Returns false if node is an ordinary import.
+func readMagicImports(node *sitter.Node, sourceCode []byte) ([]Dependency, bool) {
+	deps := make([]Dependency, 0)
+	magic := false
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		expr := node.NamedChild(i)
+		if expr.Type() != "stable_identifier" || expr.NamedChildCount() != 2 {
+			continue
+		}
+
+		root := expr.NamedChild(0).Content(sourceCode)
+		if !strings.HasPrefix(root, "$") {
+			continue
+		}
+
+		// NOTE: other magic imports ($file, $exec, ...) refer to local scripts, so
+		//    they are dropped rather than reported as package imports.
+		magic = true
+		if root == "$ivy" || root == "$dep" {
+			if dep, ok := parseDependency(expr.NamedChild(1).Content(sourceCode)); ok {
+				deps = append(deps, dep)
+			}
+		}
+	}
+
+	return deps, magic
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// semanticDB locates the SemanticDB files written by the compiler's semanticdb
+// plugin, which records exactly which symbol every name in a file resolves to.
+type semanticDB struct {
+	// targetRoot is the compiler's `-P:semanticdb:targetroot`, and sourceRoot its
+	// `-sourceroot`, which the paths of documents are relative to.
+	targetRoot string
+	sourceRoot string
+}
+
+// WithSemanticDB reads the SemanticDB of each file, written by the compiler to
+// targetRoot for sources beneath sourceRoot, and records the symbols the file's
+// references resolve to in ResolvedReferences. These are exact where the syntax
+// alone is not, e.g. for names brought in by implicits, wildcards or renaming
+// imports. Files without a SemanticDB are parsed as usual.
+func WithSemanticDB(targetRoot, sourceRoot string) Option {
+	return func(p *treeSitterParser) {
+		p.semanticDB = &semanticDB{targetRoot: targetRoot, sourceRoot: sourceRoot}
+	}
+}
+
+// resolvedReferences returns the top-level classes and objects referenced by the
+// file at filePath according to its SemanticDB, other than those it defines, or
+// nil if it has none.
+func (db *semanticDB) resolvedReferences(filePath string) ([]string, error) {
+	rel, err := filepath.Rel(db.sourceRoot, filePath)
+	if err != nil || !filepath.IsLocal(rel) {
+		// NOTE: files outside the source root have no SemanticDB, and their paths
+		// must not reach outside the target root either.
+		return nil, nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	data, err := os.ReadFile(filepath.Join(db.targetRoot, "META-INF", "semanticdb", filepath.FromSlash(rel)+".semanticdb"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	occurrences, err := readSemanticOccurrences(data, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	defined := make(map[string]bool)
+	for _, occurrence := range occurrences {
+		if occurrence.role == semanticDefinition {
+			defined[occurrence.symbol] = true
+		}
+	}
+
+	references := make([]string, 0)
+	for _, occurrence := range occurrences {
+		if occurrence.role != semanticReference || defined[occurrence.symbol] {
+			continue
+		}
+		if name, ok := semanticTopLevel(occurrence.symbol); ok {
+			references = append(references, name)
+		}
+	}
+	return sortedUnique(references), nil
+}
+
+// SymbolOccurrence roles, from the SemanticDB specification.
+const (
+	semanticReference  = 1
+	semanticDefinition = 2
+)
+
+type semanticOccurrence struct {
+	symbol string
+	role   uint64
+}
+
+// readSemanticOccurrences decodes the symbol occurrences of the document for uri
+// from a TextDocuments message. Only the fields needed are decoded, so no
+// protobuf library is needed:
+//
+//	TextDocuments    { repeated TextDocument documents = 1; }
+//	TextDocument     { string uri = 2; repeated SymbolOccurrence occurrences = 6; }
+//	SymbolOccurrence { string symbol = 2; Role role = 3; }
+func readSemanticOccurrences(data []byte, uri string) ([]semanticOccurrence, error) {
+	var occurrences []semanticOccurrence
+	err := readProtoFields(data, func(field int, _ uint64, document []byte) error {
+		if field != 1 {
+			return nil
+		}
+
+		var documentURI string
+		var documentOccurrences []semanticOccurrence
+		err := readProtoFields(document, func(field int, _ uint64, value []byte) error {
+			switch field {
+			case 2:
+				documentURI = string(value)
+			case 6:
+				var occurrence semanticOccurrence
+				err := readProtoFields(value, func(field int, varint uint64, value []byte) error {
+					switch field {
+					case 2:
+						occurrence.symbol = string(value)
+					case 3:
+						occurrence.role = varint
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				documentOccurrences = append(documentOccurrences, occurrence)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if documentURI == uri {
+			occurrences = append(occurrences, documentOccurrences...)
+		}
+		return nil
+	})
+	return occurrences, err
+}
+
+// readProtoFields calls fn with each field of a protobuf message, passing the
+// value of varint fields as varint and of length-delimited fields as value.
+func readProtoFields(data []byte, fn func(field int, varint uint64, value []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("invalid protobuf field key")
+		}
+		data = data[n:]
+
+		var varint uint64
+		var value []byte
+		switch key & 7 {
+		case 0:
+			if varint, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("invalid protobuf varint")
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return fmt.Errorf("truncated protobuf message")
+			}
+			data = data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("truncated protobuf message")
+			}
+			value, data = data[n:n+int(length)], data[n+int(length):]
+		case 5:
+			if len(data) < 4 {
+				return fmt.Errorf("truncated protobuf message")
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+
+		if err := fn(int(key>>3), varint, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// semanticTopLevel returns the top-level class or object of a global SemanticDB
+// symbol, e.g. `com.foo.Bar` for `com/foo/Bar#baz().`. Local symbols and packages
+// have none.
+func semanticTopLevel(symbol string) (string, bool) {
+	if symbol == "" || strings.HasPrefix(symbol, "local") {
+		return "", false
+	}
+
+	var packages []string
+	for rest := symbol; rest != ""; {
+		var name string
+		if strings.HasPrefix(rest, "`") {
+			end := strings.Index(rest[1:], "`")
+			if end < 0 {
+				return "", false
+			}
+			name, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, "/#.([")
+			if end < 0 {
+				return "", false
+			}
+			name, rest = rest[:end], rest[end:]
+		}
+		if rest == "" {
+			return "", false
+		}
+
+		switch rest[0] {
+		case '/':
+			if name != "_root_" && name != "_empty_" {
+				packages = append(packages, name)
+			}
+			rest = rest[1:]
+		case '#', '.':
+			return qualify(strings.Join(packages, "."), name), true
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
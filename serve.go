@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+var (
+	servedParses    = expvar.NewInt("parses")
+	servedErrors    = expvar.NewInt("parse_errors")
+	servedCacheHits = expvar.NewInt("cache_hits")
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// servedParse is the response to a parse request: the ParseResult, whose
+// SyntaxErrors locate any syntax errors, and every error found parsing the file.
+type servedParse struct {
+	*ParseResult
+	Errors []string `json:",omitempty"`
+}
+
+// Timeouts of the server, so slow or idle clients cannot hold connections open.
+const (
+	serveReadHeaderTimeout = 10 * time.Second
+	serveReadTimeout       = time.Minute
+	serveWriteTimeout      = 2 * time.Minute
+)
+
+// parseHandler parses the request body as the file named by the `file` query
+// parameter, responding with the ParseResult and its errors as JSON. Results are
+// looked up in, and stored in, the result cache selected by f, if any.
+//
+// The name is a label chosen by the client, so it must be a relative path that
+// stays within the working directory: it is only ever resolved against it, e.g.
+// to find the file's SemanticDB, and bodies are limited to --max-file-size.
+func parseHandler(f *parseFlags, parser Parser) http.HandlerFunc {
+	cache := f.openResultCache()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "expected POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filePath := r.URL.Query().Get("file")
+		if filePath == "" {
+			http.Error(w, "missing file parameter", http.StatusBadRequest)
+			return
+		} else if !filepath.IsLocal(filePath) {
+			http.Error(w, "file parameter must be a relative path within the working directory", http.StatusBadRequest)
+			return
+		}
+
+		body := r.Body
+		if f.maxFileSize > 0 {
+			body = http.MaxBytesReader(w, r.Body, int64(f.maxFileSize))
+		}
+		sourceCode, err := io.ReadAll(body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("larger than --max-file-size (%d bytes)", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sourceCode = prepareSource(sourceCode, f.encoding)
+		if reason := skipReason(int64(len(sourceCode)), sourceCode, int64(f.maxFileSize)); reason != "" {
+			http.Error(w, reason, http.StatusBadRequest)
+			return
+		}
+
+		var key string
+		if cache != nil {
+			key = f.cacheKey(filePath, sourceCode)
+			if parsed, ok := lookupParse(cache, key, parser); ok {
+				servedCacheHits.Add(1)
+				writeParse(w, parsed)
+				return
+			}
+		}
+
+		result, errs := parser.parsePrepared(filePath, sourceCode)
+		servedParses.Add(1)
+		servedErrors.Add(int64(len(errs)))
+
+		parsed := parsedFile{result: result, errs: errs}
+		if cache != nil {
+			storeParse(cache, key, parsed)
+		}
+		writeParse(w, parsed)
+	}
+}
+
+func writeParse(w http.ResponseWriter, parsed parsedFile) {
+	response := servedParse{ParseResult: parsed.result}
+	for _, err := range parsed.errs {
+		response.Errors = append(response.Errors, err.Error())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// runServeCommand implements `serve [--addr=host:port] [--debug] [flags]`, which
+// parses files with the flags of parse, including its result cache. With
+// --debug, the net/http/pprof profiles and expvar counters are served under
+// /debug/.
+func runServeCommand(args []string) {
+	f := newParseFlags("serve", "[flags]")
+	addr := f.String("addr", "localhost:8080", "address to listen on")
+	debug := f.Bool("debug", false, "expose /debug/pprof and /debug/vars")
+	f.argsOptional = true
+	f.parse(args)
+
+	parser := NewParser(f.options()...)
+	defer parser.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/parse", parseHandler(f, parser))
+	if *debug {
+		// NOTE: pprof and expvar register themselves on the default mux.
+		mux.Handle("/debug/", http.DefaultServeMux)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+		WriteTimeout:      serveWriteTimeout,
+	}
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", *addr)
+	log.Fatal(server.ListenAndServe())
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shard selects the files parsed by one of several machines sharing a parse:
+// the Index-th of Count, counting from 1.
+type shard struct {
+	Index, Count int
+}
+
+// String returns the shard as `index/count`, or nothing if unset.
+func (s *shard) String() string {
+	if s.Count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+func (s *shard) Set(value string) error {
+	index, count, ok := strings.Cut(value, "/")
+	i, err1 := strconv.Atoi(index)
+	n, err2 := strconv.Atoi(count)
+	if !ok || err1 != nil || err2 != nil || n < 1 || i < 1 || i > n {
+		return fmt.Errorf("expected <index>/<count>, e.g. 1/4")
+	}
+	s.Index, s.Count = i, n
+	return nil
+}
+
+// files returns the files among files in the shard. Files are assigned by a
+// hash of their path, so machines naming the same directories agree without
+// coordinating, whatever order the files are found in.
+func (s *shard) files(files []string) []string {
+	if s.Count <= 1 {
+		return files
+	}
+	var selected []string
+	for _, file := range files {
+		h := fnv.New32a()
+		h.Write([]byte(filepath.ToSlash(filepath.Clean(file))))
+		if int(h.Sum32()%uint32(s.Count)) == s.Index-1 {
+			selected = append(selected, file)
+		}
+	}
+	return selected
+}
+
+// IndexConflict is a symbol the shards of an index disagree about: defined by
+// different files in different shards, or, for API surfaces, described
+// differently by shards that parsed the same file.
+type IndexConflict struct {
+	Symbol string
+	// Definitions describe each disagreeing definition, with its shard.
+	Definitions []string
+}
+
+// readIndexFile reads an index as `index` prints it: a symbol, a tab and the
+// files defining it, separated by commas, per line.
+func readIndexFile(path string) (map[string][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	symbols := make(map[string][]string)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		symbol, files, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected `symbol<TAB>files`", path, lineNumber)
+		}
+		symbols[symbol] = append(symbols[symbol], strings.Split(files, ",")...)
+	}
+	return symbols, scanner.Err()
+}
+
+// MergeIndexFiles merges the index shards at paths, as `index` prints them, into
+// one index, returning it with the symbols defined by different files in
+// different shards. A file parsed by several shards is only counted once.
+func MergeIndexFiles(paths []string) (*Index, []IndexConflict, error) {
+	index := NewIndex()
+	shards := make(map[string]map[string]string)
+	for _, path := range paths {
+		symbols, err := readIndexFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		for symbol, files := range symbols {
+			if shards[symbol] == nil {
+				shards[symbol] = make(map[string]string)
+			}
+			for _, file := range files {
+				if _, ok := shards[symbol][file]; !ok {
+					shards[symbol][file] = path
+				}
+				index.add(symbol, file)
+			}
+		}
+	}
+
+	var conflicts []IndexConflict
+	for _, symbol := range index.Symbols() {
+		var definitions []string
+		fromShards := make(map[string]bool)
+		for _, file := range index.Files(symbol) {
+			definitions = append(definitions, fmt.Sprintf("%s (%s)", file, shards[symbol][file]))
+			fromShards[shards[symbol][file]] = true
+		}
+		if len(fromShards) > 1 {
+			conflicts = append(conflicts, IndexConflict{Symbol: symbol, Definitions: definitions})
+		}
+	}
+	return index, conflicts, nil
+}
+
+// MergeAPISurfaces merges the API surface shards at paths, as `index --json`
+// writes them, into one, returning it with the symbols defined by different
+// files in different shards, or differently by shards parsing the same file.
+func MergeAPISurfaces(paths []string) ([]APISymbol, []IndexConflict, error) {
+	type definition struct {
+		APISymbol
+		shard string
+	}
+	// NOTE: types and terms are kept apart by apiKey, so a class and its
+	// companion object do not conflict.
+	definitions := make(map[string][]definition)
+	var keys []string
+	for _, path := range paths {
+		surface, err := readAPISurface(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, symbol := range surface {
+			key := symbol.apiKey()
+			if _, ok := definitions[key]; !ok {
+				keys = append(keys, key)
+			}
+			definitions[key] = append(definitions[key], definition{symbol, path})
+		}
+	}
+	sort.Strings(keys)
+
+	var merged []APISymbol
+	var conflicts []IndexConflict
+	for _, key := range keys {
+		var kept []definition
+		conflicting := false
+		for _, def := range definitions[key] {
+			duplicate := false
+			for _, other := range kept {
+				if other.File != def.File {
+					conflicting = conflicting || other.shard != def.shard
+					continue
+				}
+				duplicate = true
+				conflicting = conflicting || other.Line != def.Line || other.describe() != def.describe()
+			}
+			if !duplicate {
+				kept = append(kept, def)
+			}
+		}
+
+		if conflicting {
+			conflict := IndexConflict{Symbol: definitions[key][0].Symbol}
+			for _, def := range definitions[key] {
+				conflict.Definitions = append(conflict.Definitions, fmt.Sprintf("%s:%d: %s (%s)", def.File, def.Line, def.describe(), def.shard))
+			}
+			conflicts = append(conflicts, conflict)
+		}
+		for _, def := range kept {
+			merged = append(merged, def.APISymbol)
+		}
+	}
+	return merged, conflicts, nil
+}
+
+// writeIndex writes index as `index` prints it.
+func writeIndex(w io.Writer, index *Index) error {
+	out := bufio.NewWriter(w)
+	for _, symbol := range index.Symbols() {
+		fmt.Fprintf(out, "%s\t%s\n", symbol, strings.Join(index.Files(symbol), ","))
+	}
+	return out.Flush()
+}
+
+// runIndexMergeCommand implements `index merge`, which merges the index shards
+// written by `index --shard`, all plain or all --json, into one printed like
+// them. Conflicting symbols are reported on stderr and fail it with
+// exitParseFailure, unless --allow-conflicts is given.
+func runIndexMergeCommand(args []string) {
+	flags := flag.NewFlagSet("index merge", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: scala-tree-parser index merge [flags] <shard>...")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	allowConflicts := flags.Bool("allow-conflicts", false, "report conflicting symbols without failing")
+	parseCommandFlags(flags, args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	paths := flags.Args()
+
+	asJSON := strings.HasSuffix(paths[0], ".json")
+	for _, path := range paths[1:] {
+		if strings.HasSuffix(path, ".json") != asJSON {
+			fmt.Fprintln(os.Stderr, "shards must all be plain indexes or all .json API surfaces")
+			os.Exit(exitUsage)
+		}
+	}
+
+	var conflicts []IndexConflict
+	var err error
+	if asJSON {
+		var surface []APISymbol
+		surface, conflicts, err = MergeAPISurfaces(paths)
+		if err == nil {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(APISurfaceFile{SchemaVersion: SchemaVersion, Symbols: surface})
+		}
+	} else {
+		var index *Index
+		index, conflicts, err = MergeIndexFiles(paths)
+		if err == nil {
+			err = writeIndex(os.Stdout, index)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitInternal)
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Fprintf(os.Stderr, "conflict: %s is defined by %s\n", conflict.Symbol, strings.Join(conflict.Definitions, ", "))
+		if !*allowConflicts {
+			setExitCode(exitParseFailure)
+		}
+	}
+}
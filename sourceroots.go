@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+)
+
+// SourceRole is the part a file plays in its project, inferred from its source root.
+type SourceRole string
+
+const (
+	MainSource        SourceRole = "main"
+	TestSource        SourceRole = "test"
+	IntegrationSource SourceRole = "integration"
+)
+
+// sourceRoot matches an sbt or Maven source root such as `src/main/scala`,
+// `src/test/scala-2.13` or `src/it/java`.
+var sourceRoot = regexp.MustCompile(`(^|/)src/(main|test|it)/(scala|scala-[^/]+|java)/`)
+
+var sourceSetRoles = map[string]SourceRole{
+	"main": MainSource,
+	"test": TestSource,
+	"it":   IntegrationSource,
+}
+
+// readSourceRoot returns the source root containing filePath, e.g.
+// `core/src/test/scala`, and the role of the files beneath it, or empty values if
+// the file is not under a conventional source root.
+func readSourceRoot(filePath string) (string, SourceRole) {
+	slashed := filepath.ToSlash(filePath)
+
+	// NOTE: use the last match, so nested projects take precedence.
+	matches := sourceRoot.FindAllStringSubmatchIndex(slashed, -1)
+	if len(matches) == 0 {
+		return "", ""
+	}
+	match := matches[len(matches)-1]
+
+	root := slashed[:match[1]-1]
+	return root, sourceSetRoles[slashed[match[4]:match[5]]]
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sort"
+)
+
+// SplitAdvice suggests how the files of a package could be split into smaller
+// targets with no dependencies between them.
+type SplitAdvice struct {
+	Package string
+	// Groups are the connected components of the files' references to each other,
+	// each sorted, largest first.
+	Groups [][]string
+}
+
+// AdviseSplit groups the files of each package by the connected components of
+// their references to one another, both through imports and through
+// SamePackageRefs, so results should come from a parser created WithIndex(index).
+// Only packages that split into more than one group are returned.
+func AdviseSplit(results []*ParseResult, index *Index) []SplitAdvice {
+	byPackage := make(map[string][]*ParseResult)
+	for _, result := range results {
+		byPackage[result.Package] = append(byPackage[result.Package], result)
+	}
+
+	var advice []SplitAdvice
+	for pkg, files := range byPackage {
+		if len(files) < 2 {
+			continue
+		}
+
+		// NOTE: a union-find over file paths.
+		parents := make(map[string]string)
+		for _, result := range files {
+			parents[result.File] = result.File
+		}
+		var find func(file string) string
+		find = func(file string) string {
+			if parents[file] != file {
+				parents[file] = find(parents[file])
+			}
+			return parents[file]
+		}
+		union := func(a, b string) {
+			if _, ok := parents[b]; ok {
+				parents[find(a)] = find(b)
+			}
+		}
+
+		for _, result := range files {
+			for _, ref := range result.SamePackageRefs {
+				for _, file := range index.Files(ref) {
+					union(result.File, file)
+				}
+			}
+			for _, imp := range result.Imports {
+				if !isPackageImport(imp, pkg) {
+					continue
+				}
+				for _, file := range importedFiles(index, imp) {
+					union(result.File, file)
+				}
+			}
+		}
+
+		components := make(map[string][]string)
+		for file := range parents {
+			root := find(file)
+			components[root] = append(components[root], file)
+		}
+		if len(components) < 2 {
+			continue
+		}
+
+		groups := make([][]string, 0, len(components))
+		for _, group := range components {
+			sort.Strings(group)
+			groups = append(groups, group)
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			if len(groups[i]) != len(groups[j]) {
+				return len(groups[i]) > len(groups[j])
+			}
+			return groups[i][0] < groups[j][0]
+		})
+
+		advice = append(advice, SplitAdvice{Package: pkg, Groups: groups})
+	}
+
+	sort.Slice(advice, func(i, j int) bool {
+		return advice[i].Package < advice[j].Package
+	})
+	return advice
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStats records how long a single file took to parse.
+type FileStats struct {
+	File     string
+	Bytes    int
+	Duration time.Duration
+}
+
+// Stats collects parse timings, and result and tree cache lookups, across every
+// file parsed by the parsers it is given to. It is safe for concurrent use.
+type Stats struct {
+	mu           sync.Mutex
+	files        []FileStats
+	treeHits     int
+	treeMisses   int
+	resultHits   int
+	resultMisses int
+}
+
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+// WithStats records the parse time of every file, and the hit rate of Parser.Tree,
+// in stats. The CLI records the hit rate of its result cache there too.
+func WithStats(stats *Stats) Option {
+	return func(p *treeSitterParser) {
+		p.stats = stats
+	}
+}
+
+func (s *Stats) record(filePath string, size int, start time.Time) {
+	elapsed := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = append(s.files, FileStats{File: filePath, Bytes: size, Duration: elapsed})
+}
+
+func (s *Stats) recordTreeLookup(hit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hit {
+		s.treeHits++
+	} else {
+		s.treeMisses++
+	}
+}
+
+// recordResultLookup records a lookup in the result cache of --cache-dir or
+// --remote-cache, whose hits are never parsed.
+func (s *Stats) recordResultLookup(hit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hit {
+		s.resultHits++
+	} else {
+		s.resultMisses++
+	}
+}
+
+// Files returns the stats of every file parsed so far, in the order they finished.
+func (s *Stats) Files() []FileStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FileStats(nil), s.files...)
+}
+
+// Report writes per-file and aggregate statistics to w, followed by the slowest
+// files, at most slowest of them.
+func (s *Stats) Report(w io.Writer, slowest int) {
+	files := s.Files()
+
+	s.mu.Lock()
+	treeHits, treeMisses := s.treeHits, s.treeMisses
+	resultHits, resultMisses := s.resultHits, s.resultMisses
+	s.mu.Unlock()
+
+	var totalBytes int
+	var totalTime time.Duration
+	for _, file := range files {
+		fmt.Fprintf(w, "%s: %v, %d bytes, %s\n", file.File, file.Duration, file.Bytes, throughput(file.Bytes, file.Duration))
+		totalBytes += file.Bytes
+		totalTime += file.Duration
+	}
+
+	fmt.Fprintf(w, "total: %d files, %d bytes in %v, %s\n", len(files), totalBytes, totalTime, throughput(totalBytes, totalTime))
+	reportCache(w, "result cache", resultHits, resultMisses)
+	reportCache(w, "tree cache", treeHits, treeMisses)
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Duration > files[j].Duration
+	})
+	if len(files) > slowest {
+		files = files[:slowest]
+	}
+	if len(files) > 0 {
+		fmt.Fprintln(w, "slowest:")
+		for _, file := range files {
+			fmt.Fprintf(w, "  %v %s\n", file.Duration, file.File)
+		}
+	}
+}
+
+// reportCache writes the hit rate of a cache, if it was used.
+func reportCache(w io.Writer, name string, hits, misses int) {
+	if hits+misses > 0 {
+		fmt.Fprintf(w, "%s: %d hits, %d misses (%.1f%% hit rate)\n", name, hits, misses, 100*float64(hits)/float64(hits+misses))
+	}
+}
+
+func throughput(size int, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f MB/s", float64(size)/elapsed.Seconds()/(1<<20))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// scalaIdentifier matches a Scala identifier: a backquoted name, a plain name
+// optionally ending in operator characters after an underscore, e.g. `unary_!`,
+// or a run of operator characters, e.g. `:+:`.
+const scalaIdentifier = "`[^`\n]+`|[\\pL_$][\\pL\\pN_$]*_[!#%&*+\\-/:<=>?@\\\\^|~\\pS]+|[\\pL_$][\\pL\\pN_$]*|[!#%&*+\\-/:<=>?@\\\\^|~\\pS]+"
+
+// symbolicDefinition matches the keyword and name of a definition, e.g.
+// `def unary_!` or `class :+:`.
+var symbolicDefinition = regexp.MustCompile(`\b(?:def|val|var|type|class|trait|object)\s+(` + scalaIdentifier + `)`)
+
+// leadingSymbolicDefinition matches the keyword and name a definition starts
+// with, e.g. `val ~>`.
+var leadingSymbolicDefinition = regexp.MustCompile(`^(def|val|var|type|class|trait|object)\s+(` + scalaIdentifier + `)`)
+
+// trailingOperatorImport matches the rest of an import of an operator that the
+// grammar leaves out of the declaration, e.g. `.::` in `import immutable.::`.
+var trailingOperatorImport = regexp.MustCompile(`^\.([!#%&*+\-/:<=>?@\\^|~\pS]+)\s*$`)
+
+// hasErrorChild reports whether any child of node failed to parse.
+func hasErrorChild(node *sitter.Node) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if node.Child(i).IsError() || node.Child(i).IsMissing() {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverSymbolicName returns the name declared by a definition node whose name
+// the grammar could not read, which it does for some operator names, e.g.
+// `val ~>` or `def unary_!`. It returns false for other nodes.
+func recoverSymbolicName(node *sitter.Node, sourceCode []byte) (string, bool) {
+	if !hasErrorChild(node) {
+		return "", false
+	}
+	match := symbolicDefinition.FindSubmatch(nodeBytes(node, sourceCode))
+	if match == nil || !hasOperatorCharacters(string(match[1])) {
+		return "", false
+	}
+	return string(match[1]), true
+}
+
+// recoverSymbolicDefinition returns the definition an error node starts with,
+// for definitions of operator names the grammar lost entirely, e.g. `val ~> = 1`.
+// It returns false for other nodes.
+func recoverSymbolicDefinition(node *sitter.Node, sourceCode []byte, namespace string) (Symbol, bool) {
+	if !node.IsError() {
+		return Symbol{}, false
+	}
+	match := leadingSymbolicDefinition.FindSubmatch(nodeBytes(node, sourceCode))
+	if match == nil || !hasOperatorCharacters(string(match[2])) {
+		return Symbol{}, false
+	}
+	return Symbol{
+		Name: namespace + string(match[2]),
+		Kind: string(match[1]),
+		Line: int(node.StartPoint().Row) + 1,
+	}, true
+}
+
+// readTrailingOperatorImport returns the operator following an import
+// declaration node that the grammar split off into an error, e.g. `::` for
+// `import scala.collection.immutable.::`.
+func readTrailingOperatorImport(node *sitter.Node, sourceCode []byte) (string, bool) {
+	next := node.NextSibling()
+	if next == nil || !next.IsError() || next.StartByte() != node.EndByte() {
+		return "", false
+	}
+	match := trailingOperatorImport.FindSubmatch(nodeBytes(next, sourceCode))
+	if match == nil {
+		return "", false
+	}
+	return string(match[1]), true
+}
+
+// readImportSelectorsText returns the names imported by import selectors as
+// written, e.g. `{<*> => ap, *>}`, for selectors the grammar could not read.
+func readImportSelectorsText(text string) []string {
+	text = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(text), "{"), "}")
+
+	names := make([]string, 0)
+	for _, selector := range splitTopLevel(text, ',') {
+		name := selector
+		if i := strings.Index(selector, "=>"); i >= 0 && strings.Count(selector[:i], "`")%2 == 0 {
+			name = selector[:i]
+		}
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// readImportAliasesText is readImportAliases for the text of import selectors
+// the grammar fails on.
+func readImportAliasesText(text string) map[string]string {
+	text = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(text), "{"), "}")
+
+	aliases := make(map[string]string)
+	for _, selector := range splitTopLevel(text, ',') {
+		if i := strings.Index(selector, "=>"); i >= 0 && strings.Count(selector[:i], "`")%2 == 0 {
+			name, alias := strings.TrimSpace(selector[:i]), strings.TrimSpace(selector[i+2:])
+			if name != "" && alias != "" {
+				aliases[name] = alias
+			}
+		}
+	}
+	return aliases
+}
+
+// hasOperatorCharacters reports whether a name has any operator characters,
+// e.g. `++` or `unary_!`, ignoring backquoted names.
+func hasOperatorCharacters(name string) bool {
+	if strings.HasPrefix(name, "`") {
+		return false
+	}
+	return strings.IndexFunc(name, isOperatorCharacter) >= 0
+}
+
+func isOperatorCharacter(r rune) bool {
+	return strings.ContainsRune("!#%&*+-/:<=>?@\\^|~", r) || unicode.IsSymbol(r)
+}
+
+func WithEncodedOperators() Option {
+	return func(p *treeSitterParser) {
+		p.encodeOperators = true
+	}
+}
+
+// encodeOperators percent-encodes the characters of each segment of a dotted
+// name that are not letters, digits, `_` or `$`, e.g. `Ops.++` becomes
+// `Ops.%2B%2B`, for outputs that cannot represent operator names. Wildcard
+// segments are kept.
+func encodeOperators(name string) string {
+	if strings.IndexFunc(name, needsEncoding) < 0 {
+		return name
+	}
+
+	segments := splitQualifiedName(name)
+	for i, segment := range segments {
+		if segment == "*" || segment == "_" {
+			continue
+		}
+		var b strings.Builder
+		for _, r := range segment {
+			if !needsEncoding(r) {
+				b.WriteRune(r)
+				continue
+			}
+			for _, c := range []byte(string(r)) {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, ".")
+}
+
+func needsEncoding(r rune) bool {
+	return r != '.' && r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
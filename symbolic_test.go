@@ -0,0 +1,148 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSymbolicDefinitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "concatenation",
+			source: "package ops\nobject Ops {\n  def ++(other: Ops.type): Ops.type = other\n}\n",
+			want:   []string{"Ops", "Ops.++"},
+		},
+		{
+			name:   "right-associative cons",
+			source: "package ops\nobject Ops {\n  def ::(x: Int): Ops.type = this\n}\n",
+			want:   []string{"Ops", "Ops.::"},
+		},
+		{
+			name:   "applicative apply",
+			source: "package ops\nobject Ops {\n  def <*>(f: Int): Int = f\n}\n",
+			want:   []string{"Ops", "Ops.<*>"},
+		},
+		{
+			name:   "symbolic val",
+			source: "package ops\nobject Ops {\n  val ~> = 1\n}\n",
+			want:   []string{"Ops", "Ops.~>"},
+		},
+		{
+			name:   "unary operator",
+			source: "package ops\nobject Ops {\n  def unary_!(): Boolean = false\n}\n",
+			want:   []string{"Ops", "Ops.unary_!"},
+		},
+		{
+			name:   "backquoted name",
+			source: "package ops\nobject Ops {\n  def `++`(other: Ops.type): Ops.type = other\n}\n",
+			want:   []string{"Ops", "Ops.++"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := NewParser().ParseBytes("Ops.scala", []byte(tt.source))
+			if !reflect.DeepEqual(result.Symbols, tt.want) {
+				t.Errorf("Symbols = %q, want %q", result.Symbols, tt.want)
+			}
+		})
+	}
+}
+
+func TestSymbolicImportSelectors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "renamed and plain selectors",
+			source: "package p\nimport cats.syntax.apply.{<*> => ap, *>}\n",
+			want:   []string{"cats.syntax.apply.<*>", "cats.syntax.apply.*>"},
+		},
+		{
+			name:   "trailing operator",
+			source: "package p\nimport scala.collection.immutable.::\n",
+			want:   []string{"scala.collection.immutable.::"},
+		},
+		{
+			name:   "plain and backquoted selectors",
+			source: "package p\nimport ops.Ops.{++, `::`}\n",
+			want:   []string{"ops.Ops.++", "ops.Ops.::"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := NewParser().ParseBytes("P.scala", []byte(tt.source))
+			if !reflect.DeepEqual(result.Imports, tt.want) {
+				t.Errorf("Imports = %q, want %q", result.Imports, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeOperators(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ops.Ops", "ops.Ops"},
+		{"ops.Ops.++", "ops.Ops.%2B%2B"},
+		{"scala.collection.immutable.::", "scala.collection.immutable.%3A%3A"},
+		{"cats.syntax.apply.<*>", "cats.syntax.apply.%3C%2A%3E"},
+		{"ops.Ops.unary_!", "ops.Ops.unary_%21"},
+		{"scala.collection._", "scala.collection._"},
+		{"scala.collection.*", "scala.collection.*"},
+		{"café.Menu", "café.Menu"},
+	}
+
+	for _, tt := range tests {
+		if got := encodeOperators(tt.name); got != tt.want {
+			t.Errorf("encodeOperators(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseWithEncodedOperators(t *testing.T) {
+	source := "package ops\nimport cats.syntax.apply.{<*> => ap, *>}\nobject Ops {\n  def ++(other: Ops.type): Ops.type = other\n}\n"
+
+	result, _ := NewParser(WithEncodedOperators()).ParseBytes("Ops.scala", []byte(source))
+	if want := []string{"cats.syntax.apply.%3C%2A%3E", "cats.syntax.apply.%2A%3E"}; !reflect.DeepEqual(result.Imports, want) {
+		t.Errorf("Imports = %q, want %q", result.Imports, want)
+	}
+	if want := []string{"Ops", "Ops.%2B%2B"}; !reflect.DeepEqual(result.Symbols, want) {
+		t.Errorf("Symbols = %q, want %q", result.Symbols, want)
+	}
+}
+
+func TestEncodeOperatorsFlag(t *testing.T) {
+	source := "package ops\nimport scala.collection.immutable.::\nobject Ops {\n  def <*>(f: Int): Int = f\n}\n"
+
+	tests := []struct {
+		args        []string
+		wantImports []string
+		wantSymbols []string
+	}{
+		{nil, []string{"scala.collection.immutable.::"}, []string{"Ops", "Ops.<*>"}},
+		{[]string{"--encode-operators"}, []string{"scala.collection.immutable.%3A%3A"}, []string{"Ops", "Ops.%3C%2A%3E"}},
+	}
+
+	for _, tt := range tests {
+		f := newParseFlags("symbols", "[flags] <file>...")
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		result, _ := NewParser(f.options()...).ParseBytes("Ops.scala", []byte(source))
+		if !reflect.DeepEqual(result.Imports, tt.wantImports) {
+			t.Errorf("%q: Imports = %q, want %q", tt.args, result.Imports, tt.wantImports)
+		}
+		if !reflect.DeepEqual(result.Symbols, tt.wantSymbols) {
+			t.Errorf("%q: Symbols = %q, want %q", tt.args, result.Symbols, tt.wantSymbols)
+		}
+	}
+}
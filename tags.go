@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ctagsKinds are the single-letter kinds universal-ctags uses for Scala.
+var ctagsKinds = map[string]byte{
+	"class":       'c',
+	"object":      'o',
+	"case object": 'o',
+	"trait":       't',
+	"def":         'm',
+	"val":         'V',
+	"var":         'v',
+	"type":        'T',
+	"given":       'V',
+}
+
+// tag is a definition as written to a tags file.
+type tag struct {
+	name string
+	file string
+	line int
+	kind string
+	// scope is the fully-qualified definition or package enclosing the tag, and
+	// scopeKind its kind.
+	scope     string
+	scopeKind string
+	// text is the source line of the definition, and offset the byte offset of its
+	// start. Both are unset if the file could not be read back.
+	text   string
+	offset int
+}
+
+// readTags returns the tags of the definitions in result, reading the file back
+// for the lines they're on.
+func readTags(result *ParseResult) []tag {
+	source, _ := os.ReadFile(result.File)
+	lineOffsets := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineOffsets = append(lineOffsets, i+1)
+		}
+	}
+
+	kinds := make(map[string]string)
+	for _, definition := range result.Definitions {
+		// NOTE: scope kinds are a single word, so a case object scopes as an object.
+		kinds[definition.Name] = strings.TrimPrefix(definition.Kind, "case ")
+	}
+
+	tags := make([]tag, 0, len(result.Definitions))
+	for _, definition := range result.Definitions {
+		t := tag{name: definition.Name, file: result.File, line: definition.Line, kind: definition.Kind}
+		if i := strings.LastIndex(definition.Name, "."); i >= 0 {
+			t.name = definition.Name[i+1:]
+			t.scope, t.scopeKind = qualify(result.Package, definition.Name[:i]), kinds[definition.Name[:i]]
+		} else if result.Package != "" {
+			t.scope, t.scopeKind = result.Package, "package"
+		}
+
+		if definition.Line <= len(lineOffsets) && len(source) > 0 {
+			t.offset = lineOffsets[definition.Line-1]
+			text, _, _ := bytes.Cut(source[t.offset:], []byte("\n"))
+			t.text = strings.TrimSuffix(string(text), "\r")
+		}
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// writeCtags writes tags in the universal-ctags format, sorted by name as the
+// header declares.
+func writeCtags(w io.Writer, tags []tag) {
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].name < tags[j].name })
+
+	fmt.Fprintln(w, "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/")
+	fmt.Fprintln(w, "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/")
+	fmt.Fprintln(w, "!_TAG_PROGRAM_NAME\tscala-tree-parser\t//")
+	fmt.Fprintf(w, "!_TAG_PROGRAM_VERSION\t%s\t//\n", version)
+
+	for _, t := range tags {
+		address := fmt.Sprint(t.line)
+		if t.text != "" {
+			escaped := strings.NewReplacer(`\`, `\\`, `/`, `\/`).Replace(t.text)
+			address = "/^" + escaped + "$/"
+		}
+
+		kind, ok := ctagsKinds[t.kind]
+		if !ok {
+			kind = 'm'
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s;\"\t%c\tline:%d", t.name, t.file, address, kind, t.line)
+		if t.scope != "" && t.scopeKind != "" {
+			fmt.Fprintf(w, "\t%s:%s", t.scopeKind, t.scope)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeEtags writes tags in the Emacs etags format, one section per file.
+func writeEtags(w io.Writer, tags []tag) {
+	var files []string
+	byFile := make(map[string][]tag)
+	for _, t := range tags {
+		if _, ok := byFile[t.file]; !ok {
+			files = append(files, t.file)
+		}
+		byFile[t.file] = append(byFile[t.file], t)
+	}
+
+	for _, file := range files {
+		var section strings.Builder
+		for _, t := range byFile[file] {
+			fmt.Fprintf(&section, "%s\x7f%s\x01%d,%d\n", t.text, t.name, t.line, t.offset)
+		}
+		fmt.Fprintf(w, "\f\n%s,%d\n%s", file, section.Len(), section.String())
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tastyMagic starts every .tasty file.
+var tastyMagic = []byte{0x5C, 0xA1, 0xAB, 0x1F}
+
+// tastyUTF8 is the tag of a simple name in the name table; every other kind of
+// name is built from references to these.
+const tastyUTF8 = 1
+
+// TastyFile is the header and simple names of a .tasty file, the typed trees the
+// Scala 3 compiler stores beside each top-level class.
+type TastyFile struct {
+	MajorVersion        int
+	MinorVersion        int
+	ExperimentalVersion int
+	// Tooling is the version of the compiler that wrote the file.
+	Tooling string
+	UUID    [16]byte
+	// Names are the simple names in the file's name table, defined or referenced.
+	Names []string
+}
+
+// tastyReader reads the primitives of the TASTy format.
+type tastyReader struct {
+	data []byte
+	err  error
+}
+
+// nat reads a natural number: big-endian groups of 7 bits, with the high bit set
+// only on the last byte.
+func (r *tastyReader) nat() int {
+	n := 0
+	for r.err == nil {
+		if len(r.data) == 0 {
+			r.err = io.ErrUnexpectedEOF
+			break
+		}
+		b := r.data[0]
+		r.data = r.data[1:]
+		n = n<<7 | int(b&0x7f)
+		if b&0x80 != 0 {
+			break
+		}
+	}
+	return n
+}
+
+func (r *tastyReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// ReadTasty reads the header and name table of a .tasty file.
+func ReadTasty(data []byte) (*TastyFile, error) {
+	if !bytes.HasPrefix(data, tastyMagic) {
+		return nil, fmt.Errorf("not a TASTy file")
+	}
+	r := &tastyReader{data: data[len(tastyMagic):]}
+
+	file := &TastyFile{MajorVersion: r.nat()}
+	if r.err == nil && file.MajorVersion < 28 {
+		return nil, fmt.Errorf("unsupported TASTy version %d, from before Scala 3.0", file.MajorVersion)
+	}
+	file.MinorVersion = r.nat()
+	file.ExperimentalVersion = r.nat()
+	file.Tooling = string(r.bytes(r.nat()))
+	copy(file.UUID[:], r.bytes(16))
+
+	names := &tastyReader{data: r.bytes(r.nat())}
+	for r.err == nil && names.err == nil && len(names.data) > 0 {
+		tag := names.bytes(1)
+		name := names.bytes(names.nat())
+		if names.err == nil && tag[0] == tastyUTF8 {
+			file.Names = append(file.Names, string(name))
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid TASTy header: %w", r.err)
+	} else if names.err != nil {
+		return nil, fmt.Errorf("invalid TASTy name table: %w", names.err)
+	}
+	return file, nil
+}
+
+// tastySymbol returns the top-level symbol compiled to the .tasty file at name,
+// a path relative to the root of its jar or class directory. Scala 3 compiles
+// the top-level definitions outside classes of `Foo.scala` to `Foo$package`, so
+// for those the package itself is returned.
+func tastySymbol(name string) string {
+	symbol := strings.ReplaceAll(strings.TrimSuffix(filepath.ToSlash(name), ".tasty"), "/", ".")
+	if strings.HasSuffix(symbol, "$package") {
+		if i := strings.LastIndex(symbol, "."); i >= 0 {
+			return symbol[:i]
+		}
+		return ""
+	}
+	return symbol
+}
+
+// TastySymbols returns the top-level symbols of the .tasty files in the jar or
+// class directory at path, after checking each is valid TASTy. Only the header
+// and names of each file are read, so private top-level classes are included.
+func TastySymbols(path string) ([]string, error) {
+	var symbols []string
+	add := func(name string, data []byte) error {
+		if _, err := ReadTasty(data); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+		if symbol := tastySymbol(name); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		err = filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !strings.HasSuffix(filePath, ".tasty") {
+				return err
+			}
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(path, filePath)
+			if err != nil {
+				return err
+			}
+			return add(rel, data)
+		})
+		return sortedUnique(symbols), err
+	}
+
+	jar, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer jar.Close()
+
+	for _, entry := range jar.File {
+		if !strings.HasSuffix(entry.Name, ".tasty") {
+			continue
+		}
+		data, err := readZipEntry(entry)
+		if err == nil {
+			err = add(entry.Name, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sortedUnique(symbols), nil
+}
+
+func readZipEntry(entry *zip.File) ([]byte, error) {
+	r, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// runTastyCommand implements `tasty <jar or class directory>...`, printing the
+// top-level symbols compiled to the .tasty files of each.
+func runTastyCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: tasty <jar or class directory>...")
+		os.Exit(exitUsage)
+	}
+
+	for _, path := range args {
+		symbols, err := TastySymbols(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			setExitCode(exitInternal)
+			continue
+		}
+		for _, symbol := range symbols {
+			if len(args) > 1 {
+				fmt.Printf("%s: %s\n", path, symbol)
+			} else {
+				fmt.Println(symbol)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// treeCache retains parsed trees by file path so callers can run further
+// analyses without re-parsing. Once the combined size of the retained sources
+// exceeds maxBytes, the least recently used trees are closed and evicted.
+type treeCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type cachedTree struct {
+	filePath string
+	tree     *sitter.Tree
+	source   []byte
+}
+
+func newTreeCache(maxBytes int) *treeCache {
+	return &treeCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *treeCache) put(filePath string, tree *sitter.Tree, source []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[filePath]; ok {
+		c.remove(elem)
+	}
+
+	c.entries[filePath] = c.order.PushFront(&cachedTree{
+		filePath: filePath,
+		tree:     tree,
+		source:   source,
+	})
+	c.size += len(source)
+
+	// NOTE: always keep the most recent tree, even if it alone is over the ceiling.
+	for c.size > c.maxBytes && c.order.Len() > 1 {
+		c.remove(c.order.Back())
+	}
+}
+
+// get returns a copy of the tree retained for filePath, and its source. The copy
+// is the caller's to close: evicting the retained tree, which another parse may
+// do at any time, never frees it.
+func (c *treeCache) get(filePath string) (*sitter.Tree, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[filePath]
+	if !ok {
+		return nil, nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*cachedTree)
+	// NOTE: copy while holding the lock, so the tree cannot be evicted first.
+	// Copies share the tree's nodes, so this is cheap.
+	return entry.tree.Copy(), entry.source, true
+}
+
+func (c *treeCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *treeCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*cachedTree)
+	delete(c.entries, entry.filePath)
+	c.size -= len(entry.source)
+	entry.tree.Close()
+}
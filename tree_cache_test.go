@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestTreeOutlivesEviction(t *testing.T) {
+	parser := NewParser(WithTreeRetention(1))
+	defer parser.Close()
+
+	parser.ParseBytes("A.scala", []byte("package a\nobject A\n"))
+	tree, source, ok := parser.Tree("A.scala")
+	if !ok {
+		t.Fatal("tree of A.scala not retained")
+	}
+	defer tree.Close()
+
+	// Retaining B.scala's tree evicts, and closes, the retained tree of A.scala.
+	parser.ParseBytes("B.scala", []byte("package b\nobject B\n"))
+	if _, _, ok := parser.Tree("A.scala"); ok {
+		t.Fatal("tree of A.scala not evicted")
+	}
+
+	if got := tree.RootNode().NamedChild(1).Content(source); got != "object A" {
+		t.Errorf("second node of the evicted tree = %q, want %q", got, "object A")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// twirlImport matches a Twirl `@import` statement, which must be on its own line.
+var twirlImport = regexp.MustCompile(`^\s*@import\s+(.+?)\s*$`)
+
+// isTwirlTemplate reports whether filePath is a Play Twirl template, e.g.
+// `views/index.scala.html`.
+func isTwirlTemplate(filePath string) bool {
+	for _, format := range []string{".scala.html", ".scala.txt", ".scala.xml", ".scala.js"} {
+		if strings.HasSuffix(filePath, format) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// twirlImports rewrites a Twirl template into Scala source holding only its
+// `@import` statements, so they can be parsed like any other import. Every other
+// line is blanked, keeping line numbers intact.
+func twirlImports(sourceCode []byte) []byte {
+	lines := bytes.Split(sourceCode, []byte("\n"))
+	var rewritten bytes.Buffer
+	rewritten.Grow(len(sourceCode))
+
+	for i, line := range lines {
+		if i > 0 {
+			rewritten.WriteByte('\n')
+		}
+
+		if match := twirlImport.FindSubmatch(line); match != nil {
+			rewritten.WriteString("import ")
+			rewritten.Write(match[1])
+		}
+	}
+
+	return rewritten.Bytes()
+}
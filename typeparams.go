@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// TypeParam is a type parameter of a class, trait or def.
+type TypeParam struct {
+	Name string
+	// Variance is `+` for covariant and `-` for contravariant parameters.
+	Variance string
+	// Params are the type parameters of a higher-kinded parameter as written,
+	// e.g. `[_]`.
+	Params string
+	// Bounds are the parameter's bounds as written, with whitespace normalized,
+	// e.g. `<: Foo : Ordering`.
+	Bounds string
+}
+
+// String formats the parameter as it would be written, without annotations.
+func (t TypeParam) String() string {
+	text := t.Variance + t.Name + t.Params
+	if t.Bounds != "" {
+		text += " " + t.Bounds
+	}
+	return text
+}
+
+// readTypeParams returns the type parameters of a definition node.
+func readTypeParams(node *sitter.Node, sourceCode []byte) []TypeParam {
+	params := make([]TypeParam, 0)
+
+	typeParams := node.ChildByFieldName("type_parameters")
+	if typeParams == nil {
+		return params
+	}
+
+	// NOTE: the grammar only wraps variant parameters in a node of their own, so
+	// split the parameters as written instead.
+	text := typeParams.Content(sourceCode)
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "["), "]")
+	for _, param := range splitTopLevel(text, ',') {
+		if param = normalizeWhitespace(stripAnnotations(param)); param != "" {
+			params = append(params, parseTypeParam(param))
+		}
+	}
+
+	return params
+}
+
+// parseTypeParam parses a type parameter without annotations, e.g.
+// `+F[_] <: Foo`.
+func parseTypeParam(text string) TypeParam {
+	var param TypeParam
+	if strings.HasPrefix(text, "+") || strings.HasPrefix(text, "-") {
+		param.Variance, text = text[:1], strings.TrimSpace(text[1:])
+	}
+
+	end := strings.IndexAny(text, " [:<>=")
+	if end < 0 {
+		end = len(text)
+	}
+	param.Name, text = text[:end], strings.TrimSpace(text[end:])
+
+	if strings.HasPrefix(text, "[") {
+		depth := 0
+		for i, r := range text {
+			if r == '[' {
+				depth++
+			} else if r == ']' {
+				if depth--; depth == 0 {
+					param.Params, text = text[:i+1], strings.TrimSpace(text[i+1:])
+					break
+				}
+			}
+		}
+	}
+	param.Bounds = text
+	return param
+}
+
+// splitTopLevel splits text at each sep outside brackets and parentheses.
+func splitTopLevel(text string, sep rune) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range text {
+		switch r {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, text[start:])
+}
+
+// stripAnnotations removes the leading annotations of a parameter, e.g.
+// `@specialized(Int) T`.
+func stripAnnotations(text string) string {
+	text = strings.TrimSpace(text)
+	for strings.HasPrefix(text, "@") {
+		i := 1
+		for i < len(text) && text[i] != ' ' && text[i] != '(' {
+			i++
+		}
+		if i < len(text) && text[i] == '(' {
+			if args := splitTopLevel(text[i:], ' '); len(args) > 0 {
+				i += len(args[0])
+			}
+		}
+		text = strings.TrimSpace(text[i:])
+	}
+	return text
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// TypeReference is a dependency on a type that doesn't show up as an import or
+// extends clause.
+type TypeReference struct {
+	// Type is the referenced type as written.
+	Type string
+	// Kind is how the type is referenced: `derives` or `self`.
+	Kind string
+	// Symbol is the name of the definition making the reference.
+	Symbol string
+	// Line is the 1-based line of the reference.
+	Line int
+}
+
+// derivesList matches the types listed after the `derives` keyword, e.g.
+// ` Codec, Eq`.
+var derivesList = regexp.MustCompile(`^\s+([A-Za-z_][\w.]*(?:\s*,\s*[A-Za-z_][\w.]*)*)`)
+
+// definitionName matches the name after a keyword starting a definition that may
+// have a derives clause.
+var definitionName = regexp.MustCompile(`^\s+([A-Za-z_]\w*)`)
+
+// readDerivesClauses finds the types listed in `derives` clauses. The grammar
+// doesn't support them, so they are found in one scan of the source text, which
+// skips comments and literals, and attributed to the closest preceding
+// definition.
+func readDerivesClauses(sourceCode []byte) []TypeReference {
+	refs := make([]TypeReference, 0)
+	symbol := ""
+	// line is the line of offset counted, up to which newlines have been counted.
+	line, counted := 1, 0
+
+	for i := 0; i < len(sourceCode); i++ {
+		switch {
+		case bytes.HasPrefix(sourceCode[i:], []byte("//")):
+			i = skipUntil(sourceCode, i, "\n") - 1
+		case bytes.HasPrefix(sourceCode[i:], []byte("/*")):
+			i = skipBlockComment(sourceCode, i) - 1
+		case bytes.HasPrefix(sourceCode[i:], []byte(`"""`)):
+			i = skipUntil(sourceCode, i+3, `"""`) - 1
+		case sourceCode[i] == '"':
+			i = skipStringLiteral(sourceCode, i) - 1
+		case sourceCode[i] == '\'' && i+2 < len(sourceCode) && (sourceCode[i+2] == '\'' || sourceCode[i+1] == '\\'):
+			i = skipStringLiteral(sourceCode, i) - 1
+		case sourceCode[i] == '`':
+			i = skipUntil(sourceCode, i+1, "`") - 1
+		case isIdentifierStart(sourceCode[i]) || sourceCode[i] >= utf8.RuneSelf:
+			end := i + 1
+			for end < len(sourceCode) && (isIdentifierPart(sourceCode[end]) || sourceCode[end] >= utf8.RuneSelf) {
+				end++
+			}
+
+			switch string(sourceCode[i:end]) {
+			case "class", "trait", "object", "enum":
+				if match := definitionName.FindSubmatch(sourceCode[end:]); match != nil {
+					symbol = string(match[1])
+				}
+			case "derives":
+				match := derivesList.FindSubmatch(sourceCode[end:])
+				if match == nil {
+					break
+				}
+				line += bytes.Count(sourceCode[counted:i], []byte("\n"))
+				counted = i
+				for _, derived := range strings.Split(string(match[1]), ",") {
+					refs = append(refs, TypeReference{
+						Type:   strings.TrimSpace(derived),
+						Kind:   "derives",
+						Symbol: symbol,
+						Line:   line,
+					})
+				}
+			}
+			i = end - 1
+		}
+	}
+
+	return refs
+}
+
+// selfTypeAnnotation matches the self-type at the start of a template body, e.g.
+// `{ self: Bar with Baz =>`.
+var selfTypeAnnotation = regexp.MustCompile(`^\{\s*(?:[A-Za-z_]\w*|this)\s*:\s*([^=>{}]+?)\s*=>`)
+
+// readSelfTypes returns the types in a trait or class's self-type annotation. The
+// grammar misparses these as expressions, so they're matched in the source text.
+func readSelfTypes(node *sitter.Node, sourceCode []byte) []string {
+	body := node.ChildByFieldName("body")
+	if body == nil || body.Type() != "template_body" {
+		return nil
+	}
+
+	match := selfTypeAnnotation.FindSubmatch(sourceCode[body.StartByte():body.EndByte()])
+	if match == nil {
+		return nil
+	}
+
+	selfTypes := make([]string, 0)
+	for _, withType := range strings.Split(string(match[1]), " with ") {
+		for _, selfType := range strings.Split(withType, "&") {
+			selfTypes = append(selfTypes, normalizeWhitespace(selfType))
+		}
+	}
+
+	return selfTypes
+}
+
+// selfTypeReferences lists the self-types of every definition as references.
+func selfTypeReferences(symbols []Symbol) []TypeReference {
+	refs := make([]TypeReference, 0)
+
+	for _, symbol := range symbols {
+		for _, selfType := range symbol.SelfTypes {
+			refs = append(refs, TypeReference{
+				Type:   selfType,
+				Kind:   "self",
+				Symbol: symbol.Name,
+				Line:   symbol.Line,
+			})
+		}
+	}
+
+	return refs
+}
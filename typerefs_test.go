@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadDerivesClauses(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []TypeReference
+	}{
+		{
+			name:   "derives clause",
+			source: "case class Point(x: Int) derives Codec, Eq\n",
+			want: []TypeReference{
+				{Type: "Codec", Kind: "derives", Symbol: "Point", Line: 1},
+				{Type: "Eq", Kind: "derives", Symbol: "Point", Line: 1},
+			},
+		},
+		{
+			name:   "closest preceding definition",
+			source: "object Shapes {\n  enum Shape derives io.circe.Codec:\n    case Circle\n}\n",
+			want: []TypeReference{
+				{Type: "io.circe.Codec", Kind: "derives", Symbol: "Shape", Line: 2},
+			},
+		},
+		{
+			name:   "comments and strings",
+			source: "// class A derives X\n/* class B\n derives Y */\nval s = \"class C derives Z\"\nval t = \"\"\"derives W\"\"\"\ncase class D(x: Int) derives Eq\n",
+			want: []TypeReference{
+				{Type: "Eq", Kind: "derives", Symbol: "D", Line: 6},
+			},
+		},
+		{
+			name:   "identifiers containing derives",
+			source: "val underives = 1\nval `derives` = underives\n",
+			want:   []TypeReference{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readDerivesClauses([]byte(tt.source)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readDerivesClauses = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// maskUnicodeIdentifiers returns sourceCode with each non-ASCII letter, digit
+// and mark replaced by as many ASCII letters as it has bytes, for the grammar,
+// which only lexes ASCII identifiers. Nodes of the tree parsed from it have the
+// same offsets in sourceCode, so their names are read from the original.
+// Character literals, e.g. 'é', are kept, as masking would lengthen them.
+func maskUnicodeIdentifiers(sourceCode []byte) []byte {
+	masked := sourceCode
+	for i := 0; i < len(sourceCode); {
+		if sourceCode[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(sourceCode[i:])
+		if i > 0 && sourceCode[i-1] == '\'' && i+size < len(sourceCode) && sourceCode[i+size] == '\'' {
+			i += size
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || unicode.Is(unicode.Nl, r) {
+			if &masked[0] == &sourceCode[0] {
+				masked = append([]byte(nil), sourceCode...)
+			}
+			letter := byte('a')
+			if unicode.IsUpper(r) {
+				letter = 'A'
+			}
+			for j := i; j < i+size; j++ {
+				masked[j] = letter
+			}
+		}
+		i += size
+	}
+	return masked
+}
+
+// runeColumn returns the 0-based column, in characters, of the byte offset in
+// line. Offsets inside a multi-byte character give the column of the character.
+func runeColumn(line []byte, offset int) int {
+	return utf8.RuneCount(line[:runeOffset(line, offset)])
+}
+
+// utf16Column returns the 0-based column, in UTF-16 code units as LSP and LSIF
+// positions are, of the byte offset in line.
+func utf16Column(line []byte, offset int) int {
+	column := 0
+	for _, r := range string(line[:runeOffset(line, offset)]) {
+		if r >= 0x10000 {
+			column += 2
+		} else {
+			column++
+		}
+	}
+	return column
+}
+
+// runeOffset returns offset moved back to the start of the character it is in.
+func runeOffset(line []byte, offset int) int {
+	offset = min(offset, len(line))
+	for offset > 0 && offset < len(line) && !utf8.RuneStart(line[offset]) {
+		offset--
+	}
+	return offset
+}
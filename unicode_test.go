@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeUnicodeNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		wantPackage string
+		wantImports []string
+		wantSymbols []string
+	}{
+		{
+			name:        "decomposed names",
+			source:      "package cafe\u0301\nimport cre\u0300me.Bru\u0302le\u0301e\nobject Cafe\u0301\n",
+			wantPackage: "caf\u00e9",
+			wantImports: []string{"cr\u00e8me.Br\u00fbl\u00e9e"},
+			wantSymbols: []string{"Caf\u00e9"},
+		},
+		{
+			name:        "composed names",
+			source:      "package café\nobject Größe {\n  def länge: Int = 1\n}\n",
+			wantPackage: "café",
+			wantImports: []string{},
+			wantSymbols: []string{"Größe", "Größe.länge"},
+		},
+		{
+			name:        "non-Latin names",
+			source:      "package 数据\nobject Διαδρομή {\n  val длина = 1\n}\n",
+			wantPackage: "数据",
+			wantImports: []string{},
+			wantSymbols: []string{"Διαδρομή", "Διαδρομή.длина"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, errs := NewParser().ParseBytes("A.scala", []byte(tt.source))
+			if len(errs) > 0 {
+				t.Fatalf("errors parsing: %v", errs)
+			}
+			if result.Package != tt.wantPackage {
+				t.Errorf("Package = %q, want %q", result.Package, tt.wantPackage)
+			}
+			if !reflect.DeepEqual(result.Imports, tt.wantImports) {
+				t.Errorf("Imports = %q, want %q", result.Imports, tt.wantImports)
+			}
+			if !reflect.DeepEqual(result.Symbols, tt.wantSymbols) {
+				t.Errorf("Symbols = %q, want %q", result.Symbols, tt.wantSymbols)
+			}
+		})
+	}
+}
+
+func TestMaskUnicodeIdentifiers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"object Café", "object Cafaa"},
+		{"val Ä = 1", "val AA = 1"},
+		{"val c = 'é'", "val c = 'é'"},
+		{"val s = \"→\"", "val s = \"→\""},
+		{"object 数", "object aaa"},
+		{"plain ascii", "plain ascii"},
+	}
+
+	for _, tt := range tests {
+		got := string(maskUnicodeIdentifiers([]byte(tt.source)))
+		if got != tt.want {
+			t.Errorf("maskUnicodeIdentifiers(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+		if len(got) != len(tt.source) {
+			t.Errorf("maskUnicodeIdentifiers(%q) changed the length from %d to %d", tt.source, len(tt.source), len(got))
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	tests := []struct {
+		line      string
+		offset    int
+		wantRune  int
+		wantUTF16 int
+	}{
+		{"val x = 1", 4, 4, 4},
+		// é is 2 bytes, 1 character and 1 UTF-16 unit.
+		{"val é = 1", 7, 6, 6},
+		// 数 is 3 bytes, 1 character and 1 UTF-16 unit.
+		{"val 数 = 1", 8, 6, 6},
+		// 😀 is 4 bytes, 1 character and 2 UTF-16 units.
+		{"val s = \"\U0001F600\" + x", 16, 13, 14},
+		// An offset inside a multi-byte character gives its column.
+		{"val é = 1", 5, 4, 4},
+		{"val é", 100, 5, 5},
+	}
+
+	for _, tt := range tests {
+		if got := runeColumn([]byte(tt.line), tt.offset); got != tt.wantRune {
+			t.Errorf("runeColumn(%q, %d) = %d, want %d", tt.line, tt.offset, got, tt.wantRune)
+		}
+		if got := utf16Column([]byte(tt.line), tt.offset); got != tt.wantUTF16 {
+			t.Errorf("utf16Column(%q, %d) = %d, want %d", tt.line, tt.offset, got, tt.wantUTF16)
+		}
+	}
+}
+
+func TestSyntaxErrorColumnAfterMultiByteCharacters(t *testing.T) {
+	source := "object Café {\n  val été = \"\U0001F600\" + )\n}\n"
+
+	result, _ := NewParser().ParseBytes("A.scala", []byte(source))
+	if len(result.SyntaxErrors) == 0 {
+		t.Fatal("no syntax errors found")
+	}
+	// The stray ) is the 19th character of line 2, but its 24th byte.
+	if got := result.SyntaxErrors[0]; got.Line != 2 || got.Column != 19 {
+		t.Errorf("SyntaxErrors[0] at %d:%d, want 2:19", got.Line, got.Column)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// UsingDirectives is the build metadata declared by scala-cli `//> using`
+// directives, e.g. `//> using dep "com.lihaoyi::os-lib:0.9.1"`.
+type UsingDirectives struct {
+	ScalaVersion string
+	Platforms    []string
+	Deps         []Dependency
+	TestDeps     []Dependency
+	Options      []string
+	JavaOptions  []string
+	MainClass    string
+	Resources    []string
+
+	// Other holds any directive not understood above, keyed by name.
+	Other map[string][]string
+}
+
+// readUsingDirectives scans the comment header of a file for `//> using`
+// directives. scala-cli only honors directives before the first line of code, so
+// scanning stops there. Returns nil if the file has no directives.
+func readUsingDirectives(sourceCode []byte) *UsingDirectives {
+	var directives *UsingDirectives
+	inBlockComment := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(sourceCode))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(sourceCode)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if inBlockComment {
+			inBlockComment = !strings.Contains(line, "*/")
+			continue
+		} else if strings.HasPrefix(line, "/*") {
+			inBlockComment = !strings.Contains(line, "*/")
+			continue
+		} else if line == "" || strings.HasPrefix(line, "#!") {
+			continue
+		} else if !strings.HasPrefix(line, "//") {
+			break
+		}
+
+		rest, ok := strings.CutPrefix(line, "//>")
+		if !ok {
+			continue
+		}
+		fields := splitDirectiveValues(rest)
+		if len(fields) < 2 || fields[0] != "using" {
+			continue
+		}
+
+		if directives == nil {
+			directives = &UsingDirectives{Other: make(map[string][]string)}
+		}
+		directives.add(fields[1], fields[2:])
+	}
+
+	return directives
+}
+
+func (u *UsingDirectives) add(key string, values []string) {
+	switch key {
+	case "scala":
+		if len(values) > 0 {
+			u.ScalaVersion = values[0]
+		}
+	case "platform", "platforms":
+		u.Platforms = append(u.Platforms, values...)
+	case "dep", "deps", "lib", "libs":
+		u.Deps = append(u.Deps, parseDependencies(values)...)
+	case "test.dep", "test.deps", "test.lib", "test.libs":
+		u.TestDeps = append(u.TestDeps, parseDependencies(values)...)
+	case "option", "options", "scalacOption", "scalacOptions":
+		u.Options = append(u.Options, values...)
+	case "javaOpt", "javaOpts", "javaOption", "javaOptions":
+		u.JavaOptions = append(u.JavaOptions, values...)
+	case "mainClass", "main-class":
+		if len(values) > 0 {
+			u.MainClass = values[0]
+		}
+	case "resourceDir", "resourceDirs":
+		u.Resources = append(u.Resources, values...)
+	default:
+		u.Other[key] = append(u.Other[key], values...)
+	}
+}
+
+func parseDependencies(values []string) []Dependency {
+	deps := make([]Dependency, 0, len(values))
+	for _, value := range values {
+		if dep, ok := parseDependency(value); ok {
+			deps = append(deps, dep)
+		}
+	}
+
+	return deps
+}
+
+// splitDirectiveValues splits a directive on whitespace and commas, keeping
+// double-quoted values (which may contain either) intact and unquoted.
+func splitDirectiveValues(directive string) []string {
+	values := make([]string, 0)
+	var current strings.Builder
+	inQuotes := false
+
+	for _, r := range directive {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if !inQuotes && (r == ' ' || r == '\t' || r == ',') {
+			if current.Len() > 0 {
+				values = append(values, current.String())
+				current.Reset()
+			}
+		} else {
+			current.WriteRune(r)
+		}
+	}
+
+	if current.Len() > 0 {
+		values = append(values, current.String())
+	}
+
+	return values
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxVisibilityPackages is the most packages a suggested visibility lists
+// before suggesting public instead.
+const maxVisibilityPackages = 5
+
+// narrowestVisibility returns the narrowest Bazel visibility letting the
+// packages dependents depend on a target in pkg.
+func narrowestVisibility(pkg string, dependents []string) []string {
+	var packages []string
+	for _, dependent := range dependents {
+		if dependent != pkg && !containsString(packages, dependent) {
+			packages = append(packages, dependent)
+		}
+	}
+
+	switch {
+	case len(packages) == 0:
+		return []string{"//visibility:private"}
+	case len(packages) > maxVisibilityPackages:
+		return []string{"//visibility:public"}
+	}
+	sort.Strings(packages)
+	visibility := make([]string, 0, len(packages))
+	for _, dependent := range packages {
+		visibility = append(visibility, "//"+dependent+":__pkg__")
+	}
+	return visibility
+}
+
+// SuggestVisibility returns the narrowest visibility of each library among
+// targets, given the other targets depending on it.
+func SuggestVisibility(targets []*BuildTarget) map[*BuildTarget][]string {
+	libraries := make(map[string]*BuildTarget)
+	dependents := make(map[*BuildTarget][]string)
+	for _, target := range targets {
+		if target.Kind == "scala_library" {
+			libraries[normalizeLabel(target.Label(""), "")] = target
+			dependents[target] = nil
+		}
+	}
+	for _, target := range targets {
+		for _, dep := range target.Deps {
+			if library, ok := libraries[normalizeLabel(dep, target.Package)]; ok {
+				dependents[library] = append(dependents[library], target.Package)
+			}
+		}
+	}
+
+	suggestions := make(map[*BuildTarget][]string, len(dependents))
+	for library, packages := range dependents {
+		suggestions[library] = narrowestVisibility(library.Package, packages)
+	}
+	return suggestions
+}
+
+// PrivateSuggestion is a public top-level definition only referenced from its
+// own package, which could be made `private[pkg]`.
+type PrivateSuggestion struct {
+	Symbol string
+	File   string
+	Line   int
+	// Qualifier is the innermost name of the package, as in `private[foo]`.
+	Qualifier string
+}
+
+// SuggestPackagePrivate returns the public top-level definitions of results
+// that other files reference, but only files of the same package. A wildcard
+// import of the package from elsewhere counts as referencing all of its
+// definitions. Results should come from a parser created WithIndex, as for
+// FindDeadCode, and definitions in mains and tests are never suggested.
+func SuggestPackagePrivate(results []*ParseResult) []PrivateSuggestion {
+	// referencingPackages maps each imported symbol, and each symbol it is nested
+	// in, to the packages of the files importing it, and wildcardPackages each
+	// wildcard imported package or object likewise.
+	referencingPackages := make(map[string][]string)
+	wildcardPackages := make(map[string][]string)
+	referenced := make(map[string]bool)
+	for _, result := range results {
+		for _, imp := range result.Imports {
+			owner, wildcard := strings.CutSuffix(imp, "._")
+			if !wildcard {
+				owner, wildcard = strings.CutSuffix(imp, ".*")
+			}
+			if wildcard {
+				wildcardPackages[owner] = append(wildcardPackages[owner], result.Package)
+				continue
+			}
+			for name := imp; name != ""; {
+				referencingPackages[name] = append(referencingPackages[name], result.Package)
+				referenced[name] = true
+				i := strings.LastIndex(name, ".")
+				if i < 0 {
+					break
+				}
+				name = name[:i]
+			}
+		}
+		for _, ref := range result.SamePackageRefs {
+			referenced[ref] = true
+		}
+	}
+	elsewhere := func(packages []string, pkg string) bool {
+		for _, other := range packages {
+			if other != pkg {
+				return true
+			}
+		}
+		return false
+	}
+
+	var suggestions []PrivateSuggestion
+	for _, result := range results {
+		if result.Package == "" || result.HasMain || isTestFile(result) {
+			continue
+		}
+		qualifier := result.Package[strings.LastIndex(result.Package, ".")+1:]
+		for _, definition := range result.Definitions {
+			symbol := qualify(result.Package, definition.Name)
+			if strings.Contains(definition.Name, ".") || !referenced[symbol] {
+				continue
+			}
+			if elsewhere(referencingPackages[symbol], result.Package) || elsewhere(wildcardPackages[result.Package], result.Package) {
+				continue
+			}
+			suggestions = append(suggestions, PrivateSuggestion{
+				Symbol:    symbol,
+				File:      result.File,
+				Line:      definition.Line,
+				Qualifier: qualifier,
+			})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].File != suggestions[j].File {
+			return suggestions[i].File < suggestions[j].File
+		}
+		return suggestions[i].Line < suggestions[j].Line
+	})
+	return suggestions
+}
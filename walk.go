@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Walk parses source and calls fn for each named node in pre-order. If fn returns
+// false, the node's children are skipped.
+func Walk(source []byte, fn func(node *sitter.Node) bool) error {
+	tree, err := sitter.ParseCtx(context.Background(), grammarInput(source), ScalaLang)
+	if err != nil {
+		return err
+	}
+
+	WalkNode(tree, fn)
+	return nil
+}
+
+// WalkNode calls fn for node and each named node beneath it in pre-order, e.g.
+// over a tree retained by Parser.Tree. If fn returns false, the node's children
+// are skipped.
+func WalkNode(node *sitter.Node, fn func(node *sitter.Node) bool) {
+	if !fn(node) {
+		return
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		WalkNode(node.NamedChild(i), fn)
+	}
+}
+
+// Cursor pairs a node with the source it was parsed from, for convenient access
+// to fields, children and text. The zero Cursor is invalid, and every accessor on
+// it returns another invalid Cursor or an empty value, so lookups can be chained:
+//
+//	name := NewCursor(node, source).Field("name").Text()
+type Cursor struct {
+	node   *sitter.Node
+	source []byte
+}
+
+func NewCursor(node *sitter.Node, source []byte) Cursor {
+	return Cursor{node: node, source: source}
+}
+
+// Valid reports whether the cursor points at a node.
+func (c Cursor) Valid() bool {
+	return c.node != nil && !c.node.IsNull()
+}
+
+func (c Cursor) Node() *sitter.Node {
+	return c.node
+}
+
+func (c Cursor) Type() string {
+	if !c.Valid() {
+		return ""
+	}
+	return c.node.Type()
+}
+
+// Text returns the source text spanned by the node.
+func (c Cursor) Text() string {
+	if !c.Valid() {
+		return ""
+	}
+	return c.node.Content(c.source)
+}
+
+// Field returns the child stored under a grammar field, e.g. `name` or `body`.
+func (c Cursor) Field(name string) Cursor {
+	if !c.Valid() {
+		return Cursor{}
+	}
+	return c.with(c.node.ChildByFieldName(name))
+}
+
+func (c Cursor) Parent() Cursor {
+	if !c.Valid() {
+		return Cursor{}
+	}
+	return c.with(c.node.Parent())
+}
+
+// Children returns the node's named children.
+func (c Cursor) Children() []Cursor {
+	children := make([]Cursor, 0)
+	if !c.Valid() {
+		return children
+	}
+
+	for i := 0; i < int(c.node.NamedChildCount()); i++ {
+		children = append(children, c.with(c.node.NamedChild(i)))
+	}
+	return children
+}
+
+// ChildrenOfType returns the node's named children of the given type.
+func (c Cursor) ChildrenOfType(nodeType string) []Cursor {
+	children := make([]Cursor, 0)
+	for _, child := range c.Children() {
+		if child.Type() == nodeType {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Walk calls fn for the node and each named node beneath it; see WalkNode.
+func (c Cursor) Walk(fn func(Cursor) bool) {
+	if !c.Valid() {
+		return
+	}
+	WalkNode(c.node, func(node *sitter.Node) bool {
+		return fn(c.with(node))
+	})
+}
+
+func (c Cursor) with(node *sitter.Node) Cursor {
+	return Cursor{node: node, source: c.source}
+}
@@ -0,0 +1,12 @@
+package main
+
+// Warning is a problem found in a file that does not stop it being parsed.
+type Warning struct {
+	// Kind identifies the check that produced the warning, e.g. `package-mismatch`.
+	Kind    string
+	Message string
+	Line    int
+	// Node is the type of the syntax node an `unknown-node` warning is about,
+	// e.g. `extension_definition`.
+	Node string
+}
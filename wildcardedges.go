@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// WildcardEdge is a dependency of a file on another package of the workspace
+// made only through wildcard imports. Such edges are imprecise: the file may use
+// none of the package's members, or only some, so they are worth replacing with
+// explicit imports.
+type WildcardEdge struct {
+	File    string
+	Package string
+	// Imports are the file's wildcard imports reaching Package.
+	Imports []string
+}
+
+// FindWildcardEdges returns the dependencies between files in results and other
+// packages, according to index, that are made only through wildcard imports,
+// sorted by file and package. results must come from a parser created without
+// WithIndex, which would have expanded the wildcards.
+func FindWildcardEdges(results []*ParseResult, index *Index) []WildcardEdge {
+	packages := make(map[string]string)
+	for _, result := range results {
+		packages[result.File] = result.Package
+	}
+
+	var edges []WildcardEdge
+	for _, result := range results {
+		wildcards := make(map[string][]string)
+		explicit := make(map[string]bool)
+
+		for _, imp := range result.Imports {
+			wildcard := strings.HasSuffix(imp, "._") || strings.HasSuffix(imp, ".*")
+			reached := make(map[string]bool)
+			for _, file := range importedFiles(index, imp) {
+				if pkg, ok := packages[file]; ok && pkg != result.Package {
+					reached[pkg] = true
+				}
+			}
+
+			for pkg := range reached {
+				if wildcard {
+					wildcards[pkg] = append(wildcards[pkg], imp)
+				} else {
+					explicit[pkg] = true
+				}
+			}
+		}
+
+		for pkg, imports := range wildcards {
+			if !explicit[pkg] {
+				edges = append(edges, WildcardEdge{File: result.File, Package: pkg, Imports: imports})
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].File != edges[j].File {
+			return edges[i].File < edges[j].File
+		}
+		return edges[i].Package < edges[j].Package
+	})
+	return edges
+}
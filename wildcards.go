@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+)
+
+// expandWildcards replaces each wildcard import whose package is in the index
+// with explicit imports of the members the file actually references. Wildcards
+// with no referenced members are kept, since they may still bring implicits into
+// scope.
+func expandWildcards(result *ParseResult, index *Index, references map[string]bool) {
+	suffix := "." + result.Dialect.Wildcard()
+	imports := make([]string, 0, len(result.Imports))
+
+	for _, imp := range result.Imports {
+		owner, ok := strings.CutSuffix(imp, suffix)
+		if !ok {
+			imports = append(imports, imp)
+			continue
+		}
+
+		expanded := 0
+		for _, member := range index.Members(owner) {
+			if references[member[strings.LastIndex(member, ".")+1:]] {
+				imports = append(imports, member)
+				expanded++
+			}
+		}
+
+		if expanded == 0 {
+			imports = append(imports, imp)
+		}
+	}
+
+	result.Imports = imports
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The grammar has no support for Scala 2 XML literals, and a single literal can
+// derail parsing of the definitions around it. Before parsing we find each
+// literal and replace it with `null`, padded with spaces (and keeping newlines)
+// so that every other byte offset and line number is unchanged.
+
+// blankXMLLiterals returns sourceCode with XML literals blanked out, and whether
+// any were found. sourceCode is only copied if it contains a literal.
+func blankXMLLiterals(sourceCode []byte) ([]byte, bool) {
+	if !bytes.Contains(sourceCode, []byte("</")) && !bytes.Contains(sourceCode, []byte("/>")) {
+		return sourceCode, false
+	}
+
+	blanked := sourceCode
+	found := false
+
+	for i := 0; i < len(sourceCode); i++ {
+		switch {
+		case bytes.HasPrefix(sourceCode[i:], []byte("//")):
+			i = skipUntil(sourceCode, i, "\n") - 1
+		case bytes.HasPrefix(sourceCode[i:], []byte("/*")):
+			i = skipBlockComment(sourceCode, i) - 1
+		case bytes.HasPrefix(sourceCode[i:], []byte(`"""`)):
+			i = skipUntil(sourceCode, i+3, `"""`) - 1
+		case sourceCode[i] == '"':
+			i = skipStringLiteral(sourceCode, i) - 1
+		case sourceCode[i] == '\'' && i+2 < len(sourceCode) && (sourceCode[i+2] == '\'' || sourceCode[i+1] == '\\'):
+			i = skipStringLiteral(sourceCode, i) - 1
+		case sourceCode[i] == '<' && startsXMLLiteral(sourceCode, i):
+			end, ok := scanXMLElement(sourceCode, i)
+			if !ok {
+				continue
+			}
+
+			if !found {
+				blanked = bytes.Clone(sourceCode)
+				found = true
+			}
+			blankRange(blanked, i, end)
+			i = end - 1
+		}
+	}
+
+	return blanked, found
+}
+
+// startsXMLLiteral follows the spec: an XML literal starts with a `<` preceded by
+// whitespace, `(` or `{`, and followed by the start of an XML name.
+func startsXMLLiteral(sourceCode []byte, i int) bool {
+	if i > 0 {
+		prev := sourceCode[i-1]
+		if prev != ' ' && prev != '\t' && prev != '\n' && prev != '\r' && prev != '(' && prev != '{' {
+			return false
+		}
+	}
+
+	return i+1 < len(sourceCode) && (isXMLNameStart(sourceCode[i+1:]) || sourceCode[i+1] == '!')
+}
+
+func isXMLNameStart(b []byte) bool {
+	r, _ := utf8.DecodeRune(b)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isXMLNameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c == ':' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// scanXMLElement returns the end offset of the element starting at i.
+func scanXMLElement(sourceCode []byte, i int) (int, bool) {
+	if bytes.HasPrefix(sourceCode[i:], []byte("<!--")) {
+		return checkedEnd(sourceCode, skipUntil(sourceCode, i+4, "-->"))
+	} else if bytes.HasPrefix(sourceCode[i:], []byte("<![CDATA[")) {
+		return checkedEnd(sourceCode, skipUntil(sourceCode, i+9, "]]>"))
+	} else if sourceCode[i+1] == '!' {
+		return 0, false
+	}
+
+	// start tag and attributes
+	i++
+	for i < len(sourceCode) && isXMLNameChar(sourceCode[i]) {
+		i++
+	}
+	for i < len(sourceCode) && sourceCode[i] != '>' {
+		switch sourceCode[i] {
+		case '"', '\'':
+			end := bytes.IndexByte(sourceCode[i+1:], sourceCode[i])
+			if end < 0 {
+				return 0, false
+			}
+			i += end + 2
+		case '{':
+			end, ok := scanScalaBlock(sourceCode, i)
+			if !ok {
+				return 0, false
+			}
+			i = end
+		case '/':
+			if i+1 < len(sourceCode) && sourceCode[i+1] == '>' {
+				return i + 2, true
+			}
+			return 0, false
+		case '<':
+			return 0, false
+		default:
+			i++
+		}
+	}
+	if i >= len(sourceCode) {
+		return 0, false
+	}
+	i++
+
+	// content, up to the matching end tag
+	for i < len(sourceCode) {
+		switch {
+		case bytes.HasPrefix(sourceCode[i:], []byte("</")):
+			end := bytes.IndexByte(sourceCode[i:], '>')
+			if end < 0 {
+				return 0, false
+			}
+			return i + end + 1, true
+		case sourceCode[i] == '<':
+			end, ok := scanXMLElement(sourceCode, i)
+			if !ok {
+				return 0, false
+			}
+			i = end
+		case sourceCode[i] == '{':
+			end, ok := scanScalaBlock(sourceCode, i)
+			if !ok {
+				return 0, false
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+
+	return 0, false
+}
+
+// scanScalaBlock returns the end offset of the embedded `{ ... }` expression
+// starting at i, which may itself contain XML literals.
+func scanScalaBlock(sourceCode []byte, i int) (int, bool) {
+	depth := 0
+	for ; i < len(sourceCode); i++ {
+		switch {
+		case sourceCode[i] == '{':
+			depth++
+		case sourceCode[i] == '}':
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		case sourceCode[i] == '"':
+			i = skipStringLiteral(sourceCode, i) - 1
+		case sourceCode[i] == '<' && startsXMLLiteral(sourceCode, i):
+			if end, ok := scanXMLElement(sourceCode, i); ok {
+				i = end - 1
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func skipUntil(sourceCode []byte, i int, terminator string) int {
+	end := bytes.Index(sourceCode[i:], []byte(terminator))
+	if end < 0 {
+		return len(sourceCode) + 1
+	}
+
+	return i + end + len(terminator)
+}
+
+func checkedEnd(sourceCode []byte, end int) (int, bool) {
+	return end, end <= len(sourceCode)
+}
+
+func skipBlockComment(sourceCode []byte, i int) int {
+	depth := 0
+	for ; i+1 < len(sourceCode); i++ {
+		if sourceCode[i] == '/' && sourceCode[i+1] == '*' {
+			depth++
+			i++
+		} else if sourceCode[i] == '*' && sourceCode[i+1] == '/' {
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(sourceCode)
+}
+
+func skipStringLiteral(sourceCode []byte, i int) int {
+	quote := sourceCode[i]
+	for i++; i < len(sourceCode); i++ {
+		if sourceCode[i] == '\\' {
+			i++
+		} else if sourceCode[i] == quote || sourceCode[i] == '\n' {
+			return i + 1
+		}
+	}
+
+	return len(sourceCode)
+}
+
+func blankRange(sourceCode []byte, start, end int) {
+	for i := start; i < end; i++ {
+		if sourceCode[i] != '\n' && sourceCode[i] != '\r' {
+			sourceCode[i] = ' '
+		}
+	}
+
+	// `<a\n/>` is too short for `null` before the newline; `()` always fits.
+	if bytes.ContainsAny(sourceCode[start:min(start+4, end)], "\r\n") {
+		copy(sourceCode[start:end], "()")
+	} else {
+		copy(sourceCode[start:end], "null")
+	}
+}